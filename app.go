@@ -3,36 +3,162 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"signal-from-noise/config"
+	"signal-from-noise/database"
+)
+
+// databasePath is where the app keeps its SQLite store, relative to the working directory
+const databasePath = "database/signal-from-noise.db"
+
+// productionsPath is where produced zip files are written, relative to the working directory
+const productionsPath = "productions"
+
+// Matter configuration kept beside the database; without a file the built-in defaults apply
+const (
+	piiRulesPath        = "database/pii_rules.json"
+	privilegeScreenPath = "database/privilege_screen.json"
+	issueRulesPath      = "database/issue_rules.json"
+)
+
+// Relevance ranking events, emitted when a background retraining finishes
+const (
+	EventRelevanceUpdated = "relevance:updated" // database.RelevanceStatus
+	EventRelevanceFailed  = "relevance:failed"  // RelevanceRefreshError
+)
+
+// RelevanceRefreshError is the payload of EventRelevanceFailed
+type RelevanceRefreshError struct {
+	ProductionRequestID string `json:"production_request_id"`
+	Error               string `json:"error"`
+}
+
+// ZipRequest is the frontend's request to package selected files for a production request
+type ZipRequest struct {
+	ProductionRequestID string   `json:"production_request_id"`
+	FileIDs             []int64  `json:"file_ids"`
+	BatesPrefix         string   `json:"bates_prefix"`
+	VolumeSizeLimit     int64    `json:"volume_size_limit"`
+	LoadFileFields      []string `json:"load_file_fields"` // DAT columns; empty for the defaults
+	Custodian           string   `json:"custodian"`
+	EDRMXML             bool     `json:"edrm_xml"`
+	WithholdFamilies    bool     `json:"withhold_families"` // Slip-sheet whole families of privileged documents
+	Encrypt             bool     `json:"encrypt"`           // AES-256 encrypt the volumes
+	Password            string   `json:"password"`          // Empty generates one
+	// TechnicalSlipSheets slip-sheets files DetectTechnicalIssues flags; SlipSheets maps
+	// further file IDs to the reason printed on their slip sheets
+	TechnicalSlipSheets bool             `json:"technical_slip_sheets"`
+	SlipSheets          map[int64]string `json:"slip_sheets"`
+}
+
+// ZipResult reports the outcome of CreateZip; Missing, Changed and Unindexed list data lake
+// files that did not match the index when Success is false
+type ZipResult struct {
+	Success      bool                        `json:"success"`
+	ProductionID int64                       `json:"production_id"`
+	ZipPath      string                      `json:"zip_path"` // Production directory holding the volumes
+	Message      string                      `json:"message"`
+	Volumes      []database.ProductionVolume `json:"volumes,omitempty"`
+	Password     string                      `json:"password,omitempty"`      // Shown once; never recorded
+	PasswordNote string                      `json:"password_note,omitempty"` // Deliver separately from the volumes
+	Missing      []string                    `json:"missing,omitempty"`
+	Changed      []string                    `json:"changed,omitempty"`
+	Unindexed    []string                    `json:"unindexed,omitempty"`
+	Unknown      []int64                     `json:"unknown,omitempty"` // Selected IDs no longer in the index
+}
+
+// Production job events emitted through the Wails runtime
+const (
+	EventProductionProgress = "production:progress" // ProductionJobProgress
+	EventProductionDone     = "production:done"     // ProductionJobResult
 )
 
-// ProductionRequest represents a production request
-type ProductionRequest struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title,omitempty"` // Generated, so omitempty
-	Description string `json:"description"`
+// ProductionJobProgress is the payload of EventProductionProgress
+type ProductionJobProgress struct {
+	JobID int64 `json:"job_id"`
+	database.ProductionProgress
+}
+
+// ProductionJobResult is the payload of EventProductionDone, sent once per job
+type ProductionJobResult struct {
+	JobID     int64     `json:"job_id"`
+	Cancelled bool      `json:"cancelled"` // Partial output has been removed
+	Result    ZipResult `json:"result"`
+	Error     string    `json:"error,omitempty"`
 }
 
 // App struct
 type App struct {
 	ctx      context.Context
 	manifest map[string]string
+
+	dbMu sync.Mutex // Guards db; Wails calls bound methods concurrently
+	db   *database.DB
+
+	jobsMu     sync.Mutex
+	jobs       map[int64]context.CancelFunc // Running production jobs by ID
+	nextJobID  int64
+	retraining map[string]bool // Requests being retrained; true once decisions change mid-run
+	jobsWG     sync.WaitGroup
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	return &App{
-		manifest: make(map[string]string),
+		manifest:   make(map[string]string),
+		jobs:       make(map[int64]context.CancelFunc),
+		retraining: make(map[string]bool),
 	}
 }
 
 // startup is called when the app starts
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	// Load manifest will happen lazily when needed
+	// Load manifest and open database will happen lazily when needed
+}
+
+// shutdown is called when the app is closing
+// Running production jobs are cancelled and allowed to remove their partial output first,
+// and a relevance retraining in progress is allowed to finish
+func (a *App) shutdown(ctx context.Context) {
+	a.jobsMu.Lock()
+	for _, cancel := range a.jobs {
+		cancel()
+	}
+	a.jobsMu.Unlock()
+	a.jobsWG.Wait()
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+	if a.db != nil {
+		a.db.Close()
+	}
+}
+
+// openDatabase opens the SQLite store on first use
+// Concurrent first calls wait for one NewDB rather than each seeding the store; a
+// failed open is returned to every waiting caller and retried on the next call
+func (a *App) openDatabase() (*database.DB, error) {
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+	if a.db != nil {
+		return a.db, nil // Already open
+	}
+
+	db, err := database.NewDB(databasePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+	a.db = db
+	return db, nil
 }
 
 // loadManifest loads the JSON manifest file
@@ -72,40 +198,837 @@ func (a *App) Greet(name string) string {
 	return "Hello " + name + ", It's show time!"
 }
 
-// GetProductionRequests loads and returns production requests from JSON
-func (a *App) GetProductionRequests() ([]ProductionRequest, error) {
-	// Try database folder first, then frontend
-	paths := []string{
-		"database/production_requests.json",
-		"frontend/src/data/production_requests.json",
+// GetProductionRequests returns production requests from the database
+func (a *App) GetProductionRequests() ([]database.ProductionRequest, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
 	}
+	return db.GetProductionRequests()
+}
 
-	var data []byte
-	var err error
-	for _, path := range paths {
-		data, err = os.ReadFile(path)
-		if err == nil {
-			break
+// CreateProductionRequest adds a production request
+func (a *App) CreateProductionRequest(request database.ProductionRequest) (*database.ProductionRequest, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.CreateProductionRequest(request)
+}
+
+// UpdateProductionRequest saves changes to a production request
+func (a *App) UpdateProductionRequest(request database.ProductionRequest) (*database.ProductionRequest, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.UpdateProductionRequest(request)
+}
+
+// DeleteProductionRequest removes a production request
+func (a *App) DeleteProductionRequest(id string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.DeleteProductionRequest(id)
+}
+
+// ImportProductionRequests loads production requests from a JSON file
+// Returns the number of requests imported
+func (a *App) ImportProductionRequests(path string) (int, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.ImportProductionRequestsJSON(path)
+}
+
+// DraftFilters proposes search filters parsed from a production request's description
+// The frontend shows the draft for the user to confirm or edit
+func (a *App) DraftFilters(requestID string) (*database.FilterDraft, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.DraftFilters(requestID)
+}
+
+// SaveSearch stores the current filters as a named search for their production request
+func (a *App) SaveSearch(name string, filters database.FileFilters) (*database.SavedSearch, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.SaveSearch(name, filters)
+}
+
+// GetSavedSearches returns the saved searches of a production request
+func (a *App) GetSavedSearches(requestID string) ([]database.SavedSearch, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetSavedSearches(requestID)
+}
+
+// RunSavedSearch re-runs a saved search and reports what changed since its last run
+func (a *App) RunSavedSearch(searchID int64) (*database.SearchDiff, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.RunSavedSearch(searchID)
+}
+
+// RecordReviewDecisions records a reviewer's decision on files for a production request
+// The relevance ranking is brought up to date in the background afterwards
+func (a *App) RecordReviewDecisions(fileIDs []int64, requestID, decision, reviewer, note string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	if err := db.RecordReviewDecisions(fileIDs, requestID, decision, reviewer, note); err != nil {
+		return err
+	}
+	a.refreshRelevance(db, requestID)
+	return nil
+}
+
+// ClearReviewDecision returns a file to unreviewed for a production request
+// The relevance ranking is brought up to date in the background afterwards
+func (a *App) ClearReviewDecision(fileID int64, requestID, reviewer string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	if err := db.ClearReviewDecision(fileID, requestID, reviewer); err != nil {
+		return err
+	}
+	a.refreshRelevance(db, requestID)
+	return nil
+}
+
+// refreshRelevance retrains a request's ranking in the background once enough decisions
+// have been coded, reporting the outcome as EventRelevanceUpdated or EventRelevanceFailed
+// One retraining runs per request; decisions coded meanwhile mark it dirty, and it
+// runs again when it finishes so they are not left out of the ranking
+func (a *App) refreshRelevance(db *database.DB, requestID string) {
+	a.jobsMu.Lock()
+	if _, running := a.retraining[requestID]; running {
+		a.retraining[requestID] = true
+		a.jobsMu.Unlock()
+		return
+	}
+	a.retraining[requestID] = false
+	a.jobsMu.Unlock()
+
+	a.jobsWG.Add(1)
+	go func() {
+		defer a.jobsWG.Done()
+		for {
+			status, err := db.RefreshRelevanceModel(requestID)
+			switch {
+			case err != nil:
+				a.emit(EventRelevanceFailed, RelevanceRefreshError{ProductionRequestID: requestID, Error: err.Error()})
+			case status != nil:
+				a.emit(EventRelevanceUpdated, status)
+			}
+
+			a.jobsMu.Lock()
+			if !a.retraining[requestID] {
+				delete(a.retraining, requestID)
+				a.jobsMu.Unlock()
+				return
+			}
+			a.retraining[requestID] = false
+			a.jobsMu.Unlock()
 		}
+	}()
+}
+
+// emit sends an event to the frontend once the Wails runtime has started
+func (a *App) emit(event string, data interface{}) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, event, data)
+	}
+}
+
+// GetReviewSummary reports review progress for the files matching filters
+func (a *App) GetReviewSummary(filters database.FileFilters) (*database.ReviewSummary, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
 	}
+	return db.GetReviewSummary(filters)
+}
 
+// GetTagSets returns the tag sets with their tag hierarchies
+func (a *App) GetTagSets() ([]database.TagSet, error) {
+	db, err := a.openDatabase()
 	if err != nil {
-		return nil, fmt.Errorf("could not load production requests: %w", err)
+		return nil, err
 	}
+	return db.GetTagSets()
+}
 
-	var requests []ProductionRequest
-	if err := json.Unmarshal(data, &requests); err != nil {
-		return nil, fmt.Errorf("could not parse production requests: %w", err)
+// CreateTagSet creates a tag set; single-select sets allow one tag per file
+func (a *App) CreateTagSet(name string, multiSelect bool) (int64, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
 	}
+	return db.CreateTagSet(name, multiSelect)
+}
 
-	// Generate title from ID (ensure it's always set)
-	for i := range requests {
-		if requests[i].Title == "" {
-			requests[i].Title = fmt.Sprintf("REQUEST FOR PRODUCTION NO: %d", requests[i].ID)
+// UpdateTagSet renames a tag set or changes whether it allows several tags per file
+func (a *App) UpdateTagSet(id int64, name string, multiSelect bool) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.UpdateTagSet(id, name, multiSelect)
+}
+
+// DeleteTagSet removes a tag set and its tags from every file
+func (a *App) DeleteTagSet(id int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.DeleteTagSet(id)
+}
+
+// CreateTag adds a tag to a set; parentID 0 creates a top-level tag
+func (a *App) CreateTag(setID, parentID int64, name string) (int64, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	var parent *int64
+	if parentID != 0 {
+		parent = &parentID
+	}
+	return db.CreateTag(setID, parent, name)
+}
+
+// DeleteTag removes a tag and its descendants from every file
+func (a *App) DeleteTag(id int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.DeleteTag(id)
+}
+
+// TagFiles applies a tag to the selected files
+func (a *App) TagFiles(fileIDs []int64, tagID int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.TagFiles(fileIDs, tagID)
+}
+
+// UntagFiles removes a tag from the selected files
+func (a *App) UntagFiles(fileIDs []int64, tagID int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.UntagFiles(fileIDs, tagID)
+}
+
+// TagSearchResult applies a tag to every file matching filters
+func (a *App) TagSearchResult(filters database.FileFilters, tagID int64) (int, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.TagSearchResult(filters, tagID)
+}
+
+// UntagSearchResult removes a tag from every file matching filters
+func (a *App) UntagSearchResult(filters database.FileFilters, tagID int64) (int, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.UntagSearchResult(filters, tagID)
+}
+
+// GetTagUsage returns file counts per tag
+func (a *App) GetTagUsage() ([]database.TagUsage, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetTagUsage()
+}
+
+// CreateBatches splits a saved search's unbatched results into review batches
+func (a *App) CreateBatches(searchID int64, maxSize int) ([]database.ReviewBatch, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.CreateBatches(searchID, maxSize)
+}
+
+// GetBatches returns a production request's review batches with progress
+func (a *App) GetBatches(requestID string) ([]database.ReviewBatch, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetBatches(requestID)
+}
+
+// CheckOutBatch assigns a batch to a reviewer
+func (a *App) CheckOutBatch(batchID int64, reviewer string) (*database.ReviewBatch, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.CheckOutBatch(batchID, reviewer)
+}
+
+// CheckInBatch returns a batch from its reviewer
+func (a *App) CheckInBatch(batchID int64, reviewer string) (*database.ReviewBatch, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.CheckInBatch(batchID, reviewer)
+}
+
+// GetBatchFiles returns one page of a batch's files
+func (a *App) GetBatchFiles(batchID int64, page, pageSize int) (*database.FileResult, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetBatchFiles(batchID, page, pageSize)
+}
+
+// TrainRelevanceModel trains the responsiveness ranking for a production request
+func (a *App) TrainRelevanceModel(requestID string) (*database.RelevanceStatus, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.TrainRelevanceModel(requestID)
+}
+
+// GetRelevanceStatus reports the training status of a production request's ranking
+func (a *App) GetRelevanceStatus(requestID string) (*database.RelevanceStatus, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetRelevanceStatus(requestID)
+}
+
+// CreateElusionSample draws a reproducible random sample from a request's discard pile
+func (a *App) CreateElusionSample(requestID string, sampleSize int, seed int64) (*database.ElusionSample, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.CreateElusionSample(requestID, sampleSize, seed)
+}
+
+// GetElusionSampleFiles returns a sample's files in draw order with their QC calls
+func (a *App) GetElusionSampleFiles(sampleID int64) ([]database.ElusionSampleFile, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetElusionSampleFiles(sampleID)
+}
+
+// RecordElusionDecision records a QC call on a sampled file
+func (a *App) RecordElusionDecision(sampleID, fileID int64, responsive bool, reviewer string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.RecordElusionDecision(sampleID, fileID, responsive, reviewer)
+}
+
+// GetElusionReport returns elusion and recall estimates for a sample
+func (a *App) GetElusionReport(sampleID int64) (*database.ElusionReport, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetElusionReport(sampleID)
+}
+
+// FinalizeElusionSample freezes a fully reviewed sample's report
+func (a *App) FinalizeElusionSample(sampleID int64) (*database.ElusionReport, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.FinalizeElusionSample(sampleID)
+}
+
+// GetElusionSamples returns the elusion samples drawn for a production request
+func (a *App) GetElusionSamples(requestID string) ([]database.ElusionSample, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetElusionSamples(requestID)
+}
+
+// LinkElusionSample records that a finalized sample validated a production
+func (a *App) LinkElusionSample(sampleID, productionID int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.LinkElusionSample(sampleID, productionID)
+}
+
+// WriteElusionReport writes a sample's report as plain text to outputDir and returns its path
+func (a *App) WriteElusionReport(sampleID int64, outputDir string) (string, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return "", err
+	}
+	return db.WriteElusionReport(sampleID, outputDir)
+}
+
+// SetPrivilegeEntry records the privilege basis and log details for a file
+func (a *App) SetPrivilegeEntry(entry database.PrivilegeEntry) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.SetPrivilegeEntry(entry)
+}
+
+// GeneratePrivilegeLog previews the privilege log for the files matching filters
+func (a *App) GeneratePrivilegeLog(filters database.FileFilters, withholdFamilies bool) (*database.PrivilegeLog, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GeneratePrivilegeLog(filters, withholdFamilies)
+}
+
+// ExportPrivilegeLog writes the privilege log as CSV and XLSX and returns both paths
+func (a *App) ExportPrivilegeLog(filters database.FileFilters, withholdFamilies bool, outputDir string) ([]string, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	csvPath, xlsxPath, err := db.ExportPrivilegeLog(filters, withholdFamilies, outputDir)
+	if err != nil {
+		return nil, err
+	}
+	return []string{csvPath, xlsxPath}, nil
+}
+
+// CreateZip packages the selected files from the data lake into a verified production zip
+// The call blocks until the production is written; StartProduction runs it in the background
+func (a *App) CreateZip(req ZipRequest) (ZipResult, error) {
+	return a.createZip(context.Background(), req, nil)
+}
+
+// createZip packages a production, stopping when ctx is cancelled
+func (a *App) createZip(ctx context.Context, req ZipRequest, progress database.ProgressFunc) (ZipResult, error) {
+	if req.ProductionRequestID == "" || len(req.FileIDs) == 0 {
+		return ZipResult{Message: "select a production request and at least one file"}, nil
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return ZipResult{Message: err.Error()}, nil
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return ZipResult{}, err
+	}
+
+	settings := database.ProductionSettings{
+		BatesPrefix:         req.BatesPrefix,
+		VolumeSizeLimit:     req.VolumeSizeLimit,
+		LoadFileFields:      req.LoadFileFields,
+		Custodian:           req.Custodian,
+		EDRMXML:             req.EDRMXML,
+		WithholdFamilies:    req.WithholdFamilies,
+		Encrypt:             req.Encrypt,
+		Password:            req.Password,
+		TechnicalSlipSheets: req.TechnicalSlipSheets,
+		SlipSheets:          req.SlipSheets,
+	}
+	output, err := db.CreateZipFile(ctx, req.ProductionRequestID, req.FileIDs, cfg.GetDataLakePath(), productionsPath, settings, progress)
+	var mismatch *database.ZipVerificationError
+	if errors.As(err, &mismatch) {
+		return ZipResult{
+			Message:   err.Error(),
+			Missing:   mismatch.Missing,
+			Changed:   mismatch.Changed,
+			Unindexed: mismatch.Unindexed,
+			Unknown:   mismatch.Unknown,
+		}, nil
+	}
+	if err != nil {
+		return ZipResult{}, err
+	}
+	return ZipResult{
+		Success:      true,
+		ProductionID: output.ID,
+		ZipPath:      output.Directory,
+		Volumes:      output.Volumes,
+		Password:     output.Settings.Password,
+		PasswordNote: output.PasswordNote,
+	}, nil
+}
+
+// StartProduction packages a production in the background and returns its job ID
+// Progress arrives as EventProductionProgress events and the outcome as EventProductionDone
+func (a *App) StartProduction(req ZipRequest) (int64, error) {
+	// Opened here so jobs never race to open the database lazily
+	if _, err := a.openDatabase(); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.jobsMu.Lock()
+	a.nextJobID++
+	id := a.nextJobID
+	a.jobs[id] = cancel
+	a.jobsMu.Unlock()
+
+	a.jobsWG.Add(1)
+	go func() {
+		defer a.jobsWG.Done()
+		defer func() {
+			a.jobsMu.Lock()
+			delete(a.jobs, id)
+			a.jobsMu.Unlock()
+			cancel()
+		}()
+		// A panic here would take the whole app down; CreateZipFile has already removed
+		// the partial output and released its Bates numbers as it unwound
+		defer func() {
+			if r := recover(); r != nil {
+				a.emit(EventProductionDone, ProductionJobResult{JobID: id, Error: fmt.Sprintf("production failed: %v", r)})
+			}
+		}()
+
+		result, err := a.createZip(ctx, req, func(p database.ProductionProgress) {
+			a.emit(EventProductionProgress, ProductionJobProgress{JobID: id, ProductionProgress: p})
+		})
+		done := ProductionJobResult{JobID: id, Result: result}
+		switch {
+		case errors.Is(err, context.Canceled):
+			done.Cancelled = true
+			done.Result.Message = "production cancelled; partial output was removed"
+		case err != nil:
+			done.Error = err.Error()
 		}
+		a.emit(EventProductionDone, done)
+	}()
+	return id, nil
+}
+
+// CancelProduction cancels a running production job; it finishes with a cancelled EventProductionDone
+func (a *App) CancelProduction(jobID int64) error {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	cancel, ok := a.jobs[jobID]
+	if !ok {
+		return fmt.Errorf("production job %d is not running", jobID)
+	}
+	cancel()
+	return nil
+}
+
+// GetProductions returns the production history for a request ("" for all)
+func (a *App) GetProductions(requestID string) ([]database.ProductionSummary, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetProductions(requestID)
+}
+
+// GetProduction returns a recorded production with its volumes and documents
+func (a *App) GetProduction(id int64) (*database.ProductionOutput, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetProduction(id)
+}
+
+// GetDocumentProductions returns the productions a file went out in
+func (a *App) GetDocumentProductions(fileID int64) ([]database.DocumentProduction, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetDocumentProductions(fileID)
+}
+
+// DetectTechnicalIssues lists selected files that cannot be converted for production
+func (a *App) DetectTechnicalIssues(fileIDs []int64) ([]database.TechnicalIssue, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.DetectTechnicalIssues(fileIDs, cfg.GetDataLakePath())
+}
+
+// RecreateProduction writes an identical copy of a recorded production to outputDir
+// An encrypted production needs the password it was made with
+func (a *App) RecreateProduction(id int64, outputDir, password string) (*database.ProductionOutput, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.RecreateProduction(id, "", outputDir, password)
+}
+
+// SetBatesSequence configures the padding and start number for a Bates prefix
+func (a *App) SetBatesSequence(prefix string, padding int, startNumber int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.SetBatesSequence(prefix, padding, startNumber)
+}
+
+// GetBatesSequences returns the matter's Bates sequences
+func (a *App) GetBatesSequences() ([]database.BatesSequence, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetBatesSequences()
+}
+
+// IndexContentHashes hashes data lake files that have no indexed hash yet
+func (a *App) IndexContentHashes() (int, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return 0, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.IndexContentHashes(cfg.GetDataLakePath())
+}
+
+// VerifyProduction re-checks a produced archive against its record and returns a pass/fail report
+// An empty directory verifies the volumes where they were produced; password opens an encrypted one
+func (a *App) VerifyProduction(id int64, directory, password string) (*database.VerificationReport, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.VerifyProduction(id, directory, password)
+}
+
+// VerifyAuditLog re-checks the chain-of-custody ledger's hash chain
+func (a *App) VerifyAuditLog() (*database.AuditVerification, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.VerifyAuditLog()
+}
+
+// GetCustodyReport returns the custody history of a document or, with fileID 0, a production
+func (a *App) GetCustodyReport(fileID, productionID int64) (*database.CustodyReport, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetCustodyReport(fileID, productionID)
+}
+
+// ExportCustodyReport writes a document's or production's custody report and returns its path
+func (a *App) ExportCustodyReport(fileID, productionID int64, outputDir string) (string, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return "", err
+	}
+	return db.ExportCustodyReport(fileID, productionID, outputDir)
+}
+
+// privilegeScreen returns the matter's screen from privilegeScreenPath, or the default screen
+func privilegeScreen() (database.PrivilegeScreen, error) {
+	if _, err := os.Stat(privilegeScreenPath); os.IsNotExist(err) {
+		return database.DefaultPrivilegeScreen(), nil
+	}
+	screen, err := database.LoadPrivilegeScreen(privilegeScreenPath)
+	if err != nil {
+		return database.PrivilegeScreen{}, err
+	}
+	return *screen, nil
+}
+
+// ScreenPrivilege runs the matter's privilege screen and returns the number of files pending review
+func (a *App) ScreenPrivilege() (int, error) {
+	screen, err := privilegeScreen()
+	if err != nil {
+		return 0, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.ScreenPrivilege(screen)
+}
+
+// GetPrivilegeQueue returns privilege queue items with the given status ("" for all)
+func (a *App) GetPrivilegeQueue(status string) ([]database.PrivilegeQueueItem, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPrivilegeQueue(status)
+}
+
+// GetPrivilegeScreenEvidence returns why the screen flagged a file
+func (a *App) GetPrivilegeScreenEvidence(fileID int64) ([]database.PrivilegeScreenHit, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPrivilegeScreenEvidence(fileID)
+}
+
+// ConfirmPrivilege resolves a queued file as privileged with its log entry
+func (a *App) ConfirmPrivilege(entry database.PrivilegeEntry, reviewer, note string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.ConfirmPrivilege(entry, reviewer, note)
+}
+
+// RejectPrivilege resolves a queued file as not privileged
+func (a *App) RejectPrivilege(fileID int64, reviewer, note string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.RejectPrivilege(fileID, reviewer, note)
+}
+
+// piiRules returns the matter's PII rules from piiRulesPath, or the defaults when it has none
+func piiRules() ([]database.PIIRule, error) {
+	if _, err := os.Stat(piiRulesPath); os.IsNotExist(err) {
+		return database.DefaultPIIRules(), nil
+	}
+	return database.LoadPIIRules(piiRulesPath)
+}
+
+// DetectPII extracts text from any data lake natives not yet read, then runs the matter's PII rules over it
+// Returns the number of files with findings
+func (a *App) DetectPII() (int, error) {
+	rules, err := piiRules()
+	if err != nil {
+		return 0, err
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return 0, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := db.ExtractText(cfg.GetDataLakePath()); err != nil {
+		return 0, err
 	}
+	return db.DetectPII(rules)
+}
+
+// GetPIIFindings returns the PII found in a file
+func (a *App) GetPIIFindings(fileID int64) ([]database.PIIFinding, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPIIFindings(fileID)
+}
+
+// GetPIIOptions returns the PII types with file counts for the filter UI
+func (a *App) GetPIIOptions() ([]database.PIIOption, error) {
+	rules, err := piiRules()
+	if err != nil {
+		return nil, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPIIOptions(rules)
+}
+
+// AddRedaction records a redaction on a file and returns its ID
+func (a *App) AddRedaction(r database.Redaction) (int64, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return 0, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.AddRedaction(r, cfg.GetDataLakePath())
+}
+
+// RemoveRedaction deletes a redaction, recording who removed it
+func (a *App) RemoveRedaction(id int64, actor string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.RemoveRedaction(id, actor)
+}
 
-	return requests, nil
+// GetRedactions returns a file's redactions
+func (a *App) GetRedactions(fileID int64) ([]database.Redaction, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetRedactions(fileID)
+}
+
+// GetRedactionLog returns who added and removed redactions on a file (0 for all files)
+func (a *App) GetRedactionLog(fileID int64) ([]database.RedactionLogEntry, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetRedactionLog(fileID)
+}
+
+// GetPDFPageText returns the page text that PDF redaction offsets refer to
+func (a *App) GetPDFPageText(fileID int64) ([]string, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPDFPageText(fileID, cfg.GetDataLakePath())
 }
 
 // GetCategories returns available categories with counts based on file paths
@@ -235,3 +1158,159 @@ func (a *App) shouldExclude(path string) bool {
 
 	return false
 }
+
+// TrainTopicModel fits a topic model over the corpus; k <= 0 picks the topic count from corpus size
+func (a *App) TrainTopicModel(k int) (*database.TopicModelInfo, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.TrainTopicModel(k)
+}
+
+// GetTopicSummaries returns the latest topic model's topics with document counts
+func (a *App) GetTopicSummaries() ([]database.TopicSummary, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetTopicSummaries()
+}
+
+// GetFileTopics returns a file's topics with their weights
+func (a *App) GetFileTopics(fileID int64) ([]database.FileTopic, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetFileTopics(fileID)
+}
+
+// ExtractText stores the text of data lake files that have none yet, for the topic model
+// and content analysis; files new since the last training are assigned topics
+func (a *App) ExtractText() (int, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return 0, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.ExtractText(cfg.GetDataLakePath())
+}
+
+// issueRules returns the matter's EEO issue rules from issueRulesPath, or the default rules
+func issueRules() ([]database.IssueRule, error) {
+	if _, err := os.Stat(issueRulesPath); os.IsNotExist(err) {
+		return database.DefaultIssueRules(), nil
+	}
+	return database.LoadIssueRules(issueRulesPath)
+}
+
+// ClassifyIssues tags files with the matter's EEO issue codes and returns the number tagged
+func (a *App) ClassifyIssues() (int, error) {
+	rules, err := issueRules()
+	if err != nil {
+		return 0, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.ClassifyIssues(rules)
+}
+
+// GetIssueEvidence returns the issue hits recorded for a file
+func (a *App) GetIssueEvidence(fileID int64) ([]database.IssueHit, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetIssueEvidence(fileID)
+}
+
+// GetIssueOptions returns the issue codes with file counts for the filter UI
+func (a *App) GetIssueOptions() ([]database.IssueOption, error) {
+	rules, err := issueRules()
+	if err != nil {
+		return nil, err
+	}
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetIssueOptions(rules)
+}
+
+// GetClaims returns every claim with its linked document count
+func (a *App) GetClaims() ([]database.Claim, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetClaims()
+}
+
+// CreateClaim registers a claim and returns its ID
+func (a *App) CreateClaim(claim database.Claim) (int64, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.CreateClaim(claim)
+}
+
+// UpdateClaim replaces a claim's fields
+func (a *App) UpdateClaim(claim database.Claim) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.UpdateClaim(claim)
+}
+
+// DeleteClaim removes a claim and its document links
+func (a *App) DeleteClaim(id int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.DeleteClaim(id)
+}
+
+// LinkClaims rebuilds the automatic links between documents and claims
+func (a *App) LinkClaims() (int, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return db.LinkClaims()
+}
+
+// AssignFileToClaim manually links a file to a claim
+func (a *App) AssignFileToClaim(fileID, claimID int64, note string) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.AssignFileToClaim(fileID, claimID, note)
+}
+
+// UnassignFileFromClaim removes a file's link to a claim
+func (a *App) UnassignFileFromClaim(fileID, claimID int64) error {
+	db, err := a.openDatabase()
+	if err != nil {
+		return err
+	}
+	return db.UnassignFileFromClaim(fileID, claimID)
+}
+
+// GetFileClaims returns the claims a file is linked to
+func (a *App) GetFileClaims(fileID int64) ([]database.FileClaim, error) {
+	db, err := a.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return db.GetFileClaims(fileID)
+}
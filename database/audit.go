@@ -0,0 +1,372 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Actions recorded in the chain-of-custody ledger
+const (
+	AuditIngest     = "ingest"          // A file entered the collection
+	AuditHash       = "hash"            // Its content hash was indexed
+	AuditReview     = "review_decision" // A review decision was recorded or cleared
+	AuditRedaction  = "redaction"       // A redaction was added or removed
+	AuditProduction = "production"      // It was produced, or a production was re-created
+)
+
+// AuditActorSystem is the actor for actions the application takes on its own
+const AuditActorSystem = "system"
+
+// auditGenesisHash is the previous hash of the first ledger entry
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// AuditEntry is one row of the append-only audit_log
+// Hash is the SHA-256 of the entry's fields including PrevHash, the previous entry's
+// Hash, so altering, removing or reordering an entry breaks the chain after it
+type AuditEntry struct {
+	ID           int64     `json:"id"`
+	Action       string    `json:"action"`
+	FileID       int64     `json:"file_id"`       // 0 when the entry is not about one document
+	ProductionID int64     `json:"production_id"` // 0 unless the entry is about a production
+	Actor        string    `json:"actor"`
+	Details      string    `json:"details"` // JSON object describing the action
+	At           time.Time `json:"at"`
+	PrevHash     string    `json:"prev_hash"`
+	Hash         string    `json:"hash"`
+}
+
+// AuditVerification is the result of re-computing the ledger's hash chain
+type AuditVerification struct {
+	Valid    bool      `json:"valid"`
+	Entries  int       `json:"entries"`
+	HeadHash string    `json:"head_hash"` // Record it outside the database to detect a truncated ledger
+	Problems []string  `json:"problems"`
+	Checked  time.Time `json:"checked"`
+}
+
+// CustodyReport is the ledger's history of one document or one production
+type CustodyReport struct {
+	FileID       int64             `json:"file_id,omitempty"`
+	ProductionID int64             `json:"production_id,omitempty"`
+	Subject      string            `json:"subject"` // Document path, or request and Bates range
+	GeneratedAt  time.Time         `json:"generated_at"`
+	Ledger       AuditVerification `json:"ledger"` // Integrity of the whole ledger at GeneratedAt
+	Entries      []AuditEntry      `json:"entries"`
+}
+
+// auditWriter is satisfied by *sql.DB and *sql.Tx, so an action and its ledger entry
+// can share a transaction
+type auditWriter interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// appendAudit adds an entry to the end of the ledger
+// The ID is taken from the current last entry, so a concurrent append fails on the
+// primary key instead of forking the chain
+func appendAudit(w auditWriter, action string, fileID, productionID int64, actor string, details map[string]interface{}) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	e := AuditEntry{
+		Action:       action,
+		FileID:       fileID,
+		ProductionID: productionID,
+		Actor:        actor,
+		Details:      string(detailsJSON),
+		At:           time.Now().UTC().Truncate(time.Second),
+		PrevHash:     auditGenesisHash,
+	}
+	err = w.QueryRow("SELECT id, hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&e.ID, &e.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+	e.ID++
+	e.Hash = auditHash(e)
+
+	_, err = w.Exec(`
+		INSERT INTO audit_log (id, action, file_id, production_id, actor, details, at, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ID, e.Action, e.FileID, e.ProductionID, e.Actor, e.Details, e.At.Format(time.RFC3339), e.PrevHash, e.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditHash returns the hex SHA-256 of an entry's fields, encoded as a JSON array so
+// no field can bleed into its neighbour
+func auditHash(e AuditEntry) string {
+	payload, _ := json.Marshal([]interface{}{
+		e.ID, e.PrevHash, e.Action, e.FileID, e.ProductionID, e.Actor, e.Details, e.At.Format(time.RFC3339),
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// backfillCustody records ingest and hash entries for files that have none, as in a
+// matter collected before the audit log existed. The original times were never recorded,
+// so the entries are dated when the backfill runs and marked backfilled; once every file
+// has its entries there is nothing left to do, so it runs once per matter
+func (d *DB) backfillCustody() error {
+	rows, err := d.db.Query(`
+		SELECT f.id, f.path, f.size, f.content_hash,
+		       EXISTS (SELECT 1 FROM audit_log a WHERE a.file_id = f.id AND a.action = ?),
+		       EXISTS (SELECT 1 FROM audit_log a WHERE a.file_id = f.id AND a.action = ?)
+		FROM files f
+		ORDER BY f.id
+	`, AuditIngest, AuditHash)
+	if err != nil {
+		return fmt.Errorf("failed to query files without custody entries: %w", err)
+	}
+	type missing struct {
+		id                     int64
+		path                   string
+		size                   int64
+		hash                   sql.NullString
+		needsIngest, needsHash bool
+	}
+	var pending []missing
+	for rows.Next() {
+		var m missing
+		var ingested, hashed bool
+		if err := rows.Scan(&m.id, &m.path, &m.size, &m.hash, &ingested, &hashed); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan file: %w", err)
+		}
+		m.needsIngest = !ingested
+		m.needsHash = m.hash.Valid && !hashed
+		if m.needsIngest || m.needsHash {
+			pending = append(pending, m)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating files: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	op := logging.StartOperation("backfillCustody", map[string]interface{}{
+		"files": len(pending),
+	})
+	defer op.EndOperation()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, m := range pending {
+		if m.needsIngest {
+			err := appendAudit(tx, AuditIngest, m.id, 0, AuditActorSystem, map[string]interface{}{
+				"path":       m.path,
+				"size":       m.size,
+				"backfilled": true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if m.needsHash {
+			err := appendAudit(tx, AuditHash, m.id, 0, AuditActorSystem, map[string]interface{}{
+				"path":       m.path,
+				"sha256":     m.hash.String,
+				"backfilled": true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit audit backfill: %w", err)
+	}
+	return nil
+}
+
+// VerifyAuditLog re-computes every entry's hash and checks that each links to the one
+// before it, reporting altered, missing and reordered entries
+func (d *DB) VerifyAuditLog() (*AuditVerification, error) {
+	op := logging.StartOperation("VerifyAuditLog", map[string]interface{}{})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to verify the audit log")
+
+	entries, err := d.queryAuditEntries("")
+	if err != nil {
+		return nil, err
+	}
+
+	v := &AuditVerification{
+		Entries:  len(entries),
+		HeadHash: auditGenesisHash,
+		Problems: []string{},
+		Checked:  time.Now().UTC().Truncate(time.Second),
+	}
+	var lastID int64
+	for _, e := range entries {
+		switch {
+		case e.ID == lastID+2:
+			v.Problems = append(v.Problems, fmt.Sprintf("entry %d is missing", lastID+1))
+		case e.ID != lastID+1:
+			v.Problems = append(v.Problems, fmt.Sprintf("entries %d to %d are missing", lastID+1, e.ID-1))
+		}
+		if e.PrevHash != v.HeadHash {
+			v.Problems = append(v.Problems, fmt.Sprintf("entry %d does not link to the entry before it", e.ID))
+		}
+		if auditHash(e) != e.Hash {
+			v.Problems = append(v.Problems, fmt.Sprintf("entry %d (%s) has been altered", e.ID, e.Action))
+		}
+		v.HeadHash = e.Hash
+		lastID = e.ID
+	}
+	v.Valid = len(v.Problems) == 0
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"entries": v.Entries,
+		"valid":   v.Valid,
+	})
+	return v, nil
+}
+
+// queryAuditEntries returns ledger entries matching condition ("" for all) in ledger order
+func (d *DB) queryAuditEntries(condition string, args ...interface{}) ([]AuditEntry, error) {
+	query := "SELECT id, action, file_id, production_id, actor, details, at, prev_hash, hash FROM audit_log"
+	if condition != "" {
+		query += " WHERE " + condition
+	}
+	rows, err := d.db.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var at string
+		if err := rows.Scan(&e.ID, &e.Action, &e.FileID, &e.ProductionID, &e.Actor, &e.Details, &at, &e.PrevHash, &e.Hash); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.At = parseTimestamp(at)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// GetCustodyReport returns the custody history of a document (fileID) or a production
+// (productionID); exactly one must be set. A production's report includes the full
+// history of every document in it
+func (d *DB) GetCustodyReport(fileID, productionID int64) (*CustodyReport, error) {
+	if (fileID == 0) == (productionID == 0) {
+		return nil, fmt.Errorf("a custody report covers either a document or a production")
+	}
+
+	ledger, err := d.VerifyAuditLog()
+	if err != nil {
+		return nil, err
+	}
+	report := &CustodyReport{
+		FileID:       fileID,
+		ProductionID: productionID,
+		GeneratedAt:  ledger.Checked,
+		Ledger:       *ledger,
+	}
+
+	if fileID != 0 {
+		file, err := d.GetFileByID(fileID)
+		if err != nil {
+			return nil, err
+		}
+		report.Subject = file.Path
+		report.Entries, err = d.queryAuditEntries("file_id = ?", fileID)
+		if err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+
+	production, err := d.GetProduction(productionID)
+	if err != nil {
+		return nil, err
+	}
+	if production == nil {
+		return nil, fmt.Errorf("production %d not found", productionID)
+	}
+	report.Subject = fmt.Sprintf("%s production %s - %s", production.ProductionRequestID,
+		production.Documents[0].BegBates, production.Documents[len(production.Documents)-1].EndBates)
+	report.Entries, err = d.queryAuditEntries(
+		"production_id = ? OR file_id IN (SELECT file_id FROM production_documents WHERE production_id = ?)",
+		productionID, productionID)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ExportCustodyReport writes a document's or production's custody report as plain text
+// Each entry is listed with its hash so it can be checked against the ledger later
+func (d *DB) ExportCustodyReport(fileID, productionID int64, outputDir string) (string, error) {
+	report, err := d.GetCustodyReport(fileID, productionID)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Chain-of-custody report: %s\n", report.Subject)
+	fmt.Fprintf(&b, "Generated: %s\n", report.GeneratedAt.Format(time.RFC3339))
+	if report.Ledger.Valid {
+		fmt.Fprintf(&b, "Ledger:    intact (%d entries, head %s)\n", report.Ledger.Entries, report.Ledger.HeadHash)
+	} else {
+		fmt.Fprintf(&b, "Ledger:    TAMPERING DETECTED (%d entries)\n", report.Ledger.Entries)
+		for _, p := range report.Ledger.Problems {
+			fmt.Fprintf(&b, "           %s\n", p)
+		}
+	}
+	fmt.Fprintf(&b, "\n%d entries:\n", len(report.Entries))
+	for _, e := range report.Entries {
+		actor := e.Actor
+		if actor == "" {
+			actor = "(unattributed)"
+		}
+		line := fmt.Sprintf("%6d  %s  %-15s  %-20s", e.ID, e.At.Format(time.RFC3339), e.Action, actor)
+		if e.FileID != 0 && report.FileID == 0 {
+			line += fmt.Sprintf("  file %d", e.FileID)
+		}
+		if e.ProductionID != 0 {
+			line += fmt.Sprintf("  production %d", e.ProductionID)
+		}
+		fmt.Fprintf(&b, "%s\n        %s\n        sha256 %s\n", strings.TrimRight(line, " "), e.Details, e.Hash)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	name := fmt.Sprintf("custody_file_%d.txt", fileID)
+	if productionID != 0 {
+		name = fmt.Sprintf("custody_production_%d.txt", productionID)
+	}
+	path := filepath.Join(outputDir, name)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write custody report: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyAuditLogDetectsTampering(t *testing.T) {
+	d := newTestDB(t)
+	v, err := d.VerifyAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Valid || v.Entries == 0 {
+		t.Fatalf("fresh ledger: valid %v with %d entries, problems %v", v.Valid, v.Entries, v.Problems)
+	}
+
+	// The triggers keep the application honest; tampering goes around them
+	if _, err := d.db.Exec("UPDATE audit_log SET actor = 'mallory' WHERE id = 2"); err == nil {
+		t.Fatal("audit_log accepted an update")
+	}
+	if _, err := d.db.Exec("DELETE FROM audit_log WHERE id = 2"); err == nil {
+		t.Fatal("audit_log accepted a delete")
+	}
+	mustExec(t, d, "DROP TRIGGER audit_log_no_update")
+	mustExec(t, d, "DROP TRIGGER audit_log_no_delete")
+	mustExec(t, d, "UPDATE audit_log SET actor = 'mallory' WHERE id = 2")
+	mustExec(t, d, "DELETE FROM audit_log WHERE id = 4")
+
+	v, err = d.VerifyAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"entry 2 (ingest) has been altered",
+		"entry 4 is missing",
+		"entry 5 does not link to the entry before it",
+	}
+	if v.Valid || strings.Join(v.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems = %q, want %q", v.Problems, want)
+	}
+}
+
+func TestClearReviewDecisionRecordsReviewer(t *testing.T) {
+	d := newTestDB(t)
+	id := firstFileIDs(t, d, 1)[0]
+	if err := d.RecordReviewDecision(id, "PR-001", DecisionResponsive, "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ClearReviewDecision(id, "PR-001", " "); err == nil {
+		t.Error("a decision was cleared without a reviewer")
+	}
+	if err := d.ClearReviewDecision(id, "PR-001", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := d.GetCustodyReport(id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := report.Entries[len(report.Entries)-1]
+	if last.Action != AuditReview || last.Actor != "bob" || !strings.Contains(last.Details, `"cleared"`) {
+		t.Errorf("last entry is %s by %q (%s), want the clearing by bob", last.Action, last.Actor, last.Details)
+	}
+}
+
+func TestBackfillCustody(t *testing.T) {
+	d := newTestDB(t)
+	// A file collected and hashed before the ledger existed
+	mustExec(t, d, `INSERT INTO files (path, directory, category, date, size, duplicate_hash, file_name,
+		                                   subject, from_email, to_email, sentiment, topic, content_hash)
+		VALUES ('Old/legacy.pdf', 'Old', 'other', '2021-01-01T00:00:00Z', 10, 'h', 'legacy.pdf', '', '', '', '', '', 'abc')`)
+	var id int64
+	if err := d.db.QueryRow("SELECT id FROM files WHERE path = 'Old/legacy.pdf'").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.backfillCustody(); err != nil {
+		t.Fatal(err)
+	}
+	report, err := d.GetCustodyReport(id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Entries) != 2 || report.Entries[0].Action != AuditIngest || report.Entries[1].Action != AuditHash {
+		t.Fatalf("backfilled entries = %+v, want ingest then hash", report.Entries)
+	}
+	for _, e := range report.Entries {
+		if !strings.Contains(e.Details, `"backfilled":true`) {
+			t.Errorf("%s entry is not marked backfilled: %s", e.Action, e.Details)
+		}
+	}
+	if !report.Ledger.Valid {
+		t.Errorf("ledger invalid after backfill: %v", report.Ledger.Problems)
+	}
+
+	// Running again finds nothing missing
+	before := report.Ledger.Entries
+	if err := d.backfillCustody(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := d.VerifyAuditLog(); v.Entries != before {
+		t.Errorf("second backfill added %d entries", v.Entries-before)
+	}
+}
+
+func TestRedactionsAreAudited(t *testing.T) {
+	d := newTestDB(t)
+	id := firstFileIDs(t, d, 1)[0]
+	root := textDocument(t, d, id, "Call me at 555-0100 about my diagnosis.")
+	redaction, err := d.AddRedaction(Redaction{FileID: id, Start: 29, End: 38, ReasonCode: RedactionMedical, RedactedBy: "lee"}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RemoveRedaction(redaction, "pat"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each redaction log entry is in the ledger under its actor, without the redacted text
+	entries, err := d.queryAuditEntries("action = ? AND file_id = ?", AuditRedaction, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Actor != "lee" || entries[1].Actor != "pat" {
+		t.Fatalf("got %d redaction audit entries, want lee's addition and pat's removal", len(entries))
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Details, "diagnosis") {
+			t.Errorf("audit entry leaks redacted text: %s", e.Details)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Batch statuses
+const (
+	BatchStatusAvailable  = "available"   // Waiting for a reviewer
+	BatchStatusCheckedOut = "checked_out" // Held by Reviewer
+	BatchStatusCompleted  = "completed"   // Checked in with every file reviewed
+)
+
+// DefaultBatchSize is used when CreateBatches is given no size
+const DefaultBatchSize = 100
+
+// ReviewBatch is a set of files from one saved search, reviewed by one reviewer at a time
+type ReviewBatch struct {
+	ID                  int64      `json:"id"`
+	Name                string     `json:"name"` // e.g. "PR-004-B007"
+	SavedSearchID       int64      `json:"saved_search_id"`
+	RunID               int64      `json:"run_id"` // Saved search run the files came from
+	ProductionRequestID string     `json:"production_request_id"`
+	Status              string     `json:"status"`
+	Reviewer            string     `json:"reviewer"` // Current holder, or the last one after check-in
+	FileCount           int        `json:"file_count"`
+	ReviewedCount       int        `json:"reviewed_count"`
+	PercentComplete     float64    `json:"percent_complete"`
+	CheckedOutAt        *time.Time `json:"checked_out_at"`
+	CheckedInAt         *time.Time `json:"checked_in_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// groupCandidate is a file considered for family and thread grouping
+type groupCandidate struct {
+	id           int64
+	path         string
+	category     string
+	subject      string
+	participants []string // Lower-cased sender and recipient addresses; only needed for threads
+	date         time.Time
+}
+
+// threadWindow is the longest gap between consecutive messages of one thread
+// Without it a generic subject ("Update") would chain months of unrelated mail together
+const threadWindow = 14 * 24 * time.Hour
+
+// emailParticipants returns the distinct lower-cased addresses in sender and recipient fields
+// Recipient lists may be separated by commas or semicolons
+func emailParticipants(fields ...string) []string {
+	seen := make(map[string]bool)
+	var participants []string
+	for _, field := range fields {
+		for _, addr := range strings.FieldsFunc(field, func(r rune) bool { return r == ',' || r == ';' }) {
+			addr = strings.ToLower(strings.TrimSpace(addr))
+			if addr != "" && !seen[addr] {
+				seen[addr] = true
+				participants = append(participants, addr)
+			}
+		}
+	}
+	return participants
+}
+
+// threadPrefixRegex matches reply and forward markers at the start of a subject
+var threadPrefixRegex = regexp.MustCompile(`(?i)^\s*((re|fw|fwd)\s*(\[\d+\])?\s*:\s*)+`)
+
+// normalizeThreadSubject reduces an email subject to the part shared by its thread
+func normalizeThreadSubject(subject string) string {
+	subject = threadPrefixRegex.ReplaceAllString(subject, "")
+	return strings.ToLower(strings.Join(strings.Fields(subject), " "))
+}
+
+// CreateBatches splits a saved search's current results into review batches of at most maxSize files
+// The search is re-run first; files already batched for the same production request are skipped,
+// so calling it again after new documents arrive only batches the new ones
+// Families and threads stay in one batch unless a single group is larger than maxSize,
+// which is then split across consecutive batches
+func (d *DB) CreateBatches(searchID int64, maxSize int) ([]ReviewBatch, error) {
+	op := logging.StartOperation("CreateBatches", map[string]interface{}{
+		"search_id": searchID,
+		"max_size":  maxSize,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to create batches")
+
+	if maxSize <= 0 {
+		maxSize = DefaultBatchSize
+	}
+
+	diff, err := d.RunSavedSearch(searchID)
+	if err != nil {
+		return nil, err
+	}
+	search, err := d.GetSavedSearch(searchID)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := d.GetSearchRunFileIDs(diff.Run.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := d.loadBatchCandidates(diff.Run.ID, search.ProductionRequestID, ids)
+	if err != nil {
+		return nil, err
+	}
+	groups := groupCandidates(candidates, true)
+	batches := packBatches(groups, maxSize)
+	if len(batches) == 0 {
+		return nil, nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var number int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(batch_number), 0) FROM review_batches WHERE production_request_id = ?",
+		search.ProductionRequestID).Scan(&number); err != nil {
+		return nil, fmt.Errorf("failed to get last batch number: %w", err)
+	}
+
+	fileStmt, err := tx.Prepare("INSERT INTO review_batch_files (batch_id, file_id, position) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare batch file insert: %w", err)
+	}
+	defer fileStmt.Close()
+
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	batchIDs := make([]int64, 0, len(batches))
+	for _, fileIDs := range batches {
+		number++
+		name := fmt.Sprintf("%s-B%03d", search.ProductionRequestID, number)
+		result, err := tx.Exec(`
+			INSERT INTO review_batches (name, batch_number, saved_search_id, run_id, production_request_id, status, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, name, number, searchID, diff.Run.ID, search.ProductionRequestID, BatchStatusAvailable, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch %s: %w", name, err)
+		}
+		batchID, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get batch id: %w", err)
+		}
+		for position, fileID := range fileIDs {
+			if _, err := fileStmt.Exec(batchID, fileID, position); err != nil {
+				return nil, fmt.Errorf("failed to add file %d to batch %s: %w", fileID, name, err)
+			}
+		}
+		batchIDs = append(batchIDs, batchID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit batches: %w", err)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batchIDs)), ",")
+	args := make([]interface{}, len(batchIDs))
+	for i, id := range batchIDs {
+		args[i] = id
+	}
+	return d.queryBatches("b.id IN ("+placeholders+")", args...)
+}
+
+// loadBatchCandidates returns the run's files not yet batched for the request, in ids order
+func (d *DB) loadBatchCandidates(runID int64, requestID string, ids []int64) ([]groupCandidate, error) {
+	rows, err := d.db.Query(`
+		SELECT id, path, category, subject, from_email, to_email, date
+		FROM files
+		WHERE id IN (SELECT file_id FROM saved_search_run_files WHERE run_id = ?)
+		  AND id NOT IN (
+			SELECT bf.file_id FROM review_batch_files bf
+			JOIN review_batches b ON b.id = bf.batch_id
+			WHERE b.production_request_id = ?)
+	`, runID, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch candidates: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]groupCandidate)
+	for rows.Next() {
+		var c groupCandidate
+		var subject, fromEmail, toEmail sql.NullString
+		var date string
+		if err := rows.Scan(&c.id, &c.path, &c.category, &subject, &fromEmail, &toEmail, &date); err != nil {
+			return nil, fmt.Errorf("failed to scan batch candidate: %w", err)
+		}
+		c.subject = subject.String
+		c.participants = emailParticipants(fromEmail.String, toEmail.String)
+		c.date = parseTimestamp(date)
+		byID[c.id] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batch candidates: %w", err)
+	}
+
+	candidates := make([]groupCandidate, 0, len(byID))
+	for _, id := range ids {
+		if c, ok := byID[id]; ok {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates, nil
+}
+
+// groupCandidates partitions candidates into families, merged with their threads if withThreads
+// Thread: emails with the same subject once Re:/Fwd: prefixes are removed that share a
+// participant, each sent within threadWindow of the previous one
+// Family: files stored under a directory named after another file without its extension,
+// e.g. "Inbox/msg_12/invoice.pdf" belongs with "Inbox/msg_12.eml"
+// Groups keep the order of their first member, and members keep candidate order
+func groupCandidates(candidates []groupCandidate, withThreads bool) [][]int64 {
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	union := func(a, b int) {
+		ra, rb := find(a), find(b)
+		if ra == rb {
+			return
+		}
+		// Keep the earliest member as root so groups stay in result order
+		if ra < rb {
+			parent[rb] = ra
+		} else {
+			parent[ra] = rb
+		}
+	}
+
+	threads := make(map[string][]int) // Subject and participant to messages
+	stems := make(map[string]int)
+	for i, c := range candidates {
+		if withThreads && c.category == "email" {
+			if subject := normalizeThreadSubject(c.subject); subject != "" {
+				for _, p := range c.participants {
+					key := subject + "\x00" + p
+					threads[key] = append(threads[key], i)
+				}
+			}
+		}
+		stem := strings.TrimSuffix(c.path, filepath.Ext(c.path))
+		if _, ok := stems[stem]; !ok {
+			stems[stem] = i
+		}
+	}
+	for _, messages := range threads {
+		sort.SliceStable(messages, func(a, b int) bool {
+			return candidates[messages[a]].date.Before(candidates[messages[b]].date)
+		})
+		for k := 1; k < len(messages); k++ {
+			if candidates[messages[k]].date.Sub(candidates[messages[k-1]].date) <= threadWindow {
+				union(messages[k-1], messages[k])
+			}
+		}
+	}
+	for i, c := range candidates {
+		for dir := filepath.Dir(c.path); dir != "." && dir != "/" && dir != ""; dir = filepath.Dir(dir) {
+			if j, ok := stems[dir]; ok && j != i {
+				union(i, j)
+				break
+			}
+		}
+	}
+
+	index := make(map[int]int)
+	var groups [][]int64
+	for i, c := range candidates {
+		root := find(i)
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], c.id)
+	}
+	return groups
+}
+
+// packBatches fills batches with whole groups in order, starting a new batch
+// when the next group would push the current one past maxSize
+// A group larger than maxSize is split into maxSize pieces, so no batch is oversized
+func packBatches(groups [][]int64, maxSize int) [][]int64 {
+	var split [][]int64
+	for _, g := range groups {
+		for len(g) > maxSize {
+			split = append(split, g[:maxSize])
+			g = g[maxSize:]
+		}
+		split = append(split, g)
+	}
+
+	var batches [][]int64
+	var current []int64
+	for _, g := range split {
+		if len(current) > 0 && len(current)+len(g) > maxSize {
+			batches = append(batches, current)
+			current = nil
+		}
+		current = append(current, g...)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// GetBatches returns the review batches of a production request with their progress
+func (d *DB) GetBatches(requestID string) ([]ReviewBatch, error) {
+	return d.queryBatches("b.production_request_id = ?", requestID)
+}
+
+// GetBatch returns a single review batch with its progress
+func (d *DB) GetBatch(id int64) (*ReviewBatch, error) {
+	batches, err := d.queryBatches("b.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("batch %d not found", id)
+	}
+	return &batches[0], nil
+}
+
+// queryBatches loads batches matching a WHERE condition on review_batches b
+// A file counts as reviewed once it has a decision for the batch's production request
+func (d *DB) queryBatches(condition string, args ...interface{}) ([]ReviewBatch, error) {
+	rows, err := d.db.Query(`
+		SELECT b.id, b.name, b.saved_search_id, b.run_id, b.production_request_id, b.status, b.reviewer,
+		       b.checked_out_at, b.checked_in_at, b.created_at,
+		       COUNT(bf.file_id), COUNT(rd.file_id)
+		FROM review_batches b
+		LEFT JOIN review_batch_files bf ON bf.batch_id = b.id
+		LEFT JOIN review_decisions rd ON rd.file_id = bf.file_id AND rd.production_request_id = b.production_request_id
+		WHERE `+condition+`
+		GROUP BY b.id
+		ORDER BY b.production_request_id, b.batch_number
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batches: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []ReviewBatch
+	for rows.Next() {
+		var b ReviewBatch
+		var checkedOutAt, checkedInAt sql.NullString
+		var createdAt string
+		if err := rows.Scan(&b.ID, &b.Name, &b.SavedSearchID, &b.RunID, &b.ProductionRequestID, &b.Status, &b.Reviewer,
+			&checkedOutAt, &checkedInAt, &createdAt, &b.FileCount, &b.ReviewedCount); err != nil {
+			return nil, fmt.Errorf("failed to scan batch: %w", err)
+		}
+		if b.CheckedOutAt, err = parseOptionalDate(checkedOutAt); err != nil {
+			return nil, err
+		}
+		if b.CheckedInAt, err = parseOptionalDate(checkedInAt); err != nil {
+			return nil, err
+		}
+		b.CreatedAt = parseTimestamp(createdAt)
+		if b.FileCount > 0 {
+			b.PercentComplete = 100 * float64(b.ReviewedCount) / float64(b.FileCount)
+		}
+		batches = append(batches, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batches: %w", err)
+	}
+	return batches, nil
+}
+
+// CheckOutBatch assigns an available batch to a reviewer
+// ASSUMPTION: One reviewer holds a batch at a time
+func (d *DB) CheckOutBatch(id int64, reviewer string) (*ReviewBatch, error) {
+	reviewer = strings.TrimSpace(reviewer)
+	if reviewer == "" {
+		return nil, fmt.Errorf("reviewer is required")
+	}
+
+	batch, err := d.GetBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	switch batch.Status {
+	case BatchStatusCheckedOut:
+		if batch.Reviewer == reviewer {
+			return batch, nil
+		}
+		return nil, fmt.Errorf("batch %s is checked out by %s", batch.Name, batch.Reviewer)
+	case BatchStatusCompleted:
+		return nil, fmt.Errorf("batch %s is already completed", batch.Name)
+	}
+
+	result, err := d.db.Exec(`
+		UPDATE review_batches SET status = ?, reviewer = ?, checked_out_at = ?, checked_in_at = NULL
+		WHERE id = ? AND status = ?
+	`, BatchStatusCheckedOut, reviewer, time.Now().UTC().Format(time.RFC3339), id, BatchStatusAvailable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check out batch: %w", err)
+	}
+	// Another reviewer may have taken it between the read and the update
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("batch %s is no longer available", batch.Name)
+	}
+	return d.GetBatch(id)
+}
+
+// CheckInBatch returns a batch held by reviewer
+// The batch is completed when every file has a decision, otherwise it becomes available again
+func (d *DB) CheckInBatch(id int64, reviewer string) (*ReviewBatch, error) {
+	batch, err := d.GetBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status != BatchStatusCheckedOut || batch.Reviewer != strings.TrimSpace(reviewer) {
+		return nil, fmt.Errorf("batch %s is not checked out by %s", batch.Name, reviewer)
+	}
+
+	status := BatchStatusAvailable
+	if batch.ReviewedCount == batch.FileCount {
+		status = BatchStatusCompleted
+	}
+	_, err = d.db.Exec("UPDATE review_batches SET status = ?, checked_in_at = ? WHERE id = ?",
+		status, time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check in batch: %w", err)
+	}
+	return d.GetBatch(id)
+}
+
+// GetBatchFiles returns one page of a batch's files with their review decisions
+// It is SearchFiles restricted to the batch, so paging works as in the main file list
+func (d *DB) GetBatchFiles(id int64, page, pageSize int) (*FileResult, error) {
+	batch, err := d.GetBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.SearchFiles(FileFilters{
+		ProductionRequestID: batch.ProductionRequestID,
+		BatchID:             id,
+		Page:                page,
+		PageSize:            pageSize,
+	})
+}
+
+// DeleteBatch removes a batch that no reviewer has started, returning its files to the unbatched pool
+func (d *DB) DeleteBatch(id int64) error {
+	batch, err := d.GetBatch(id)
+	if err != nil {
+		return err
+	}
+	if batch.Status != BatchStatusAvailable || batch.CheckedOutAt != nil {
+		return fmt.Errorf("batch %s has been checked out and cannot be deleted", batch.Name)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM review_batch_files WHERE batch_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete batch files: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM review_batches WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete batch: %w", err)
+	}
+	return tx.Commit()
+}
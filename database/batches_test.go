@@ -0,0 +1,91 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGroupCandidatesThreads(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n) }
+	email := func(id int64, subject, from, to string, date time.Time) groupCandidate {
+		return groupCandidate{
+			id:           id,
+			path:         "mail/" + string(rune('a'+id)) + ".eml",
+			category:     "email",
+			subject:      subject,
+			participants: emailParticipants(from, to),
+			date:         date,
+		}
+	}
+	candidates := []groupCandidate{
+		email(1, "Update", "ann@a.com", "bob@b.com", day(0)),
+		email(2, "RE: Update", "bob@b.com", "ann@a.com", day(2)),
+		email(3, "Update", "carl@c.com", "dee@d.com", day(1)),       // Same subject, other people
+		email(4, "Fwd: Update", "ann@a.com", "eve@e.com", day(200)), // Same sender, months later
+		email(5, "Re: Update", "eve@e.com", "ANN@a.com", day(205)),
+	}
+
+	got := groupCandidates(candidates, true)
+	want := [][]int64{{1, 2}, {3}, {4, 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+	if got := groupCandidates(candidates, false); len(got) != len(candidates) {
+		t.Errorf("without threads got %d groups, want %d", len(got), len(candidates))
+	}
+}
+
+func TestEmailParticipants(t *testing.T) {
+	got := emailParticipants("Ann@A.com", "bob@b.com; ann@a.com, carl@c.com")
+	want := []string{"ann@a.com", "bob@b.com", "carl@c.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("participants = %v, want %v", got, want)
+	}
+}
+
+func TestPackBatchesSplitsOversizeGroups(t *testing.T) {
+	groups := [][]int64{{1, 2}, {3, 4, 5, 6, 7}, {8}}
+	got := packBatches(groups, 3)
+	want := [][]int64{{1, 2}, {3, 4, 5}, {6, 7, 8}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+	for _, b := range got {
+		if len(b) > 3 {
+			t.Errorf("batch %v exceeds the maximum size", b)
+		}
+	}
+}
+
+func TestDeleteSavedSearchRefusesBatchedSearch(t *testing.T) {
+	d := newTestDB(t)
+	search, err := d.SaveSearch("Emails", FileFilters{ProductionRequestID: "PR-001", Categories: []string{"email"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches, err := d.CreateBatches(search.ID, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) == 0 {
+		t.Fatal("no batches created")
+	}
+
+	if err := d.DeleteSavedSearch(search.ID); err == nil || !strings.Contains(err.Error(), "review batches") {
+		t.Fatalf("got %v, want a review batches error", err)
+	}
+	if _, err := d.GetSavedSearch(search.ID); err != nil {
+		t.Errorf("refused delete still removed the search: %v", err)
+	}
+
+	for _, b := range batches {
+		if err := d.DeleteBatch(b.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.DeleteSavedSearch(search.ID); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bates numbering defaults for a prefix used before it is configured
+const (
+	DefaultBatesPadding = 7
+	DefaultBatesStart   = 1
+	maxBatesPadding     = 12
+)
+
+// batesPrefixRegex limits prefixes to characters that are safe in file names
+var batesPrefixRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// pdfPageRegex matches page objects in a PDF; "/Type /Pages" tree nodes are excluded
+// Used only to estimate pages when the page tree cannot be read
+var pdfPageRegex = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// BatesSequence is the numbering state for one Bates prefix in the matter
+// NextNumber only moves forward, so numbers are never reused across productions
+type BatesSequence struct {
+	Prefix      string    `json:"prefix"`
+	Padding     int       `json:"padding"`      // Digits after the prefix, zero-filled
+	StartNumber int64     `json:"start_number"` // First number the sequence was configured with
+	NextNumber  int64     `json:"next_number"`  // Next number to assign
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// FormatBates renders a Bates number, e.g. FormatBates("DOI", 7, 42) is "DOI0000042"
+func FormatBates(prefix string, padding int, number int64) string {
+	return fmt.Sprintf("%s%0*d", prefix, padding, number)
+}
+
+// validateBatesPrefix checks that a prefix can be used in Bates numbers and file names
+func validateBatesPrefix(prefix string) error {
+	if !batesPrefixRegex.MatchString(prefix) {
+		return fmt.Errorf("invalid Bates prefix %q: use letters, digits, '-' or '_'", prefix)
+	}
+	return nil
+}
+
+// SetBatesSequence configures the padding and start number for a prefix
+// A sequence that has already numbered documents cannot be moved back below its
+// next number, and its padding cannot shrink below the digits already in use
+func (d *DB) SetBatesSequence(prefix string, padding int, startNumber int64) error {
+	prefix = strings.TrimSpace(prefix)
+	if err := validateBatesPrefix(prefix); err != nil {
+		return err
+	}
+	if padding < 1 || padding > maxBatesPadding {
+		return fmt.Errorf("Bates padding must be between 1 and %d", maxBatesPadding)
+	}
+	if startNumber < 0 {
+		return fmt.Errorf("Bates start number cannot be negative")
+	}
+
+	existing, err := d.GetBatesSequence(prefix)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.NextNumber > existing.StartNumber {
+		if startNumber < existing.NextNumber {
+			return fmt.Errorf("%s has already assigned numbers up to %s; start must be at least %d",
+				prefix, FormatBates(prefix, existing.Padding, existing.NextNumber-1), existing.NextNumber)
+		}
+		if padding < len(strconv.FormatInt(existing.NextNumber-1, 10)) {
+			return fmt.Errorf("padding %d is too small for numbers already assigned under %s", padding, prefix)
+		}
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO bates_sequences (prefix, padding, start_number, next_number, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(prefix) DO UPDATE SET
+			padding = excluded.padding,
+			start_number = CASE WHEN next_number > start_number THEN start_number ELSE excluded.start_number END,
+			next_number = excluded.next_number,
+			updated_at = excluded.updated_at
+	`, prefix, padding, startNumber, startNumber, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save Bates sequence: %w", err)
+	}
+	return nil
+}
+
+// GetBatesSequence returns the sequence for a prefix, or nil if it was never configured or used
+func (d *DB) GetBatesSequence(prefix string) (*BatesSequence, error) {
+	var seq BatesSequence
+	var updatedAt string
+	err := d.db.QueryRow(`
+		SELECT prefix, padding, start_number, next_number, updated_at
+		FROM bates_sequences WHERE prefix = ?
+	`, prefix).Scan(&seq.Prefix, &seq.Padding, &seq.StartNumber, &seq.NextNumber, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Bates sequence: %w", err)
+	}
+	seq.UpdatedAt = parseTimestamp(updatedAt)
+	return &seq, nil
+}
+
+// GetBatesSequences returns every Bates sequence in the matter
+func (d *DB) GetBatesSequences() ([]BatesSequence, error) {
+	rows, err := d.db.Query(`
+		SELECT prefix, padding, start_number, next_number, updated_at
+		FROM bates_sequences ORDER BY prefix
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Bates sequences: %w", err)
+	}
+	defer rows.Close()
+
+	var sequences []BatesSequence
+	for rows.Next() {
+		var seq BatesSequence
+		var updatedAt string
+		if err := rows.Scan(&seq.Prefix, &seq.Padding, &seq.StartNumber, &seq.NextNumber, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan Bates sequence: %w", err)
+		}
+		seq.UpdatedAt = parseTimestamp(updatedAt)
+		sequences = append(sequences, seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating Bates sequences: %w", err)
+	}
+	return sequences, nil
+}
+
+// reserveBates claims count consecutive numbers for a prefix and returns the sequence
+// as it was before the claim, so NextNumber is the first reserved number
+// An unconfigured prefix starts at DefaultBatesStart with DefaultBatesPadding
+func (d *DB) reserveBates(prefix string, count int64) (BatesSequence, error) {
+	if err := validateBatesPrefix(prefix); err != nil {
+		return BatesSequence{}, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return BatesSequence{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(`
+		INSERT INTO bates_sequences (prefix, padding, start_number, next_number, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(prefix) DO NOTHING
+	`, prefix, DefaultBatesPadding, DefaultBatesStart, DefaultBatesStart, now); err != nil {
+		return BatesSequence{}, fmt.Errorf("failed to create Bates sequence: %w", err)
+	}
+
+	seq := BatesSequence{Prefix: prefix}
+	if err := tx.QueryRow("SELECT padding, start_number, next_number FROM bates_sequences WHERE prefix = ?", prefix).
+		Scan(&seq.Padding, &seq.StartNumber, &seq.NextNumber); err != nil {
+		return BatesSequence{}, fmt.Errorf("failed to read Bates sequence: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE bates_sequences SET next_number = ?, updated_at = ? WHERE prefix = ?",
+		seq.NextNumber+count, now, prefix); err != nil {
+		return BatesSequence{}, fmt.Errorf("failed to advance Bates sequence: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return BatesSequence{}, fmt.Errorf("failed to commit Bates reservation: %w", err)
+	}
+	return seq, nil
+}
+
+// releaseBates hands back a reservation from a production that failed
+// Only the most recent reservation can be released; otherwise the gap is left in place
+func (d *DB) releaseBates(prefix string, first, count int64) error {
+	_, err := d.db.Exec("UPDATE bates_sequences SET next_number = ? WHERE prefix = ? AND next_number = ?",
+		first, prefix, first+count)
+	if err != nil {
+		return fmt.Errorf("failed to release Bates numbers: %w", err)
+	}
+	return nil
+}
+
+// pageCount returns how many Bates numbers a document needs
+// PDFs get one per page in the page tree; natives are endorsed as a single page
+// A PDF whose page tree cannot be read (compressed object streams, damage) is counted
+// by matching page objects instead, and estimated is set so the count can be flagged
+func pageCount(path string) (pages int64, estimated bool, err error) {
+	if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return 1, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+	if pdf, err := parsePDF(data); err == nil {
+		if tree, err := pdf.pages(); err == nil && len(tree) > 0 {
+			return int64(len(tree)), false, nil
+		}
+	}
+	pages = int64(len(pdfPageRegex.FindAllIndex(data, -1)))
+	if pages == 0 || !bytes.HasPrefix(data, []byte("%PDF")) {
+		return 1, true, nil
+	}
+	return pages, true, nil
+}
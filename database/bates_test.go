@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReserveAndReleaseBates(t *testing.T) {
+	d := newTestDB(t)
+	first, err := d.reserveBates("ABC", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.reserveBates("ABC", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.NextNumber != DefaultBatesStart || second.NextNumber != DefaultBatesStart+5 {
+		t.Fatalf("reservations start at %d and %d, want %d and %d",
+			first.NextNumber, second.NextNumber, DefaultBatesStart, DefaultBatesStart+5)
+	}
+
+	// Releasing an earlier reservation would hand out numbers twice, so it leaves a gap
+	if err := d.releaseBates("ABC", first.NextNumber, 5); err != nil {
+		t.Fatal(err)
+	}
+	seq, _ := d.GetBatesSequence("ABC")
+	if seq.NextNumber != DefaultBatesStart+8 {
+		t.Errorf("after releasing an earlier reservation next is %d, want %d", seq.NextNumber, DefaultBatesStart+8)
+	}
+	if err := d.releaseBates("ABC", second.NextNumber, 3); err != nil {
+		t.Fatal(err)
+	}
+	seq, _ = d.GetBatesSequence("ABC")
+	if seq.NextNumber != DefaultBatesStart+5 {
+		t.Errorf("after releasing the latest reservation next is %d, want %d", seq.NextNumber, DefaultBatesStart+5)
+	}
+}
+
+func TestFailedProductionReleasesBates(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("a"), ids[1]: onePagePDF("b")})
+	f, _ := d.GetFileByID(ids[1])
+	original, _ := os.ReadFile(sourcePath(root, f.Path))
+	if err := os.WriteFile(sourcePath(root, f.Path), onePagePDF("altered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.CreateZipFile(context.Background(), "PR-001", ids, root, t.TempDir(), ProductionSettings{}, nil)
+	var mismatch *ZipVerificationError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want a verification error", err)
+	}
+
+	if err := os.WriteFile(sourcePath(root, f.Path), original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := produce(t, d, ids, root, ProductionSettings{})
+	if want := FormatBates(DefaultBatesPrefix, DefaultBatesPadding, DefaultBatesStart); out.Documents[0].BegBates != want {
+		t.Errorf("production after a failed one begins at %s, want %s", out.Documents[0].BegBates, want)
+	}
+}
+
+func TestPageCountReadsPageTree(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// The document information mentions "/Type /Page", which matching page objects would count
+	tree := write("tree.pdf", buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Title (/Type /Page) >>",
+	}, "/Info 6 0 R"))
+	if pages, estimated, err := pageCount(tree); err != nil || pages != 3 || estimated {
+		t.Errorf("page tree count = %d (estimated %v, %v), want 3 exact", pages, estimated, err)
+	}
+
+	// Compressed object streams hide the page tree, so the count is an estimate
+	compressed := write("compressed.pdf", buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /ObjStm /N 0 /First 0 /Length 0 >>\nstream\n\nendstream",
+	}, ""))
+	if pages, estimated, err := pageCount(compressed); err != nil || pages != 2 || !estimated {
+		t.Errorf("compressed count = %d (estimated %v, %v), want an estimate of 2", pages, estimated, err)
+	}
+
+	native := write("memo.docx", []byte("PK"))
+	if pages, estimated, err := pageCount(native); err != nil || pages != 1 || estimated {
+		t.Errorf("native count = %d (estimated %v, %v), want 1 exact", pages, estimated, err)
+	}
+}
@@ -0,0 +1,365 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Link methods record how a file came to be associated with a claim
+const (
+	ClaimLinkPath   = "path"   // File path contains one of the claim's path fragments
+	ClaimLinkDocket = "docket" // Docket number found in the path or document text
+	ClaimLinkManual = "manual" // Assigned by a reviewer; never removed by re-linking
+)
+
+// Claim is a filed EEO claim that documents can be linked to
+type Claim struct {
+	ID            int64      `json:"id"`
+	DocketNumber  string     `json:"docket_number"` // e.g. "DOI-OS-23-0456"
+	FilingDate    *time.Time `json:"filing_date"`
+	Description   string     `json:"description"`
+	Issues        []string   `json:"issues"`         // EEO issue codes (see IssueRule)
+	PathFragments []string   `json:"path_fragments"` // Case-insensitive path substrings linking files
+	DocumentCount int        `json:"document_count"`
+}
+
+// FileClaim is a claim linked to a file, with the reason for the link
+type FileClaim struct {
+	ClaimID      int64  `json:"claim_id"`
+	DocketNumber string `json:"docket_number"`
+	Method       string `json:"method"`
+	Evidence     string `json:"evidence"`
+}
+
+// CreateClaim registers a claim and returns its ID
+// ASSUMPTION: Docket numbers are unique across the matter
+func (d *DB) CreateClaim(claim Claim) (int64, error) {
+	// ASSUMPTION: Every claim is identified by a docket number
+	if err := validateDocketNumber(claim.DocketNumber); err != nil {
+		return 0, err
+	}
+
+	issuesJSON, fragmentsJSON, err := encodeClaimLists(claim)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO claims (docket_number, filing_date, description, issues, path_fragments)
+		VALUES (?, ?, ?, ?, ?)
+	`, strings.TrimSpace(claim.DocketNumber), formatOptionalDate(claim.FilingDate), claim.Description, issuesJSON, fragmentsJSON)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create claim: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// UpdateClaim replaces a claim's fields; links are left untouched until the next LinkClaims
+func (d *DB) UpdateClaim(claim Claim) error {
+	if err := validateDocketNumber(claim.DocketNumber); err != nil {
+		return err
+	}
+
+	issuesJSON, fragmentsJSON, err := encodeClaimLists(claim)
+	if err != nil {
+		return err
+	}
+
+	result, err := d.db.Exec(`
+		UPDATE claims
+		SET docket_number = ?, filing_date = ?, description = ?, issues = ?, path_fragments = ?
+		WHERE id = ?
+	`, strings.TrimSpace(claim.DocketNumber), formatOptionalDate(claim.FilingDate), claim.Description, issuesJSON, fragmentsJSON, claim.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update claim: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("claim %d not found", claim.ID)
+	}
+	return nil
+}
+
+// DeleteClaim removes a claim and all of its document links
+func (d *DB) DeleteClaim(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM file_claims WHERE claim_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete claim links: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM claims WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete claim: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("claim %d not found", id)
+	}
+	return tx.Commit()
+}
+
+// GetClaims returns all claims with their linked document counts, oldest filing first
+func (d *DB) GetClaims() ([]Claim, error) {
+	rows, err := d.db.Query(`
+		SELECT c.id, c.docket_number, c.filing_date, c.description, c.issues, c.path_fragments,
+		       COUNT(fc.file_id)
+		FROM claims c
+		LEFT JOIN file_claims fc ON fc.claim_id = c.id
+		GROUP BY c.id
+		ORDER BY c.filing_date IS NULL, c.filing_date, c.docket_number
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query claims: %w", err)
+	}
+	defer rows.Close()
+
+	var claims []Claim
+	for rows.Next() {
+		var c Claim
+		var filingDate sql.NullString
+		var issuesJSON, fragmentsJSON string
+		if err := rows.Scan(&c.ID, &c.DocketNumber, &filingDate, &c.Description, &issuesJSON, &fragmentsJSON, &c.DocumentCount); err != nil {
+			return nil, fmt.Errorf("failed to scan claim: %w", err)
+		}
+		if c.FilingDate, err = parseOptionalDate(filingDate); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(issuesJSON), &c.Issues); err != nil {
+			return nil, fmt.Errorf("failed to decode claim issues: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fragmentsJSON), &c.PathFragments); err != nil {
+			return nil, fmt.Errorf("failed to decode claim path fragments: %w", err)
+		}
+		claims = append(claims, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating claims: %w", err)
+	}
+	return claims, nil
+}
+
+// LinkClaims associates files with every registered claim by path fragment
+// and by docket number in the path or document text
+// Automatic links are rebuilt from scratch; manual links are kept
+// Returns the number of automatic links created
+func (d *DB) LinkClaims() (int, error) {
+	op := logging.StartOperation("LinkClaims", map[string]interface{}{})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to link claims")
+
+	claims, err := d.GetClaims()
+	if err != nil {
+		return 0, err
+	}
+
+	// A docket saved before validation may have no letters or digits; it gets no matcher
+	matchers := make([]*regexp.Regexp, len(claims))
+	for i, c := range claims {
+		matchers[i] = docketPattern(c.DocketNumber)
+	}
+
+	rows, err := d.db.Query("SELECT id, path, subject, file_name, extracted_text FROM files ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query files: %w", err)
+	}
+	type linkCandidate struct {
+		fileID int64
+		path   string
+		text   string
+	}
+	var candidates []linkCandidate
+	for rows.Next() {
+		var c linkCandidate
+		var fileName string
+		var subject, extractedText sql.NullString
+		if err := rows.Scan(&c.fileID, &c.path, &subject, &fileName, &extractedText); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file: %w", err)
+		}
+		c.text = documentText(subject.String, fileName, extractedText.String)
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating files: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM file_claims WHERE method != ?", ClaimLinkManual); err != nil {
+		return 0, fmt.Errorf("failed to clear automatic claim links: %w", err)
+	}
+
+	// INSERT OR IGNORE: a manual link for the same pair wins over an automatic one
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO file_claims (file_id, claim_id, method, evidence)
+		VALUES (?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare claim link insert: %w", err)
+	}
+	defer stmt.Close()
+
+	linked := 0
+	for _, cand := range candidates {
+		lowerPath := strings.ToLower(cand.path)
+		for i, claim := range claims {
+			method, evidence := "", ""
+			for _, fragment := range claim.PathFragments {
+				if fragment != "" && strings.Contains(lowerPath, strings.ToLower(fragment)) {
+					method, evidence = ClaimLinkPath, fragment
+					break
+				}
+			}
+			if method == "" && matchers[i] != nil {
+				if m := matchers[i].FindStringSubmatch(cand.path); m != nil {
+					method, evidence = ClaimLinkDocket, m[1]
+				} else if m := matchers[i].FindStringSubmatch(cand.text); m != nil {
+					method, evidence = ClaimLinkDocket, m[1]
+				}
+			}
+			if method == "" {
+				continue
+			}
+
+			result, err := stmt.Exec(cand.fileID, claim.ID, method, evidence)
+			if err != nil {
+				return 0, fmt.Errorf("failed to link file to claim: %w", err)
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				linked++
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit claim links: %w", err)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"claims":        len(claims),
+		"files_scanned": len(candidates),
+		"links_created": linked,
+	})
+	return linked, nil
+}
+
+// docketSeparatorRegex matches the separators between a docket number's parts
+var docketSeparatorRegex = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// validateDocketNumber requires a docket number with at least one letter or digit
+// Separators alone ("---") would give a pattern that matches every document
+func validateDocketNumber(docket string) error {
+	if strings.TrimSpace(docket) == "" {
+		return fmt.Errorf("docket number is required")
+	}
+	if docketSeparatorRegex.ReplaceAllString(docket, "") == "" {
+		return fmt.Errorf("docket number %q has no letters or digits", strings.TrimSpace(docket))
+	}
+	return nil
+}
+
+// docketPattern matches a docket number regardless of separators and case
+// "DOI-OS-23-0456" also matches "doi os 23 0456" and "DOI_OS_23_0456"
+// Returns nil for a docket with no letters or digits
+func docketPattern(docket string) *regexp.Regexp {
+	parts := docketSeparatorRegex.Split(docket, -1)
+	quoted := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			quoted = append(quoted, regexp.QuoteMeta(p))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(?:^|[^A-Za-z0-9])(` + strings.Join(quoted, `[\s\-_./]*`) + `)(?:$|[^A-Za-z0-9])`)
+}
+
+// AssignFileToClaim manually links a file to a claim
+// A manual assignment replaces any automatic link for the same pair
+func (d *DB) AssignFileToClaim(fileID, claimID int64, note string) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO file_claims (file_id, claim_id, method, evidence)
+		VALUES (?, ?, ?, ?)
+	`, fileID, claimID, ClaimLinkManual, note)
+	if err != nil {
+		return fmt.Errorf("failed to assign file to claim: %w", err)
+	}
+	return nil
+}
+
+// UnassignFileFromClaim removes a file's link to a claim, whatever its method
+// Automatic links will return on the next LinkClaims unless the claim rules change
+func (d *DB) UnassignFileFromClaim(fileID, claimID int64) error {
+	_, err := d.db.Exec("DELETE FROM file_claims WHERE file_id = ? AND claim_id = ?", fileID, claimID)
+	if err != nil {
+		return fmt.Errorf("failed to unassign file from claim: %w", err)
+	}
+	return nil
+}
+
+// GetFileClaims returns the claims a file is linked to
+func (d *DB) GetFileClaims(fileID int64) ([]FileClaim, error) {
+	rows, err := d.db.Query(`
+		SELECT c.id, c.docket_number, fc.method, fc.evidence
+		FROM file_claims fc
+		JOIN claims c ON c.id = fc.claim_id
+		WHERE fc.file_id = ?
+		ORDER BY c.docket_number
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file claims: %w", err)
+	}
+	defer rows.Close()
+
+	var claims []FileClaim
+	for rows.Next() {
+		var fc FileClaim
+		if err := rows.Scan(&fc.ClaimID, &fc.DocketNumber, &fc.Method, &fc.Evidence); err != nil {
+			return nil, fmt.Errorf("failed to scan file claim: %w", err)
+		}
+		claims = append(claims, fc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file claims: %w", err)
+	}
+	return claims, nil
+}
+
+// encodeClaimLists serializes a claim's list fields for storage
+func encodeClaimLists(claim Claim) (string, string, error) {
+	issues := claim.Issues
+	if issues == nil {
+		issues = []string{}
+	}
+	fragments := claim.PathFragments
+	if fragments == nil {
+		fragments = []string{}
+	}
+
+	issuesJSON, err := json.Marshal(issues)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode claim issues: %w", err)
+	}
+	fragmentsJSON, err := json.Marshal(fragments)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode claim path fragments: %w", err)
+	}
+	return string(issuesJSON), string(fragmentsJSON), nil
+}
@@ -0,0 +1,48 @@
+package database
+
+import "testing"
+
+func TestDocketNumberNeedsLettersOrDigits(t *testing.T) {
+	d := newTestDB(t)
+	for _, docket := range []string{"", "   ", "---", " / _ "} {
+		if _, err := d.CreateClaim(Claim{DocketNumber: docket}); err == nil {
+			t.Errorf("CreateClaim accepted docket %q", docket)
+		}
+	}
+
+	id, err := d.CreateClaim(Claim{DocketNumber: "DOI-OS-23-0456"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UpdateClaim(Claim{ID: id, DocketNumber: "--"}); err == nil {
+		t.Error("UpdateClaim accepted a separator-only docket")
+	}
+}
+
+func TestDocketPattern(t *testing.T) {
+	p := docketPattern("DOI-OS-23-0456")
+	for _, text := range []string{"re DOI-OS-23-0456 filing", "doi os 23 0456", "Claims/DOI_OS_23_0456/memo.pdf"} {
+		if !p.MatchString(text) {
+			t.Errorf("%q did not match", text)
+		}
+	}
+	if p.MatchString("DOI-OS-23-04567") {
+		t.Error("a longer docket number matched")
+	}
+	if docketPattern("---") != nil {
+		t.Error("a separator-only docket has a pattern")
+	}
+}
+
+func TestLinkClaimsSkipsSeparatorOnlyDockets(t *testing.T) {
+	d := newTestDB(t)
+	// Saved before dockets were validated
+	mustExec(t, d, "INSERT INTO claims (docket_number, description, issues, path_fragments) VALUES ('---', '', '[]', '[]')")
+	linked, err := d.LinkClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked != 0 {
+		t.Errorf("a separator-only docket linked %d documents", linked)
+	}
+}
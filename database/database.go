@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"signal-from-noise/assert"
 )
 
 // File represents a file in the database
@@ -27,7 +30,11 @@ type File struct {
 	ToEmail     string `json:"to_email"`     // Recipient email address (first/main)
 	Sentiment   string `json:"sentiment"`    // "positive", "negative", "neutral", "unknown"
 	IsInternal bool  `json:"is_internal"`   // true if internal email, false if external
-	Topic       string `json:"topic"`        // Extracted topic from subject
+	Topic       string `json:"topic"`        // Dominant topic (modeled, or subject prefix before training)
+	Issues      []string `json:"issues,omitempty"` // EEO issue codes tagged by ClassifyIssues
+	ReviewDecision string `json:"review_decision,omitempty"` // Decision for the searched production request
+	Tags        []string `json:"tags,omitempty"`   // Paths of the user-defined tags on the file
+	RelevanceScore *float64 `json:"relevance_score,omitempty"` // Predicted responsiveness for the searched production request
 }
 
 // FileFilters represents filters for querying files
@@ -42,6 +49,20 @@ type FileFilters struct {
 	Topics    []string // Topics extracted from email subjects
 	People    []string // Email addresses (from FROM or TO fields)
 	Sentiment string   // "positive", "negative", "neutral", "unknown", "all"
+	Issues    []string // EEO issue codes (protected class, law, argument)
+	ClaimIDs  []int64  // Claims the files must be linked to (see LinkClaims)
+	PIITypes  []string // PII types the files must contain any of (see DetectPII)
+	Keywords  []string // Terms matched against subject, file name and extracted text
+	// Review status for ProductionRequestID: "unreviewed", "reviewed", a decision, or "all"
+	ReviewStatus string
+	// Tag filters; a tag also matches files carrying any of its descendants
+	TagsAll  []int64 // Files must carry every one of these tags (AND)
+	TagsAny  []int64 // Files must carry at least one of these tags (OR)
+	TagsNone []int64 // Files must carry none of these tags (NOT)
+	BatchID  int64   // Restrict to one review batch, listed in batch order
+	// Relevance filters for ProductionRequestID (see TrainRelevanceModel)
+	MinRelevance *float64 // Files must score at least this; unscored files never match
+	SortBy       string   // "date" (default) or "relevance"; ignored for a batch
 	// People filter options
 	PeopleFilterType string // "internal", "external", "specific", "all"
 	Page             int
@@ -95,6 +116,20 @@ func NewDB(dbPath string) (*DB, error) {
 		}
 	}
 
+	// Production requests come from the served request list, not mock data
+	if err := database.seedProductionRequests(); err != nil {
+		return nil, fmt.Errorf("failed to seed production requests: %w", err)
+	}
+
+	if err := database.seedTagSets(); err != nil {
+		return nil, fmt.Errorf("failed to seed tag sets: %w", err)
+	}
+
+	// Files collected before the audit log existed still need a custody history
+	if err := database.backfillCustody(); err != nil {
+		return nil, fmt.Errorf("failed to backfill audit log: %w", err)
+	}
+
 	return database, nil
 }
 
@@ -123,6 +158,7 @@ func (d *DB) initSchema() error {
 		sentiment TEXT,
 		is_internal INTEGER DEFAULT 0,
 		topic TEXT,
+		extracted_text TEXT,
 		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
@@ -140,14 +176,442 @@ func (d *DB) initSchema() error {
 
 	CREATE TABLE IF NOT EXISTS production_requests (
 		id TEXT PRIMARY KEY,
+		number INTEGER NOT NULL DEFAULT 0,
 		title TEXT NOT NULL,
 		description TEXT NOT NULL,
+		served_date TEXT,
+		due_date TEXT,
+		status TEXT NOT NULL DEFAULT 'open',
+		objections TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Topic model: one row per training run, the latest is active
+	CREATE TABLE IF NOT EXISTS topic_models (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		topic_count INTEGER NOT NULL,
+		document_count INTEGER NOT NULL,
+		vocabulary_size INTEGER NOT NULL,
+		model TEXT NOT NULL,
+		trained_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS topics (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		model_id INTEGER NOT NULL REFERENCES topic_models(id),
+		cluster INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		top_terms TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS file_topics (
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		topic_id INTEGER NOT NULL REFERENCES topics(id),
+		weight REAL NOT NULL,
+		PRIMARY KEY (file_id, topic_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_file_topics_topic ON file_topics(topic_id);
+
+	-- EEO issue hits with evidence, replaced on each ClassifyIssues run
+	CREATE TABLE IF NOT EXISTS file_issues (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		code TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		phrase TEXT NOT NULL,
+		snippet TEXT NOT NULL,
+		field TEXT NOT NULL DEFAULT 'text', -- subject, file_name or text; offset is within it
+		offset INTEGER NOT NULL,
 		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
+
+	CREATE INDEX IF NOT EXISTS idx_file_issues_file ON file_issues(file_id);
+	CREATE INDEX IF NOT EXISTS idx_file_issues_code ON file_issues(code);
+
+	-- PII findings with offsets into extracted text, replaced on each DetectPII run
+	CREATE TABLE IF NOT EXISTS pii_findings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		type TEXT NOT NULL,
+		matched TEXT NOT NULL,
+		snippet TEXT NOT NULL,
+		offset INTEGER NOT NULL,
+		length INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_pii_findings_file ON pii_findings(file_id);
+	CREATE INDEX IF NOT EXISTS idx_pii_findings_type ON pii_findings(type);
+
+	CREATE TABLE IF NOT EXISTS claims (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		docket_number TEXT NOT NULL UNIQUE,
+		filing_date TEXT,
+		description TEXT NOT NULL DEFAULT '',
+		issues TEXT NOT NULL DEFAULT '[]',
+		path_fragments TEXT NOT NULL DEFAULT '[]',
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- method is "path", "docket" or "manual"; manual links survive re-linking
+	CREATE TABLE IF NOT EXISTS file_claims (
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		claim_id INTEGER NOT NULL REFERENCES claims(id),
+		method TEXT NOT NULL,
+		evidence TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (file_id, claim_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_file_claims_claim ON file_claims(claim_id);
+
+	CREATE TABLE IF NOT EXISTS saved_searches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		production_request_id TEXT NOT NULL REFERENCES production_requests(id),
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (name, production_request_id)
+	);
+
+	-- filters is the JSON-encoded FileFilters without pagination
+	CREATE TABLE IF NOT EXISTS saved_search_versions (
+		search_id INTEGER NOT NULL REFERENCES saved_searches(id),
+		version INTEGER NOT NULL,
+		filters TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (search_id, version)
+	);
+
+	CREATE TABLE IF NOT EXISTS saved_search_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		search_id INTEGER NOT NULL REFERENCES saved_searches(id),
+		version INTEGER NOT NULL,
+		run_at TEXT NOT NULL,
+		total_count INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS saved_search_run_files (
+		run_id INTEGER NOT NULL REFERENCES saved_search_runs(id),
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		PRIMARY KEY (run_id, file_id)
+	);
+
+	-- One current decision per file and production request
+	CREATE TABLE IF NOT EXISTS review_decisions (
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		production_request_id TEXT NOT NULL REFERENCES production_requests(id),
+		decision TEXT NOT NULL,
+		reviewer TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		decided_at TEXT NOT NULL,
+		PRIMARY KEY (file_id, production_request_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_review_decisions_request ON review_decisions(production_request_id, decision);
+
+	CREATE TABLE IF NOT EXISTS tag_sets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		multi_select INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- path is the "/"-joined names from the root, so a subtree is a path prefix
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		set_id INTEGER NOT NULL REFERENCES tag_sets(id),
+		parent_id INTEGER REFERENCES tags(id),
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (set_id, path)
+	);
+
+	CREATE TABLE IF NOT EXISTS file_tags (
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		tag_id INTEGER NOT NULL REFERENCES tags(id),
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (file_id, tag_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_file_tags_tag ON file_tags(tag_id);
+
+	-- Review batches; reviewer is the current holder, or the last one once checked in
+	CREATE TABLE IF NOT EXISTS review_batches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		batch_number INTEGER NOT NULL,
+		saved_search_id INTEGER NOT NULL REFERENCES saved_searches(id),
+		run_id INTEGER NOT NULL REFERENCES saved_search_runs(id),
+		production_request_id TEXT NOT NULL REFERENCES production_requests(id),
+		status TEXT NOT NULL DEFAULT 'available',
+		reviewer TEXT NOT NULL DEFAULT '',
+		checked_out_at TEXT,
+		checked_in_at TEXT,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_review_batches_request ON review_batches(production_request_id, status);
+
+	CREATE TABLE IF NOT EXISTS review_batch_files (
+		batch_id INTEGER NOT NULL REFERENCES review_batches(id),
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		position INTEGER NOT NULL,
+		PRIMARY KEY (batch_id, file_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_review_batch_files_file ON review_batch_files(file_id);
+
+	-- Relevance classifier per production request; only the latest is kept
+	CREATE TABLE IF NOT EXISTS relevance_models (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		production_request_id TEXT NOT NULL REFERENCES production_requests(id),
+		positive_count INTEGER NOT NULL,
+		negative_count INTEGER NOT NULL,
+		vocabulary_size INTEGER NOT NULL,
+		accuracy REAL NOT NULL,
+		model TEXT NOT NULL,
+		trained_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS relevance_scores (
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		production_request_id TEXT NOT NULL REFERENCES production_requests(id),
+		score REAL NOT NULL,
+		PRIMARY KEY (file_id, production_request_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_relevance_scores_request ON relevance_scores(production_request_id, score);
+
+	-- report holds the frozen ElusionReport JSON once finalized;
+	-- production_id is the production the sample validated, once linked
+	CREATE TABLE IF NOT EXISTS elusion_samples (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		production_request_id TEXT NOT NULL REFERENCES production_requests(id),
+		production_id INTEGER REFERENCES productions(id),
+		seed INTEGER NOT NULL,
+		population_size INTEGER NOT NULL,
+		produced_count INTEGER NOT NULL,
+		sample_size INTEGER NOT NULL,
+		created_at TEXT NOT NULL,
+		finalized_at TEXT,
+		report TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS elusion_sample_files (
+		sample_id INTEGER NOT NULL REFERENCES elusion_samples(id),
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		position INTEGER NOT NULL,
+		responsive INTEGER,
+		reviewer TEXT NOT NULL DEFAULT '',
+		decided_at TEXT,
+		PRIMARY KEY (sample_id, file_id)
+	);
+
+	-- bases and recipients are JSON lists; treatment is "withheld" or "redacted"
+	CREATE TABLE IF NOT EXISTS privilege_entries (
+		file_id INTEGER PRIMARY KEY REFERENCES files(id),
+		bases TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		author TEXT NOT NULL DEFAULT '',
+		recipients TEXT NOT NULL DEFAULT '[]',
+		treatment TEXT NOT NULL DEFAULT 'withheld',
+		updated_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS privilege_screen_hits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		reason TEXT NOT NULL,
+		matched TEXT NOT NULL,
+		counsel TEXT NOT NULL DEFAULT '',
+		snippet TEXT NOT NULL DEFAULT '',
+		offset INTEGER NOT NULL DEFAULT -1
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_privilege_screen_hits_file ON privilege_screen_hits(file_id);
+
+	CREATE TABLE IF NOT EXISTS privilege_queue (
+		file_id INTEGER PRIMARY KEY REFERENCES files(id),
+		status TEXT NOT NULL DEFAULT 'pending',
+		reviewer TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		screened_at TEXT NOT NULL,
+		decided_at TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_privilege_queue_status ON privilege_queue(status);
+
+	CREATE TABLE IF NOT EXISTS bates_sequences (
+		prefix TEXT PRIMARY KEY,
+		padding INTEGER NOT NULL,
+		start_number INTEGER NOT NULL,
+		next_number INTEGER NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS productions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		production_request_id TEXT NOT NULL,
+		directory TEXT NOT NULL,
+		bates_prefix TEXT NOT NULL,
+		beg_bates TEXT NOT NULL,
+		end_bates TEXT NOT NULL,
+		settings TEXT NOT NULL,
+		source_root TEXT NOT NULL,
+		output_hash TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS production_volumes (
+		production_id INTEGER NOT NULL REFERENCES productions(id),
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		file_count INTEGER NOT NULL,
+		size INTEGER NOT NULL,
+		beg_bates TEXT NOT NULL,
+		end_bates TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		PRIMARY KEY (production_id, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS production_documents (
+		production_id INTEGER NOT NULL REFERENCES productions(id),
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		position INTEGER NOT NULL,
+		source_path TEXT NOT NULL,
+		beg_bates TEXT NOT NULL,
+		end_bates TEXT NOT NULL,
+		pages INTEGER NOT NULL,
+		volume TEXT NOT NULL,
+		native_path TEXT NOT NULL,
+		text_path TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL,
+		sha256 TEXT NOT NULL,
+		md5 TEXT NOT NULL,
+		slip_sheet TEXT NOT NULL DEFAULT '',
+		redactions INTEGER NOT NULL DEFAULT 0,
+		pages_estimated INTEGER NOT NULL DEFAULT 0, -- 1 when pages were counted without the PDF page tree
+		PRIMARY KEY (production_id, file_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_production_documents_file ON production_documents(file_id);
+
+	CREATE TABLE IF NOT EXISTS redactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		page INTEGER NOT NULL DEFAULT 0,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		reason_code TEXT NOT NULL,
+		redacted_by TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_redactions_file ON redactions(file_id);
+
+	CREATE TABLE IF NOT EXISTS redaction_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		redaction_id INTEGER NOT NULL,
+		file_id INTEGER NOT NULL REFERENCES files(id),
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		page INTEGER NOT NULL,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		reason_code TEXT NOT NULL,
+		at TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_redaction_log_file ON redaction_log(file_id);
+
+	-- Chain-of-custody ledger; each entry's hash covers the previous entry's hash, and
+	-- the triggers refuse edits so changes can only be made by going around the app
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY,
+		action TEXT NOT NULL,
+		file_id INTEGER NOT NULL DEFAULT 0,
+		production_id INTEGER NOT NULL DEFAULT 0,
+		actor TEXT NOT NULL,
+		details TEXT NOT NULL,
+		at TEXT NOT NULL,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_file ON audit_log(file_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_production ON audit_log(production_id);
+
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;
 	`
 
-	_, err := d.db.Exec(schema)
-	return err
+	if _, err := d.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the first release; CREATE TABLE IF NOT EXISTS
+	// leaves existing databases without them
+	// ALTER TABLE cannot use CURRENT_TIMESTAMP as a default, hence ''
+	columns := []struct{ table, column, definition string }{
+		{"files", "extracted_text", "TEXT"},
+		{"files", "content_hash", "TEXT"}, // SHA-256 of the source bytes, set by IndexContentHashes
+		{"file_issues", "field", "TEXT NOT NULL DEFAULT 'text'"},
+		{"production_documents", "slip_sheet", "TEXT NOT NULL DEFAULT ''"},
+		{"production_documents", "redactions", "INTEGER NOT NULL DEFAULT 0"},
+		{"production_documents", "pages_estimated", "INTEGER NOT NULL DEFAULT 0"},
+		{"production_requests", "number", "INTEGER NOT NULL DEFAULT 0"},
+		{"production_requests", "served_date", "TEXT"},
+		{"production_requests", "due_date", "TEXT"},
+		{"production_requests", "status", "TEXT NOT NULL DEFAULT 'open'"},
+		{"production_requests", "objections", "TEXT NOT NULL DEFAULT ''"},
+		{"production_requests", "updated_at", "TEXT NOT NULL DEFAULT ''"},
+		{"elusion_samples", "production_id", "INTEGER REFERENCES productions(id)"},
+	}
+	for _, c := range columns {
+		if err := d.ensureColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureColumn adds a column to an existing table if it is missing
+func (d *DB) ensureColumn(table, column, definition string) error {
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating columns of %s: %w", table, err)
+	}
+
+	_, err = d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // getFileCount returns the total number of files
@@ -174,80 +638,9 @@ func (d *DB) SearchFiles(filters FileFilters) (*FileResult, error) {
 		filters.PageSize = 50
 	}
 
-	// Build WHERE clause
-	whereClause := "1=1"
-	args := []interface{}{}
-
-	// Date range filter
-	if filters.DateStart != nil {
-		whereClause += " AND date >= ?"
-		args = append(args, filters.DateStart.Format(time.RFC3339))
-	}
-	if filters.DateEnd != nil {
-		whereClause += " AND date <= ?"
-		args = append(args, filters.DateEnd.Format(time.RFC3339))
-	}
-
-	// Category filter
-	if len(filters.Categories) > 0 {
-		placeholders := ""
-		for i, cat := range filters.Categories {
-			if i > 0 {
-				placeholders += ","
-			}
-			placeholders += "?"
-			args = append(args, cat)
-		}
-		whereClause += fmt.Sprintf(" AND category IN (%s)", placeholders)
-	}
-
-	// Topic filter (incremental complexity reduction)
-	// ASSUMPTION: Topics exist in database and can filter email files
-	// If topics selected, only files with matching topics are returned
-	if len(filters.Topics) > 0 {
-		placeholders := ""
-		for i, topic := range filters.Topics {
-			if i > 0 {
-				placeholders += ","
-			}
-			placeholders += "?"
-			args = append(args, topic)
-		}
-		whereClause += fmt.Sprintf(" AND topic IN (%s)", placeholders)
-	}
-
-	// People filter (incremental complexity reduction)
-	// ASSUMPTION: People filter reduces result set by email addresses
-	// Internal/external classification precomputed for performance
-	if filters.PeopleFilterType != "" && filters.PeopleFilterType != "all" {
-		if filters.PeopleFilterType == "internal" {
-			whereClause += " AND is_internal = 1"
-		} else if filters.PeopleFilterType == "external" {
-			whereClause += " AND is_internal = 0"
-		} else if filters.PeopleFilterType == "specific" && len(filters.People) > 0 {
-			placeholders := ""
-			for i, email := range filters.People {
-				if i > 0 {
-					placeholders += " OR "
-				}
-				placeholders += "(from_email = ? OR to_email = ?)"
-				args = append(args, email, email)
-			}
-			whereClause += fmt.Sprintf(" AND (%s)", placeholders)
-		}
-	}
-
-	// Sentiment filter (incremental complexity reduction)
-	// ASSUMPTION: Sentiment values are valid and filter email files
-	// "all" means no sentiment filter applied
-	if filters.Sentiment != "" && filters.Sentiment != "all" {
-		whereClause += " AND sentiment = ?"
-		args = append(args, filters.Sentiment)
-	}
-
-	// Exclude privileged
-	if filters.ExcludePrivileged {
-		whereClause += " AND privileged = 0"
+	whereClause, args, err := fileWhereClause(filters)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get total count
@@ -255,7 +648,7 @@ func (d *DB) SearchFiles(filters FileFilters) (*FileResult, error) {
 	// If this fails, the database schema or query structure is invalid
 	countQuery := "SELECT COUNT(*) FROM files WHERE " + whereClause
 	var totalCount int
-	err := d.db.QueryRow(countQuery, args...).Scan(&totalCount)
+	err = d.db.QueryRow(countQuery, args...).Scan(&totalCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file count: %w", err)
 	}
@@ -267,17 +660,28 @@ func (d *DB) SearchFiles(filters FileFilters) (*FileResult, error) {
 	// ASSUMPTION: SQL query structure matches database schema
 	// Column names must exist in the files table
 	// Include all fields for frontend display
+	// The review decision and relevance score are for the filters' production request (NULL without one)
+	orderBy, orderArgs := fileOrderClause(filters)
 	query := fmt.Sprintf(`
 		SELECT id, path, directory, category, date, size, privileged, duplicate_hash, file_name,
-		       subject, from_email, to_email, sentiment, is_internal, topic
+		       subject, from_email, to_email, sentiment, is_internal, topic,
+		       (SELECT GROUP_CONCAT(DISTINCT code) FROM file_issues WHERE file_id = files.id) AS issues,
+		       (SELECT decision FROM review_decisions
+		        WHERE file_id = files.id AND production_request_id = ?) AS review_decision,
+		       (SELECT GROUP_CONCAT(t.path, char(31)) FROM file_tags ft JOIN tags t ON t.id = ft.tag_id
+		        WHERE ft.file_id = files.id) AS tags,
+		       (SELECT score FROM relevance_scores
+		        WHERE file_id = files.id AND production_request_id = ?) AS relevance_score
 		FROM files
 		WHERE %s
-		ORDER BY date DESC
+		ORDER BY %s
 		LIMIT ? OFFSET ?
-	`, whereClause)
+	`, whereClause, orderBy)
 
-	args = append(args, filters.PageSize, offset)
-	rows, err := d.db.Query(query, args...)
+	queryArgs := append([]interface{}{filters.ProductionRequestID, filters.ProductionRequestID}, args...)
+	queryArgs = append(queryArgs, orderArgs...)
+	queryArgs = append(queryArgs, filters.PageSize, offset)
+	rows, err := d.db.Query(query, queryArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query files: %w", err)
 	}
@@ -287,8 +691,9 @@ func (d *DB) SearchFiles(filters FileFilters) (*FileResult, error) {
 	for rows.Next() {
 		var f File
 		var dateStr string
-		var subject, fromEmail, toEmail, sentiment, topic sql.NullString
+		var subject, fromEmail, toEmail, sentiment, topic, issues, reviewDecision, tags sql.NullString
 		var isInternal sql.NullBool
+		var relevanceScore sql.NullFloat64
 
 		// ASSUMPTION: Row structure matches SELECT statement
 		// All columns must be scannable into the File struct
@@ -308,6 +713,10 @@ func (d *DB) SearchFiles(filters FileFilters) (*FileResult, error) {
 			&sentiment,
 			&isInternal,
 			&topic,
+			&issues,
+			&reviewDecision,
+			&tags,
+			&relevanceScore,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
@@ -337,6 +746,20 @@ func (d *DB) SearchFiles(filters FileFilters) (*FileResult, error) {
 		if isInternal.Valid {
 			f.IsInternal = isInternal.Bool
 		}
+		if issues.Valid {
+			f.Issues = strings.Split(issues.String, ",")
+		}
+		if reviewDecision.Valid {
+			f.ReviewDecision = reviewDecision.String
+		}
+		if tags.Valid {
+			// Unit separator, since tag names may contain commas
+			f.Tags = strings.Split(tags.String, "\x1f")
+		}
+		if relevanceScore.Valid {
+			score := relevanceScore.Float64
+			f.RelevanceScore = &score
+		}
 
 		files = append(files, f)
 	}
@@ -354,6 +777,260 @@ func (d *DB) SearchFiles(filters FileFilters) (*FileResult, error) {
 	}, nil
 }
 
+// fileWhereClause builds the WHERE clause and arguments for a set of filters
+// Shared by SearchFiles and SearchFileIDs so pages and full result sets agree
+// Page and PageSize are ignored
+func fileWhereClause(filters FileFilters) (string, []interface{}, error) {
+	whereClause := "1=1"
+	args := []interface{}{}
+
+	// Date range filter
+	if filters.DateStart != nil {
+		whereClause += " AND date >= ?"
+		args = append(args, filters.DateStart.Format(time.RFC3339))
+	}
+	if filters.DateEnd != nil {
+		whereClause += " AND date <= ?"
+		args = append(args, filters.DateEnd.Format(time.RFC3339))
+	}
+
+	// Category filter
+	if len(filters.Categories) > 0 {
+		placeholders := ""
+		for i, cat := range filters.Categories {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+			args = append(args, cat)
+		}
+		whereClause += fmt.Sprintf(" AND category IN (%s)", placeholders)
+	}
+
+	// Topic filter (incremental complexity reduction)
+	// ASSUMPTION: Topics exist in database and can filter email files
+	// A file matches on its dominant topic or on any modeled topic it was assigned
+	if len(filters.Topics) > 0 {
+		placeholders := ""
+		for i := range filters.Topics {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+		}
+		for _, topic := range filters.Topics {
+			args = append(args, topic)
+		}
+		for _, topic := range filters.Topics {
+			args = append(args, topic)
+		}
+		whereClause += fmt.Sprintf(` AND (topic IN (%s) OR id IN (
+			SELECT ft.file_id FROM file_topics ft JOIN topics t ON t.id = ft.topic_id
+			WHERE t.label IN (%s)))`, placeholders, placeholders)
+	}
+
+	// People filter (incremental complexity reduction)
+	// ASSUMPTION: People filter reduces result set by email addresses
+	// Internal/external classification precomputed for performance
+	if filters.PeopleFilterType != "" && filters.PeopleFilterType != "all" {
+		if filters.PeopleFilterType == "internal" {
+			whereClause += " AND is_internal = 1"
+		} else if filters.PeopleFilterType == "external" {
+			whereClause += " AND is_internal = 0"
+		} else if filters.PeopleFilterType == "specific" && len(filters.People) > 0 {
+			placeholders := ""
+			for i, email := range filters.People {
+				if i > 0 {
+					placeholders += " OR "
+				}
+				placeholders += "(from_email = ? OR to_email = ?)"
+				args = append(args, email, email)
+			}
+			whereClause += fmt.Sprintf(" AND (%s)", placeholders)
+		}
+	}
+
+	// Sentiment filter (incremental complexity reduction)
+	// ASSUMPTION: Sentiment values are valid and filter email files
+	// "all" means no sentiment filter applied
+	if filters.Sentiment != "" && filters.Sentiment != "all" {
+		whereClause += " AND sentiment = ?"
+		args = append(args, filters.Sentiment)
+	}
+
+	// EEO issue filter (incremental complexity reduction)
+	// ASSUMPTION: ClassifyIssues has run; untagged files never match
+	// A file matches if it carries any of the selected issue codes
+	if len(filters.Issues) > 0 {
+		placeholders := ""
+		for i, code := range filters.Issues {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+			args = append(args, code)
+		}
+		whereClause += fmt.Sprintf(" AND id IN (SELECT file_id FROM file_issues WHERE code IN (%s))", placeholders)
+	}
+
+	// Claim filter (incremental complexity reduction)
+	// ASSUMPTION: Files are linked to claims by LinkClaims or manual assignment
+	// A file matches if it is linked to any of the selected claims
+	if len(filters.ClaimIDs) > 0 {
+		placeholders := ""
+		for i, id := range filters.ClaimIDs {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+			args = append(args, id)
+		}
+		whereClause += fmt.Sprintf(" AND id IN (SELECT file_id FROM file_claims WHERE claim_id IN (%s))", placeholders)
+	}
+
+	// PII filter
+	// ASSUMPTION: DetectPII has run; files without findings never match
+	// A file matches if it contains any of the selected PII types
+	if len(filters.PIITypes) > 0 {
+		placeholders := ""
+		for i, piiType := range filters.PIITypes {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+			args = append(args, piiType)
+		}
+		whereClause += fmt.Sprintf(" AND id IN (SELECT file_id FROM pii_findings WHERE type IN (%s))", placeholders)
+	}
+
+	// Keyword filter (incremental complexity reduction)
+	// ASSUMPTION: Keywords describe content, so any one of them is enough to match
+	if len(filters.Keywords) > 0 {
+		clauses := ""
+		for i, kw := range filters.Keywords {
+			if i > 0 {
+				clauses += " OR "
+			}
+			clauses += `(subject LIKE ? ESCAPE '\' OR file_name LIKE ? ESCAPE '\' OR extracted_text LIKE ? ESCAPE '\')`
+			pattern := likePattern(kw)
+			args = append(args, pattern, pattern, pattern)
+		}
+		whereClause += fmt.Sprintf(" AND (%s)", clauses)
+	}
+
+	// Review status filter (incremental complexity reduction)
+	// ASSUMPTION: Review decisions only mean something for one production request
+	// A document responsive to PR-003 may be unreviewed for PR-004
+	if filters.ReviewStatus != "" && filters.ReviewStatus != "all" {
+		if filters.ProductionRequestID == "" {
+			return "", nil, fmt.Errorf("review status filter requires a production request")
+		}
+		switch filters.ReviewStatus {
+		case ReviewStatusUnreviewed:
+			whereClause += " AND id NOT IN (SELECT file_id FROM review_decisions WHERE production_request_id = ?)"
+			args = append(args, filters.ProductionRequestID)
+		case ReviewStatusReviewed:
+			whereClause += " AND id IN (SELECT file_id FROM review_decisions WHERE production_request_id = ?)"
+			args = append(args, filters.ProductionRequestID)
+		default:
+			if !isValidReviewDecision(filters.ReviewStatus) {
+				return "", nil, fmt.Errorf("unknown review status %q", filters.ReviewStatus)
+			}
+			whereClause += " AND id IN (SELECT file_id FROM review_decisions WHERE production_request_id = ? AND decision = ?)"
+			args = append(args, filters.ProductionRequestID, filters.ReviewStatus)
+		}
+	}
+
+	// Tag filters (incremental complexity reduction)
+	// ASSUMPTION: Filtering on a parent tag means "this tag or anything under it"
+	for _, id := range filters.TagsAll {
+		subquery, subArgs := tagFilterClause([]int64{id})
+		whereClause += " AND id IN (" + subquery + ")"
+		args = append(args, subArgs...)
+	}
+	if len(filters.TagsAny) > 0 {
+		subquery, subArgs := tagFilterClause(filters.TagsAny)
+		whereClause += " AND id IN (" + subquery + ")"
+		args = append(args, subArgs...)
+	}
+	if len(filters.TagsNone) > 0 {
+		subquery, subArgs := tagFilterClause(filters.TagsNone)
+		whereClause += " AND id NOT IN (" + subquery + ")"
+		args = append(args, subArgs...)
+	}
+
+	// Review batch filter
+	if filters.BatchID != 0 {
+		whereClause += " AND id IN (SELECT file_id FROM review_batch_files WHERE batch_id = ?)"
+		args = append(args, filters.BatchID)
+	}
+
+	// Relevance filter
+	// ASSUMPTION: Scores belong to one production request's model
+	if filters.MinRelevance != nil {
+		if filters.ProductionRequestID == "" {
+			return "", nil, fmt.Errorf("relevance filter requires a production request")
+		}
+		whereClause += " AND id IN (SELECT file_id FROM relevance_scores WHERE production_request_id = ? AND score >= ?)"
+		args = append(args, filters.ProductionRequestID, *filters.MinRelevance)
+	}
+
+	// Exclude privileged
+	if filters.ExcludePrivileged {
+		whereClause += " AND privileged = 0"
+	}
+
+	return whereClause, args, nil
+}
+
+// fileOrderClause builds the ORDER BY clause and arguments for a set of filters
+// A batch is listed in batch order so families and threads stay adjacent;
+// otherwise SortBy picks newest first or most relevant first
+func fileOrderClause(filters FileFilters) (string, []interface{}) {
+	if filters.BatchID != 0 {
+		return "(SELECT position FROM review_batch_files WHERE batch_id = ? AND file_id = files.id), id",
+			[]interface{}{filters.BatchID}
+	}
+	if filters.SortBy == SortByRelevance && filters.ProductionRequestID != "" {
+		// NULL sorts lowest in SQLite, so unscored files come last
+		return `(SELECT score FROM relevance_scores
+			WHERE file_id = files.id AND production_request_id = ?) DESC, date DESC, id`,
+			[]interface{}{filters.ProductionRequestID}
+	}
+	return "date DESC, id", nil
+}
+
+// SearchFileIDs returns the IDs of every file matching the filters, in SearchFiles order
+// Used where a whole result set is needed rather than one page
+func (d *DB) SearchFileIDs(filters FileFilters) ([]int64, error) {
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to search file IDs")
+
+	whereClause, args, err := fileWhereClause(filters)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, orderArgs := fileOrderClause(filters)
+	rows, err := d.db.Query("SELECT id FROM files WHERE "+whereClause+" ORDER BY "+orderBy, append(args, orderArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan file ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file IDs: %w", err)
+	}
+	return ids, nil
+}
+
 // GetFileByID retrieves a file by its ID
 func (d *DB) GetFileByID(id int64) (*File, error) {
 	query := `
@@ -405,7 +1082,8 @@ func (d *DB) GetFilesByIDs(ids []int64) ([]File, error) {
 
 	query := fmt.Sprintf(`
 		SELECT id, path, directory, category, date, size, privileged, duplicate_hash, file_name,
-		       subject, from_email, to_email, sentiment, is_internal, topic
+		       subject, from_email, to_email, sentiment, is_internal, topic,
+		       (SELECT GROUP_CONCAT(DISTINCT code) FROM file_issues WHERE file_id = files.id) AS issues
 		FROM files
 		WHERE id IN (%s)
 	`, placeholders)
@@ -420,7 +1098,7 @@ func (d *DB) GetFilesByIDs(ids []int64) ([]File, error) {
 	for rows.Next() {
 		var f File
 		var dateStr string
-		var subject, fromEmail, toEmail, sentiment, topic sql.NullString
+		var subject, fromEmail, toEmail, sentiment, topic, issues sql.NullString
 		var isInternal sql.NullBool
 		err := rows.Scan(
 			&f.ID,
@@ -438,6 +1116,7 @@ func (d *DB) GetFilesByIDs(ids []int64) ([]File, error) {
 			&sentiment,
 			&isInternal,
 			&topic,
+			&issues,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
@@ -467,9 +1146,39 @@ func (d *DB) GetFilesByIDs(ids []int64) ([]File, error) {
 		if isInternal.Valid {
 			f.IsInternal = isInternal.Bool
 		}
+		if issues.Valid {
+			f.Issues = strings.Split(issues.String, ",")
+		}
 
 		files = append(files, f)
 	}
 
 	return files, nil
 }
+
+// formatOptionalDate stores a nil date as NULL
+func formatOptionalDate(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+// parseOptionalDate reads a nullable RFC3339 date column
+func parseOptionalDate(ns sql.NullString) (*time.Time, error) {
+	if !ns.Valid || ns.String == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, ns.String)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse date: %w", err)
+	}
+	return &t, nil
+}
+
+// likePattern wraps a term for a case-insensitive substring LIKE match
+// LIKE wildcards in the term are escaped so they match literally
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EDRM XML 2.0 document types and field tags
+// "#" tags are EDRM-defined fields; review tags are exported under their "Set/Path" names
+const (
+	edrmMajorVersion = "2"
+	edrmMinorVersion = "0"
+
+	edrmDocTypeMessage = "Message"
+	edrmDocTypeFile    = "File"
+
+	edrmFileNative = "Native"
+	edrmFileText   = "Text"
+)
+
+// edrmTagDataTypes are the TagDataType values allowed by the EDRM XML 2.0 schema
+var edrmTagDataTypes = map[string]bool{
+	"Text": true, "LongText": true, "Integer": true, "Decimal": true, "DateTime": true, "Boolean": true,
+}
+
+// edrmRoot is the top-level EDRM XML element; a production volume is one batch
+type edrmRoot struct {
+	XMLName             xml.Name  `xml:"Root"`
+	MajorVersion        string    `xml:"MajorVersion,attr"`
+	MinorVersion        string    `xml:"MinorVersion,attr"`
+	Description         string    `xml:"Description,attr"`
+	Locale              string    `xml:"Locale,attr"`
+	DataInterchangeType string    `xml:"DataInterchangeType,attr"`
+	Batch               edrmBatch `xml:"Batch"`
+}
+
+// edrmBatch holds the documents of one volume
+type edrmBatch struct {
+	Name      string         `xml:"name,attr"`
+	Documents []edrmDocument `xml:"Documents>Document"`
+}
+
+// edrmDocument is one produced document, identified by its beginning Bates number
+type edrmDocument struct {
+	DocID     string         `xml:"DocID,attr"`
+	DocType   string         `xml:"DocType,attr"`
+	MimeType  string         `xml:"MimeType,attr,omitempty"`
+	Tags      []edrmTag      `xml:"Tags>Tag"`
+	Files     []edrmFile     `xml:"Files>File"`
+	Locations []edrmLocation `xml:"Locations>Location"`
+}
+
+// edrmTag is a field value or review tag on a document
+type edrmTag struct {
+	TagName     string `xml:"TagName,attr"`
+	TagDataType string `xml:"TagDataType,attr"`
+	TagValue    string `xml:"TagValue,attr"`
+}
+
+// edrmFile is a native or text rendition shipped with a document
+type edrmFile struct {
+	FileType     string           `xml:"FileType,attr"`
+	ExternalFile edrmExternalFile `xml:"ExternalFile"`
+}
+
+// edrmExternalFile points at a file inside the production
+type edrmExternalFile struct {
+	FilePath string `xml:"FilePath,attr"`
+	FileName string `xml:"FileName,attr"`
+	FileSize int64  `xml:"FileSize,attr,omitempty"`
+	Hash     string `xml:"Hash,attr,omitempty"` // MD5, matching the DAT
+}
+
+// edrmLocation records where a document was collected from
+type edrmLocation struct {
+	Custodian   string `xml:"Custodian"`
+	LocationURI string `xml:"LocationURI"`
+}
+
+// edrmExternalFileFor splits a zip entry name into EDRM's folder and file name
+func edrmExternalFileFor(entry string, size int64, hash string) edrmExternalFile {
+	return edrmExternalFile{
+		FilePath: loadFilePath(path.Dir(entry)),
+		FileName: path.Base(entry),
+		FileSize: size,
+		Hash:     hash,
+	}
+}
+
+// buildEDRM renders one volume's documents as EDRM XML 2.0
+func buildEDRM(volume, productionRequestID string, docs []ProducedDocument, files map[int64]File, tags map[int64][]string, custodian string) ([]byte, error) {
+	root := edrmRoot{
+		MajorVersion:        edrmMajorVersion,
+		MinorVersion:        edrmMinorVersion,
+		Description:         fmt.Sprintf("Production for %s, %s", productionRequestID, volume),
+		Locale:              "US",
+		DataInterchangeType: "Update",
+		Batch:               edrmBatch{Name: volume},
+	}
+
+	for _, doc := range docs {
+		file := files[doc.FileID]
+		d := edrmDocument{DocID: doc.BegBates, DocType: edrmDocTypeFile}
+		if file.Category == "email" {
+			d.DocType = edrmDocTypeMessage
+			d.MimeType = "message/rfc822"
+		}
+
+		d.Tags = append(d.Tags,
+			edrmTag{"#EndBates", "Text", doc.EndBates},
+			edrmTag{"#PageCount", "Integer", strconv.FormatInt(doc.Pages, 10)},
+		)
+		if doc.SlipSheet != "" {
+			// Only the placeholder is described; see datValue
+			d.DocType, d.MimeType = edrmDocTypeFile, "application/pdf"
+			d.Tags = append(d.Tags, edrmTag{"WithheldReason", "Text", doc.SlipSheet})
+			d.Files = append(d.Files, edrmFile{edrmFileNative, edrmExternalFileFor(doc.NativePath, doc.Size, doc.MD5)})
+			if doc.TextPath != "" {
+				d.Files = append(d.Files, edrmFile{edrmFileText, edrmExternalFileFor(doc.TextPath, 0, "")})
+			}
+			d.Locations = append(d.Locations, edrmLocation{Custodian: custodian})
+			root.Batch.Documents = append(root.Batch.Documents, d)
+			continue
+		}
+		if file.Category == "email" {
+			d.Tags = append(d.Tags,
+				edrmTag{"#From", "Text", file.FromEmail},
+				edrmTag{"#To", "Text", file.ToEmail},
+				edrmTag{"#Subject", "Text", file.Subject},
+				edrmTag{"#DateSent", "DateTime", file.Date.UTC().Format(time.RFC3339)},
+			)
+		} else {
+			d.Tags = append(d.Tags, edrmTag{"#DateModified", "DateTime", file.Date.UTC().Format(time.RFC3339)})
+		}
+		for _, name := range tags[doc.FileID] {
+			d.Tags = append(d.Tags, edrmTag{name, "Boolean", "true"})
+		}
+
+		d.Files = append(d.Files, edrmFile{edrmFileNative, edrmExternalFileFor(doc.NativePath, doc.Size, doc.MD5)})
+		if doc.TextPath != "" {
+			d.Files = append(d.Files, edrmFile{edrmFileText, edrmExternalFileFor(doc.TextPath, 0, "")})
+		}
+		d.Locations = append(d.Locations, edrmLocation{Custodian: custodian, LocationURI: file.Path})
+
+		root.Batch.Documents = append(root.Batch.Documents, d)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to encode EDRM XML: %w", err)
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// validateEDRM parses EDRM XML back and checks it against the 2.0 structure
+// entries are the zip entry names in the volume; every ExternalFile must point at one
+func validateEDRM(data []byte, entries map[string]bool) error {
+	var root edrmRoot
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("EDRM XML is not well-formed: %w", err)
+	}
+	if root.MajorVersion != edrmMajorVersion || root.MinorVersion != edrmMinorVersion {
+		return fmt.Errorf("EDRM XML version is %s.%s, want 2.0", root.MajorVersion, root.MinorVersion)
+	}
+	if root.Batch.Name == "" {
+		return fmt.Errorf("EDRM XML batch has no name")
+	}
+	if len(root.Batch.Documents) == 0 {
+		return fmt.Errorf("EDRM XML batch %s has no documents", root.Batch.Name)
+	}
+
+	seen := make(map[string]bool, len(root.Batch.Documents))
+	for _, d := range root.Batch.Documents {
+		if d.DocID == "" {
+			return fmt.Errorf("EDRM XML document without a DocID")
+		}
+		if seen[d.DocID] {
+			return fmt.Errorf("EDRM XML DocID %s appears twice", d.DocID)
+		}
+		seen[d.DocID] = true
+		if d.DocType != edrmDocTypeMessage && d.DocType != edrmDocTypeFile {
+			return fmt.Errorf("document %s has unknown DocType %q", d.DocID, d.DocType)
+		}
+
+		for _, t := range d.Tags {
+			if t.TagName == "" {
+				return fmt.Errorf("document %s has a tag without a name", d.DocID)
+			}
+			if !edrmTagDataTypes[t.TagDataType] {
+				return fmt.Errorf("document %s tag %s has unknown data type %q", d.DocID, t.TagName, t.TagDataType)
+			}
+			if err := validateEDRMTagValue(t); err != nil {
+				return fmt.Errorf("document %s tag %s: %w", d.DocID, t.TagName, err)
+			}
+		}
+
+		natives := 0
+		for _, f := range d.Files {
+			if f.FileType != edrmFileNative && f.FileType != edrmFileText {
+				return fmt.Errorf("document %s has unknown FileType %q", d.DocID, f.FileType)
+			}
+			if f.FileType == edrmFileNative {
+				natives++
+			}
+			ext := f.ExternalFile
+			if ext.FileName == "" || ext.FilePath == "" {
+				return fmt.Errorf("document %s has a %s file without a path", d.DocID, f.FileType)
+			}
+			entry := path.Join(filepathFromLoadFile(ext.FilePath), ext.FileName)
+			if !entries[entry] {
+				return fmt.Errorf("document %s references %s, which is not in the volume", d.DocID, entry)
+			}
+		}
+		if natives != 1 {
+			return fmt.Errorf("document %s has %d native files, want 1", d.DocID, natives)
+		}
+		if len(d.Locations) == 0 {
+			return fmt.Errorf("document %s has no location", d.DocID)
+		}
+	}
+	return nil
+}
+
+// validateEDRMTagValue checks that typed tag values parse as their declared type
+func validateEDRMTagValue(t edrmTag) error {
+	var err error
+	switch t.TagDataType {
+	case "Integer":
+		_, err = strconv.ParseInt(t.TagValue, 10, 64)
+	case "Decimal":
+		_, err = strconv.ParseFloat(t.TagValue, 64)
+	case "DateTime":
+		_, err = time.Parse(time.RFC3339, t.TagValue)
+	case "Boolean":
+		_, err = strconv.ParseBool(t.TagValue)
+	}
+	if err != nil {
+		return fmt.Errorf("value %q is not a valid %s", t.TagValue, t.TagDataType)
+	}
+	return nil
+}
+
+// filepathFromLoadFile reverses loadFilePath, turning backslashes back into zip separators
+func filepathFromLoadFile(p string) string {
+	return path.Clean(strings.ReplaceAll(p, `\`, "/"))
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"archive/zip"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestEDRMRoundTrip(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("first"), ids[1]: onePagePDF("second")})
+	mustExec(t, d, "UPDATE files SET extracted_text = 'first memo' WHERE id = ?", ids[0])
+
+	out := produce(t, d, ids, root, ProductionSettings{EDRMXML: true, Custodian: "J. Doe"})
+	r, err := zip.OpenReader(out.Volumes[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	entries := map[string]*zip.File{}
+	names := map[string]bool{}
+	for _, f := range r.File {
+		entries[f.Name] = f
+		names[f.Name] = true
+	}
+	read := func(name string) []byte {
+		t.Helper()
+		f := entries[name]
+		if f == nil {
+			t.Fatalf("%s is not in the volume", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, _ := io.ReadAll(rc)
+		return data
+	}
+
+	data := read("VOL001/DATA/VOL001.xml")
+	if err := validateEDRM(data, names); err != nil {
+		t.Fatalf("written EDRM XML fails validation: %v", err)
+	}
+	var parsed edrmRoot
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Batch.Documents) != len(out.Documents) {
+		t.Fatalf("EDRM XML has %d documents, want %d", len(parsed.Batch.Documents), len(out.Documents))
+	}
+	for i, doc := range parsed.Batch.Documents {
+		produced := out.Documents[i]
+		if doc.DocID != produced.BegBates {
+			t.Errorf("document %d DocID = %s, want %s", i, doc.DocID, produced.BegBates)
+		}
+		tags := map[string]string{}
+		for _, tag := range doc.Tags {
+			tags[tag.TagName] = tag.TagValue
+		}
+		if tags["#EndBates"] != produced.EndBates {
+			t.Errorf("%s #EndBates = %q, want %s", doc.DocID, tags["#EndBates"], produced.EndBates)
+		}
+		if doc.Locations[0].Custodian != "J. Doe" || doc.Locations[0].LocationURI != produced.Path {
+			t.Errorf("%s location = %+v", doc.DocID, doc.Locations[0])
+		}
+
+		// Each external file resolves to the entry it describes, with the native's hash
+		for _, f := range doc.Files {
+			entry := path.Join(filepathFromLoadFile(f.ExternalFile.FilePath), f.ExternalFile.FileName)
+			content := read(entry)
+			if f.FileType == edrmFileNative {
+				sum := md5.Sum(content)
+				if !strings.EqualFold(f.ExternalFile.Hash, hex.EncodeToString(sum[:])) || f.ExternalFile.FileSize != int64(len(content)) {
+					t.Errorf("%s native %s does not match its entry", doc.DocID, entry)
+				}
+			}
+			if f.FileType == edrmFileText && string(content) != "first memo" {
+				t.Errorf("%s text entry holds %q", doc.DocID, content)
+			}
+		}
+	}
+}
+
+func TestValidateEDRMRejectsBrokenXML(t *testing.T) {
+	docs := []ProducedDocument{{FileID: 1, BegBates: "DOI0000001", EndBates: "DOI0000001", Pages: 1,
+		NativePath: "VOL001/NATIVES/DOI0000001.pdf", Size: 3, MD5: "abc"}}
+	files := map[int64]File{1: {ID: 1, Path: "a/b.pdf", Category: "other"}}
+	entries := map[string]bool{"VOL001/NATIVES/DOI0000001.pdf": true}
+	good, err := buildEDRM("VOL001", "PR-001", docs, files, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateEDRM(good, entries); err != nil {
+		t.Fatalf("valid EDRM XML refused: %v", err)
+	}
+
+	broken := map[string]string{
+		"missing entry":   strings.Replace(string(good), "DOI0000001.pdf", "DOI0000009.pdf", 1),
+		"bad integer":     strings.Replace(string(good), `TagValue="1"`, `TagValue="one"`, 1),
+		"wrong version":   strings.Replace(string(good), `MajorVersion="2"`, `MajorVersion="1"`, 1),
+		"unknown type":    strings.Replace(string(good), `DocType="File"`, `DocType="Binder"`, 1),
+		"not well formed": strings.Replace(string(good), "</Root>", "", 1),
+	}
+	for name, data := range broken {
+		if err := validateEDRM([]byte(data), entries); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Elusion sampling settings
+const (
+	// elusionZ is the normal quantile for the 95% confidence intervals reported
+	elusionZ = 1.959964
+	// defaultElusionMargin is the margin of error used to size a sample when none is given
+	defaultElusionMargin = 0.02
+)
+
+// ElusionSample is a random sample drawn from a production request's discard pile
+// The discard pile is every file not coded responsive or privileged for the request
+type ElusionSample struct {
+	ID                  int64          `json:"id"`
+	ProductionRequestID string         `json:"production_request_id"`
+	ProductionID        int64          `json:"production_id,omitempty"` // Production the sample validated; 0 until linked
+	Seed                int64          `json:"seed"`
+	PopulationSize      int            `json:"population_size"` // Files in the discard pile when drawn
+	ProducedCount       int            `json:"produced_count"`  // Files coded responsive or privileged when drawn
+	SampleSize          int            `json:"sample_size"`
+	CreatedAt           time.Time      `json:"created_at"`
+	FinalizedAt         *time.Time     `json:"finalized_at"`
+	Report              *ElusionReport `json:"report"` // Frozen report once finalized
+}
+
+// ElusionSampleFile is one sampled file with the QC reviewer's call, if made
+type ElusionSampleFile struct {
+	FileID     int64      `json:"file_id"`
+	Position   int        `json:"position"`
+	Path       string     `json:"path"`
+	Responsive *bool      `json:"responsive"` // nil until reviewed
+	Reviewer   string     `json:"reviewer"`
+	DecidedAt  *time.Time `json:"decided_at"`
+}
+
+// ElusionReport is the statistical summary of an elusion sample
+// Intervals are 95% Wilson score intervals on the elusion rate; the recall
+// interval follows from them because recall falls as elusion rises
+type ElusionReport struct {
+	SampleID            int64     `json:"sample_id"`
+	ProductionRequestID string    `json:"production_request_id"`
+	Seed                int64     `json:"seed"`
+	PopulationSize      int       `json:"population_size"`
+	ProducedCount       int       `json:"produced_count"`
+	SampleSize          int       `json:"sample_size"`
+	ReviewedCount       int       `json:"reviewed_count"`
+	ResponsiveFound     int       `json:"responsive_found"`
+	ElusionRate         float64   `json:"elusion_rate"`
+	ElusionLow          float64   `json:"elusion_low"`
+	ElusionHigh         float64   `json:"elusion_high"`
+	EstimatedEluded     float64   `json:"estimated_eluded"` // Responsive files estimated to remain in the discard pile
+	Recall              float64   `json:"recall"`
+	RecallLow           float64   `json:"recall_low"`
+	RecallHigh          float64   `json:"recall_high"`
+	Complete            bool      `json:"complete"` // Every sampled file has been reviewed
+	GeneratedAt         time.Time `json:"generated_at"`
+}
+
+// ElusionSampleSize returns the sample size needed to estimate elusion within margin
+// at 95% confidence, assuming the worst case rate of 50% and correcting for a finite population
+func ElusionSampleSize(population int, margin float64) int {
+	if population <= 0 {
+		return 0
+	}
+	if margin <= 0 {
+		margin = defaultElusionMargin
+	}
+	n0 := elusionZ * elusionZ * 0.25 / (margin * margin)
+	n := n0 / (1 + (n0-1)/float64(population))
+	return int(math.Min(math.Ceil(n), float64(population)))
+}
+
+// CreateElusionSample draws a reproducible random sample from the request's discard pile
+// The same seed over the same discard pile always yields the same sample
+// A sampleSize of 0 or less picks one from ElusionSampleSize with a ±2% margin
+func (d *DB) CreateElusionSample(requestID string, sampleSize int, seed int64) (*ElusionSample, error) {
+	op := logging.StartOperation("CreateElusionSample", map[string]interface{}{
+		"production_request_id": requestID,
+		"sample_size":           sampleSize,
+		"seed":                  seed,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to draw an elusion sample")
+
+	if _, err := d.GetProductionRequest(requestID); err != nil {
+		return nil, err
+	}
+
+	population, err := d.discardPile(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if len(population) == 0 {
+		return nil, fmt.Errorf("discard pile for %s is empty", requestID)
+	}
+
+	var produced int
+	if err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM review_decisions WHERE production_request_id = ? AND decision IN (?, ?)
+	`, requestID, DecisionResponsive, DecisionPrivileged).Scan(&produced); err != nil {
+		return nil, fmt.Errorf("failed to count production set: %w", err)
+	}
+
+	if sampleSize <= 0 {
+		sampleSize = ElusionSampleSize(len(population), defaultElusionMargin)
+	}
+	if sampleSize > len(population) {
+		sampleSize = len(population)
+	}
+
+	// Population is sorted by ID so the shuffle depends only on the seed and pile contents
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(population), func(i, j int) {
+		population[i], population[j] = population[j], population[i]
+	})
+	sample := population[:sampleSize]
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO elusion_samples (production_request_id, seed, population_size, produced_count, sample_size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, requestID, seed, len(population), produced, sampleSize, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create elusion sample: %w", err)
+	}
+	sampleID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get elusion sample id: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO elusion_sample_files (sample_id, file_id, position) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare sample file insert: %w", err)
+	}
+	defer stmt.Close()
+	for position, fileID := range sample {
+		if _, err := stmt.Exec(sampleID, fileID, position); err != nil {
+			return nil, fmt.Errorf("failed to add file %d to sample: %w", fileID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit elusion sample: %w", err)
+	}
+	return d.GetElusionSample(sampleID)
+}
+
+// discardPile returns the IDs, ascending, of files not coded responsive or privileged for the request
+func (d *DB) discardPile(requestID string) ([]int64, error) {
+	rows, err := d.db.Query(`
+		SELECT id FROM files
+		WHERE id NOT IN (
+			SELECT file_id FROM review_decisions
+			WHERE production_request_id = ? AND decision IN (?, ?))
+		ORDER BY id
+	`, requestID, DecisionResponsive, DecisionPrivileged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discard pile: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan discard pile: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating discard pile: %w", err)
+	}
+	return ids, nil
+}
+
+// GetElusionSample returns a sample with its frozen report, if finalized
+func (d *DB) GetElusionSample(id int64) (*ElusionSample, error) {
+	samples, err := d.queryElusionSamples("id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("elusion sample %d not found", id)
+	}
+	return &samples[0], nil
+}
+
+// GetElusionSamples returns the elusion samples drawn for a production request, newest first
+func (d *DB) GetElusionSamples(requestID string) ([]ElusionSample, error) {
+	return d.queryElusionSamples("production_request_id = ?", requestID)
+}
+
+// queryElusionSamples loads samples matching a WHERE condition
+func (d *DB) queryElusionSamples(condition string, args ...interface{}) ([]ElusionSample, error) {
+	rows, err := d.db.Query(`
+		SELECT id, production_request_id, production_id, seed, population_size, produced_count, sample_size,
+		       created_at, finalized_at, report
+		FROM elusion_samples
+		WHERE `+condition+`
+		ORDER BY id DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query elusion samples: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []ElusionSample
+	for rows.Next() {
+		var s ElusionSample
+		var createdAt string
+		var productionID sql.NullInt64
+		var finalizedAt, report sql.NullString
+		if err := rows.Scan(&s.ID, &s.ProductionRequestID, &productionID, &s.Seed, &s.PopulationSize, &s.ProducedCount,
+			&s.SampleSize, &createdAt, &finalizedAt, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan elusion sample: %w", err)
+		}
+		s.ProductionID = productionID.Int64
+		s.CreatedAt = parseTimestamp(createdAt)
+		if s.FinalizedAt, err = parseOptionalDate(finalizedAt); err != nil {
+			return nil, err
+		}
+		if report.Valid {
+			s.Report = &ElusionReport{}
+			if err := json.Unmarshal([]byte(report.String), s.Report); err != nil {
+				return nil, fmt.Errorf("failed to decode elusion report: %w", err)
+			}
+		}
+		samples = append(samples, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating elusion samples: %w", err)
+	}
+	return samples, nil
+}
+
+// GetElusionSampleFiles returns the sampled files in draw order with their QC calls
+func (d *DB) GetElusionSampleFiles(sampleID int64) ([]ElusionSampleFile, error) {
+	rows, err := d.db.Query(`
+		SELECT sf.file_id, sf.position, f.path, sf.responsive, sf.reviewer, sf.decided_at
+		FROM elusion_sample_files sf
+		JOIN files f ON f.id = sf.file_id
+		WHERE sf.sample_id = ?
+		ORDER BY sf.position
+	`, sampleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sample files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []ElusionSampleFile
+	for rows.Next() {
+		var f ElusionSampleFile
+		var responsive sql.NullBool
+		var decidedAt sql.NullString
+		if err := rows.Scan(&f.FileID, &f.Position, &f.Path, &responsive, &f.Reviewer, &decidedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sample file: %w", err)
+		}
+		if responsive.Valid {
+			f.Responsive = &responsive.Bool
+		}
+		if f.DecidedAt, err = parseOptionalDate(decidedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sample files: %w", err)
+	}
+	return files, nil
+}
+
+// RecordElusionDecision records whether a sampled file is responsive
+// Sample calls are kept apart from review_decisions so QC does not change the production set
+func (d *DB) RecordElusionDecision(sampleID, fileID int64, responsive bool, reviewer string) error {
+	reviewer = strings.TrimSpace(reviewer)
+	if reviewer == "" {
+		return fmt.Errorf("reviewer is required")
+	}
+	sample, err := d.GetElusionSample(sampleID)
+	if err != nil {
+		return err
+	}
+	// ASSUMPTION: A finalized report must not change after it is attached
+	if sample.FinalizedAt != nil {
+		return fmt.Errorf("elusion sample %d is finalized", sampleID)
+	}
+
+	result, err := d.db.Exec(`
+		UPDATE elusion_sample_files SET responsive = ?, reviewer = ?, decided_at = ?
+		WHERE sample_id = ? AND file_id = ?
+	`, responsive, reviewer, time.Now().UTC().Format(time.RFC3339), sampleID, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to record elusion decision: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("file %d is not in elusion sample %d", fileID, sampleID)
+	}
+	return nil
+}
+
+// GetElusionReport computes the current statistics for a sample
+// A finalized sample returns its frozen report
+func (d *DB) GetElusionReport(sampleID int64) (*ElusionReport, error) {
+	sample, err := d.GetElusionSample(sampleID)
+	if err != nil {
+		return nil, err
+	}
+	if sample.Report != nil {
+		return sample.Report, nil
+	}
+
+	report := &ElusionReport{
+		SampleID:            sample.ID,
+		ProductionRequestID: sample.ProductionRequestID,
+		Seed:                sample.Seed,
+		PopulationSize:      sample.PopulationSize,
+		ProducedCount:       sample.ProducedCount,
+		SampleSize:          sample.SampleSize,
+		GeneratedAt:         time.Now().UTC(),
+	}
+	err = d.db.QueryRow(`
+		SELECT COUNT(responsive), COALESCE(SUM(responsive), 0)
+		FROM elusion_sample_files WHERE sample_id = ?
+	`, sampleID).Scan(&report.ReviewedCount, &report.ResponsiveFound)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sample decisions: %w", err)
+	}
+	report.Complete = report.ReviewedCount == report.SampleSize
+	computeElusionStats(report)
+	return report, nil
+}
+
+// computeElusionStats fills the rates and intervals from the counts
+// With nothing reviewed yet the rates stay zero and the intervals span everything
+func computeElusionStats(r *ElusionReport) {
+	r.ElusionLow, r.ElusionHigh = 0, 1
+	r.RecallLow, r.RecallHigh = 0, 1
+	if r.ReviewedCount == 0 {
+		return
+	}
+
+	r.ElusionRate = float64(r.ResponsiveFound) / float64(r.ReviewedCount)
+	r.ElusionLow, r.ElusionHigh = wilsonInterval(r.ResponsiveFound, r.ReviewedCount, elusionZ)
+	r.EstimatedEluded = r.ElusionRate * float64(r.PopulationSize)
+
+	recall := func(elusion float64) float64 {
+		produced := float64(r.ProducedCount)
+		eluded := elusion * float64(r.PopulationSize)
+		if produced+eluded == 0 {
+			return 0
+		}
+		return produced / (produced + eluded)
+	}
+	r.Recall = recall(r.ElusionRate)
+	r.RecallLow = recall(r.ElusionHigh)
+	r.RecallHigh = recall(r.ElusionLow)
+}
+
+// wilsonInterval returns the Wilson score interval for successes out of n
+// It stays inside [0, 1] and behaves sensibly when no successes are observed
+func wilsonInterval(successes, n int, z float64) (float64, float64) {
+	if n == 0 {
+		return 0, 1
+	}
+	p := float64(successes) / float64(n)
+	nf := float64(n)
+	denominator := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denominator
+	spread := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denominator
+	return math.Max(0, center-spread), math.Min(1, center+spread)
+}
+
+// FinalizeElusionSample freezes a fully reviewed sample's report onto the production request record
+// After this the sample's decisions can no longer change
+func (d *DB) FinalizeElusionSample(sampleID int64) (*ElusionReport, error) {
+	report, err := d.GetElusionReport(sampleID)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Complete {
+		return nil, fmt.Errorf("elusion sample %d has %d of %d files reviewed", sampleID, report.ReviewedCount, report.SampleSize)
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elusion report: %w", err)
+	}
+	_, err = d.db.Exec("UPDATE elusion_samples SET report = ?, finalized_at = ? WHERE id = ? AND finalized_at IS NULL",
+		string(reportJSON), report.GeneratedAt.Format(time.RFC3339), sampleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize elusion sample: %w", err)
+	}
+	return d.GetElusionReport(sampleID)
+}
+
+// LinkElusionSample records that a finalized sample validated a production of its request
+// A sample validates one production; linking it again to the same one is a no-op
+func (d *DB) LinkElusionSample(sampleID, productionID int64) error {
+	sample, err := d.GetElusionSample(sampleID)
+	if err != nil {
+		return err
+	}
+	if sample.Report == nil {
+		return fmt.Errorf("elusion sample %d must be finalized before it is linked to a production", sampleID)
+	}
+	if sample.ProductionID != 0 && sample.ProductionID != productionID {
+		return fmt.Errorf("elusion sample %d already validates production %d", sampleID, sample.ProductionID)
+	}
+	production, err := d.GetProduction(productionID)
+	if err != nil {
+		return err
+	}
+	if production == nil {
+		return fmt.Errorf("production %d not found", productionID)
+	}
+	if production.ProductionRequestID != sample.ProductionRequestID {
+		return fmt.Errorf("production %d answers %s, but elusion sample %d was drawn for %s",
+			productionID, production.ProductionRequestID, sampleID, sample.ProductionRequestID)
+	}
+
+	if _, err := d.db.Exec("UPDATE elusion_samples SET production_id = ? WHERE id = ?", productionID, sampleID); err != nil {
+		return fmt.Errorf("failed to link elusion sample: %w", err)
+	}
+	return nil
+}
+
+// GetProductionElusionSamples returns the samples linked to a production, newest first
+func (d *DB) GetProductionElusionSamples(productionID int64) ([]ElusionSample, error) {
+	return d.queryElusionSamples("production_id = ?", productionID)
+}
+
+// WriteElusionReport writes a sample's report as plain text for the production record
+// The file lists the sampled files and calls so the result can be reproduced from the seed
+func (d *DB) WriteElusionReport(sampleID int64, outputDir string) (string, error) {
+	sample, err := d.GetElusionSample(sampleID)
+	if err != nil {
+		return "", err
+	}
+	report, err := d.GetElusionReport(sampleID)
+	if err != nil {
+		return "", err
+	}
+	files, err := d.GetElusionSampleFiles(sampleID)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Elusion report for %s (sample %d)\n", report.ProductionRequestID, report.SampleID)
+	fmt.Fprintf(&b, "Generated: %s\n", report.GeneratedAt.Format(time.RFC3339))
+	if sample.ProductionID != 0 {
+		production, err := d.GetProduction(sample.ProductionID)
+		if err != nil {
+			return "", err
+		}
+		if production != nil {
+			fmt.Fprintf(&b, "Validates production %d: %s - %s\n", production.ID,
+				production.Documents[0].BegBates, production.Documents[len(production.Documents)-1].EndBates)
+		}
+	}
+	if !report.Complete {
+		fmt.Fprintf(&b, "PRELIMINARY: %d of %d sampled files reviewed\n", report.ReviewedCount, report.SampleSize)
+	}
+	fmt.Fprintf(&b, "\nRandom seed:          %d\n", report.Seed)
+	fmt.Fprintf(&b, "Discard pile size:    %d\n", report.PopulationSize)
+	fmt.Fprintf(&b, "Production set size:  %d\n", report.ProducedCount)
+	fmt.Fprintf(&b, "Sample size:          %d\n", report.SampleSize)
+	fmt.Fprintf(&b, "Responsive in sample: %d of %d reviewed\n", report.ResponsiveFound, report.ReviewedCount)
+	fmt.Fprintf(&b, "\nElusion rate:     %.2f%% (95%% CI %.2f%% - %.2f%%)\n", 100*report.ElusionRate, 100*report.ElusionLow, 100*report.ElusionHigh)
+	fmt.Fprintf(&b, "Estimated eluded: %.0f documents\n", report.EstimatedEluded)
+	fmt.Fprintf(&b, "Estimated recall: %.2f%% (95%% CI %.2f%% - %.2f%%)\n", 100*report.Recall, 100*report.RecallLow, 100*report.RecallHigh)
+	fmt.Fprintf(&b, "\nSampled files (draw order):\n")
+	for _, f := range files {
+		call := "not reviewed"
+		if f.Responsive != nil {
+			call = "non-responsive"
+			if *f.Responsive {
+				call = "RESPONSIVE"
+			}
+			call += " (" + f.Reviewer + ")"
+		}
+		fmt.Fprintf(&b, "%5d  %d  %s  %s\n", f.Position+1, f.FileID, f.Path, call)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	path := filepath.Join(outputDir, fmt.Sprintf("%s_elusion_%d.txt", report.ProductionRequestID, report.SampleID))
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write elusion report: %w", err)
+	}
+	return path, nil
+}
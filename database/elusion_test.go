@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestElusionSampleIsReproducible(t *testing.T) {
+	d := newTestDB(t)
+	a, err := d.CreateElusionSample("PR-001", 10, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.CreateElusionSample("PR-001", 10, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aFiles, _ := d.GetElusionSampleFiles(a.ID)
+	bFiles, _ := d.GetElusionSampleFiles(b.ID)
+	for i := range aFiles {
+		if aFiles[i].FileID != bFiles[i].FileID {
+			t.Fatalf("same seed drew file %d then %d at position %d", aFiles[i].FileID, bFiles[i].FileID, i)
+		}
+	}
+}
+
+func TestWilsonIntervalStaysInRange(t *testing.T) {
+	low, high := wilsonInterval(0, 100, elusionZ)
+	if low != 0 || high <= 0 || high > 0.05 {
+		t.Errorf("0 of 100 gives [%f, %f]", low, high)
+	}
+	low, high = wilsonInterval(100, 100, elusionZ)
+	if high != 1 || low < 0.95 {
+		t.Errorf("100 of 100 gives [%f, %f]", low, high)
+	}
+}
+
+func TestLinkElusionSampleToProduction(t *testing.T) {
+	d := newTestDB(t)
+	sample, err := d.CreateElusionSample("PR-001", 2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := d.GetElusionSampleFiles(sample.ID)
+	ids := firstFileIDs(t, d, 1)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("produced")})
+	production := produce(t, d, ids, root, ProductionSettings{})
+
+	if err := d.LinkElusionSample(sample.ID, production.ID); err == nil || !strings.Contains(err.Error(), "finalized") {
+		t.Errorf("linked an unfinished sample: %v", err)
+	}
+	for _, f := range files {
+		if err := d.RecordElusionDecision(sample.ID, f.FileID, false, "qc"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.FinalizeElusionSample(sample.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := d.CreateZipFile(context.Background(), "PR-002", ids, root, t.TempDir(), ProductionSettings{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.LinkElusionSample(sample.ID, other.ID); err == nil || !strings.Contains(err.Error(), "PR-002") {
+		t.Errorf("linked a sample to another request's production: %v", err)
+	}
+	if err := d.LinkElusionSample(sample.ID, production.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.LinkElusionSample(sample.ID, other.ID); err == nil {
+		t.Error("relinked a sample to a second production")
+	}
+
+	linked, err := d.GetProductionElusionSamples(production.ID)
+	if err != nil || len(linked) != 1 || linked[0].ID != sample.ID {
+		t.Fatalf("production samples are %+v, %v", linked, err)
+	}
+	path, err := d.WriteElusionReport(sample.ID, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := os.ReadFile(path)
+	if !strings.Contains(string(text), "Validates production") || !strings.Contains(string(text), production.Documents[0].BegBates) {
+		t.Errorf("report does not name the production:\n%s", text)
+	}
+}
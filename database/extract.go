@@ -0,0 +1,127 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// textExtensions are natives whose bytes are their text
+var textExtensions = map[string]bool{
+	".txt": true, ".text": true, ".eml": true, ".csv": true, ".md": true, ".log": true,
+}
+
+// sourcePath maps an indexed file path onto the data lake
+func sourcePath(sourceRoot, path string) string {
+	return filepath.Join(sourceRoot, filepath.FromSlash(path))
+}
+
+// hasTextExtractor reports whether ExtractText can read a native's format
+func hasTextExtractor(path string) bool {
+	return isPDFPath(path) || textExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// extractFileText returns the text of a native in a format hasTextExtractor accepts
+// A PDF's pages are read from its content streams and separated by form feeds; image-only
+// and unparseable PDFs fail. Invalid UTF-8 is replaced so the stored text is always valid
+func extractFileText(path string, data []byte) (string, error) {
+	if isPDFPath(path) {
+		pages, err := pdfPageTexts(data)
+		if err != nil {
+			return "", err
+		}
+		texts := make([]string, len(pages))
+		for i, page := range pages {
+			texts[i] = latin1String(page)
+		}
+		return strings.Join(texts, "\f"), nil
+	}
+	return strings.ToValidUTF8(string(data), "\uFFFD"), nil
+}
+
+// ExtractText reads the natives of files without extracted text from the data lake and
+// stores their text, which the topic model, issue classifier, claim linking, privilege
+// screen, PII detector and relevance model read along with subject and file name
+// Files in formats without an extractor, missing from sourceRoot or that cannot be read,
+// such as scanned PDFs, are left for a later run. When a topic model exists the newly extracted files are assigned topics with it.
+// Returns the number of files whose text was stored
+func (d *DB) ExtractText(sourceRoot string) (int, error) {
+	op := logging.StartOperation("ExtractText", map[string]interface{}{
+		"source_root": sourceRoot,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to extract text")
+
+	rows, err := d.db.Query("SELECT id, path FROM files WHERE extracted_text IS NULL ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query files without text: %w", err)
+	}
+	type pending struct {
+		id   int64
+		path string
+	}
+	var files []pending
+	for rows.Next() {
+		var f pending
+		if err := rows.Scan(&f.id, &f.path); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating files: %w", err)
+	}
+
+	var extracted []int64
+	unsupported, missing, failed := 0, 0, 0
+	for _, f := range files {
+		if !hasTextExtractor(f.path) {
+			unsupported++
+			continue
+		}
+		data, err := os.ReadFile(sourcePath(sourceRoot, f.path))
+		if os.IsNotExist(err) {
+			missing++
+			continue
+		}
+		if err != nil {
+			return len(extracted), fmt.Errorf("failed to read %s: %w", f.path, err)
+		}
+		text, err := extractFileText(f.path, data)
+		if err != nil {
+			logging.LogError("ExtractText", err, map[string]interface{}{"path": f.path})
+			failed++
+			continue
+		}
+		if _, err := d.db.Exec("UPDATE files SET extracted_text = ? WHERE id = ?", text, f.id); err != nil {
+			return len(extracted), fmt.Errorf("failed to save extracted text: %w", err)
+		}
+		extracted = append(extracted, f.id)
+	}
+
+	var models int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM topic_models").Scan(&models); err != nil {
+		return len(extracted), fmt.Errorf("failed to count topic models: %w", err)
+	}
+	if models > 0 {
+		if err := d.AssignTopics(extracted); err != nil {
+			return len(extracted), err
+		}
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"extracted":   len(extracted),
+		"unsupported": unsupported,
+		"missing":     missing,
+		"failed":      failed,
+	})
+	return len(extracted), nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// moveToLake points a file at a new path and writes its native there under root
+func moveToLake(t *testing.T, d *DB, root string, fileID int64, path string, data []byte) {
+	t.Helper()
+	mustExec(t, d, "UPDATE files SET path = ? WHERE id = ?", path, fileID)
+	full := sourcePath(root, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// extractedText returns a file's stored text and whether any is stored
+func extractedText(t *testing.T, d *DB, fileID int64) (string, bool) {
+	t.Helper()
+	var text *string
+	if err := d.db.QueryRow("SELECT extracted_text FROM files WHERE id = ?", fileID).Scan(&text); err != nil {
+		t.Fatal(err)
+	}
+	if text == nil {
+		return "", false
+	}
+	return *text, true
+}
+
+func TestExtractTextStoresTextNatives(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 4)
+	root := t.TempDir()
+	moveToLake(t, d, root, ids[0], "Mail/msg_1.eml", []byte("Subject: Telework\r\n\r\nPlease approve my telework request."))
+	moveToLake(t, d, root, ids[1], "Notes/notes.txt", []byte("caf\xe9 meeting notes"))
+	moveToLake(t, d, root, ids[2], "Scans/scan.tiff", []byte("II*\x00"))
+	mustExec(t, d, "UPDATE files SET path = 'Mail/gone.eml' WHERE id = ?", ids[3])
+
+	n, err := d.ExtractText(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("extracted %d files, want 2", n)
+	}
+	if text, _ := extractedText(t, d, ids[0]); text != "Subject: Telework\r\n\r\nPlease approve my telework request." {
+		t.Errorf("email text %q", text)
+	}
+	if text, _ := extractedText(t, d, ids[1]); text != "caf\uFFFD meeting notes" {
+		t.Errorf("invalid UTF-8 stored as %q", text)
+	}
+	// Unsupported and missing natives are left for a later run
+	if _, ok := extractedText(t, d, ids[2]); ok {
+		t.Error("stored text for an image")
+	}
+	if _, ok := extractedText(t, d, ids[3]); ok {
+		t.Error("stored text for a missing file")
+	}
+
+	// Stored text is searchable by keyword
+	found, err := d.SearchFileIDs(FileFilters{Keywords: []string{"approve"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != ids[0] {
+		t.Errorf("keyword search found %v, want [%d]", found, ids[0])
+	}
+
+	if n, err := d.ExtractText(root); err != nil || n != 0 {
+		t.Errorf("second run extracted %d files (%v), want none", n, err)
+	}
+}
+
+func TestExtractTextAssignsTopicsToNewFiles(t *testing.T) {
+	d := newTestDB(t)
+	if _, err := d.TrainTopicModel(12); err != nil {
+		t.Fatal(err)
+	}
+	ids := firstFileIDs(t, d, 1)
+	mustExec(t, d, "DELETE FROM file_topics WHERE file_id = ?", ids[0])
+	root := t.TempDir()
+	moveToLake(t, d, root, ids[0], "Mail/budget.eml", []byte("budget approval invoice contract review budget approval"))
+
+	if _, err := d.ExtractText(root); err != nil {
+		t.Fatal(err)
+	}
+	topics, err := d.GetFileTopics(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topics) == 0 {
+		t.Error("newly extracted file was not assigned a topic")
+	}
+}
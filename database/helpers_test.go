@@ -0,0 +1,85 @@
+package database
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Operation logging is useful in the app and noise in test output
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestDB opens a fresh, seeded database in a temporary directory
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull) // SeedMockData prints a summary
+	d, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+// mustExec runs a statement against the test database
+func mustExec(t *testing.T, d *DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := d.db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// firstFileIDs returns the IDs of the first n seeded files
+func firstFileIDs(t *testing.T, d *DB, n int) []int64 {
+	t.Helper()
+	rows, err := d.db.Query("SELECT id FROM files ORDER BY id LIMIT ?", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// writeLake writes each file's content under a temporary data lake root and indexes
+// the content hashes; content maps file ID to bytes
+func writeLake(t *testing.T, d *DB, content map[int64][]byte) string {
+	t.Helper()
+	root := t.TempDir()
+	for id, data := range content {
+		f, err := d.GetFileByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := sourcePath(root, f.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.IndexContentHashes(root); err != nil {
+		t.Fatalf("IndexContentHashes: %v", err)
+	}
+	return root
+}
+
+// onePagePDF is a minimal text-based PDF with one page showing text
+func onePagePDF(text string) []byte {
+	return SlipSheetPDF("TEST0000001", text)
+}
@@ -0,0 +1,316 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Issue kinds mirror the three parts of the EEO area question (step 17)
+const (
+	IssueKindProtectedClass = "protected_class"
+	IssueKindLaw            = "law"
+	IssueKindArgument       = "argument"
+)
+
+// Document fields an issue hit can be found in
+const (
+	IssueFieldSubject  = "subject"
+	IssueFieldFileName = "file_name"
+	IssueFieldText     = "text" // extracted_text
+)
+
+// IssueRule tags a document with an issue code when any phrase matches
+// Phrases match case-insensitively on word boundaries, so a bare word that is also a
+// name or everyday term ("Ada", "black", "promotion") tags far too much; use phrases
+type IssueRule struct {
+	Code    string   `json:"code"`  // e.g. "title_vii", "retaliation"
+	Kind    string   `json:"kind"`  // protected_class, law or argument
+	Label   string   `json:"label"` // Display name
+	Phrases []string `json:"phrases"`
+}
+
+// IssueHit is the evidence for one issue code in one file
+type IssueHit struct {
+	FileID  int64  `json:"file_id"`
+	Code    string `json:"code"`
+	Kind    string `json:"kind"`
+	Phrase  string `json:"phrase"`  // Phrase as it appears in the text
+	Snippet string `json:"snippet"` // Surrounding text for the reviewer
+	Field   string `json:"field"`   // IssueFieldSubject, IssueFieldFileName or IssueFieldText
+	Offset  int    `json:"offset"`  // Byte offset of the match in Field
+}
+
+// IssueOption is an issue code with its usage count, for the filter UI
+type IssueOption struct {
+	Code      string `json:"code"`
+	Kind      string `json:"kind"`
+	Label     string `json:"label"`
+	FileCount int    `json:"file_count"`
+}
+
+// DefaultIssueRules returns the built-in EEO rule set
+// Covers the protected classes, statutes and arguments listed in the search process
+func DefaultIssueRules() []IssueRule {
+	return []IssueRule{
+		// Protected classes
+		{"race", IssueKindProtectedClass, "Race", []string{"racial", "racist", "racially", "race discrimination", "race-based", "because of race", "because of my race", "african american", "black employee", "black employees", "hispanic", "latino"}},
+		{"color", IssueKindProtectedClass, "Color", []string{"skin color", "complexion", "colorism"}},
+		{"religion", IssueKindProtectedClass, "Religion", []string{"religion", "religious", "mosque", "synagogue", "sabbath"}},
+		{"sex", IssueKindProtectedClass, "Sex", []string{"sex discrimination", "sex-based", "because of sex", "because of my sex", "gender", "pregnancy", "pregnant", "sexual orientation", "gender identity", "female employee", "female employees", "male employee", "male employees"}},
+		{"national_origin", IssueKindProtectedClass, "National Origin", []string{"national origin", "ethnicity", "ethnic", "foreign accent", "immigrant", "citizenship"}},
+		{"age", IssueKindProtectedClass, "Age", []string{"age discrimination", "too old", "younger employee", "older worker", "retirement eligible", "over 40"}},
+		{"disability", IssueKindProtectedClass, "Disability", []string{"disability", "disabled employee", "disabled veteran", "physical impairment", "mental impairment", "medical condition", "handicap"}},
+		{"genetic_information", IssueKindProtectedClass, "Genetic Information", []string{"genetic information", "genetic test", "family medical history"}},
+		{"reprisal", IssueKindProtectedClass, "Reprisal (Prior EEO Activity)", []string{"prior eeo activity", "protected activity", "eeo complaint", "eeo counselor"}},
+
+		// Statutes
+		{"title_vii", IssueKindLaw, "Title VII", []string{"title vii", "civil rights act", "42 u.s.c. 2000e"}},
+		{"adea", IssueKindLaw, "ADEA", []string{"adea", "age discrimination in employment act"}},
+		{"ada", IssueKindLaw, "ADA / Rehabilitation Act", []string{"americans with disabilities act", "ada amendments act", "under the ada", "ada claim", "rehabilitation act", "section 501"}},
+		{"epa", IssueKindLaw, "Equal Pay Act", []string{"equal pay act", "pay disparity", "unequal pay"}},
+		{"gina", IssueKindLaw, "GINA", []string{"genetic information nondiscrimination act", "under gina"}},
+		{"telework_act", IssueKindLaw, "Telework Enhancement Act", []string{"telework act", "telework enhancement act", "telework agreement"}},
+
+		// Legal arguments
+		{"retaliation", IssueKindArgument, "Retaliation", []string{"retaliation", "retaliate", "retaliatory", "reprisal"}},
+		{"harassment", IssueKindArgument, "Harassment / Hostile Work Environment", []string{"harassment", "harass", "hostile work environment", "bullying", "intimidation"}},
+		{"accommodation", IssueKindArgument, "Failure to Accommodate", []string{"reasonable accommodation", "accommodation request", "interactive process", "undue hardship"}},
+		{"disparate_treatment", IssueKindArgument, "Disparate Treatment", []string{"disparate treatment", "treated differently", "similarly situated", "comparator"}},
+		{"non_selection", IssueKindArgument, "Non-Selection / Promotion", []string{"non-selection", "not selected", "selecting official", "denied promotion", "denied a promotion", "passed over for promotion", "not promoted", "vacancy announcement", "best qualified"}},
+		{"constructive_discharge", IssueKindArgument, "Constructive Discharge", []string{"constructive discharge", "forced to resign", "forced resignation"}},
+	}
+}
+
+// LoadIssueRules reads a rule set from a JSON file (an array of IssueRule)
+// Lets counsel tune phrases per matter without a rebuild
+func LoadIssueRules(path string) ([]IssueRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load issue rules: %w", err)
+	}
+
+	var rules []IssueRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("could not parse issue rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		if rule.Code == "" || len(rule.Phrases) == 0 {
+			return nil, fmt.Errorf("issue rule %q must have a code and at least one phrase", rule.Code)
+		}
+		switch rule.Kind {
+		case IssueKindProtectedClass, IssueKindLaw, IssueKindArgument:
+		default:
+			return nil, fmt.Errorf("issue rule %q has unknown kind %q", rule.Code, rule.Kind)
+		}
+	}
+	return rules, nil
+}
+
+// compiledIssueRule pairs a rule with its phrase matcher
+type compiledIssueRule struct {
+	rule    IssueRule
+	pattern *regexp.Regexp
+}
+
+// compileIssueRules builds one case-insensitive, word-bounded regexp per rule
+func compileIssueRules(rules []IssueRule) ([]compiledIssueRule, error) {
+	compiled := make([]compiledIssueRule, 0, len(rules))
+	for _, rule := range rules {
+		alternatives := make([]string, len(rule.Phrases))
+		for i, phrase := range rule.Phrases {
+			alternatives[i] = regexp.QuoteMeta(strings.ToLower(phrase))
+		}
+		pattern, err := regexp.Compile(`(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid phrases for issue rule %q: %w", rule.Code, err)
+		}
+		compiled = append(compiled, compiledIssueRule{rule: rule, pattern: pattern})
+	}
+	return compiled, nil
+}
+
+// matchIssues returns every rule hit in a document with a snippet of context
+// Offsets are rebased from the combined document text onto the field each hit is in
+func matchIssues(doc corpusDocument, rules []compiledIssueRule) []IssueHit {
+	var hits []IssueHit
+	for _, cr := range rules {
+		for _, loc := range cr.pattern.FindAllStringIndex(doc.text, -1) {
+			field, offset := IssueFieldText, loc[0]-doc.textStart
+			switch {
+			case loc[0] < doc.nameStart:
+				field, offset = IssueFieldSubject, loc[0]
+			case loc[0] < doc.textStart:
+				field, offset = IssueFieldFileName, loc[0]-doc.nameStart
+			}
+			hits = append(hits, IssueHit{
+				FileID:  doc.fileID,
+				Code:    cr.rule.Code,
+				Kind:    cr.rule.Kind,
+				Phrase:  doc.text[loc[0]:loc[1]],
+				Snippet: snippetAround(doc.text, loc[0], loc[1], 40),
+				Field:   field,
+				Offset:  offset,
+			})
+		}
+	}
+	return hits
+}
+
+// snippetAround returns the match with up to radius bytes of context on each side
+// Context is trimmed to whitespace so words are not cut in half
+func snippetAround(text string, start, end, radius int) string {
+	from := start - radius
+	if from < 0 {
+		from = 0
+	} else if i := strings.IndexAny(text[from:start], " \n\t"); i >= 0 {
+		from += i + 1
+	}
+
+	to := end + radius
+	if to > len(text) {
+		to = len(text)
+	} else if i := strings.LastIndexAny(text[end:to], " \n\t"); i >= 0 {
+		to = end + i
+	}
+
+	return strings.Join(strings.Fields(text[from:to]), " ")
+}
+
+// ClassifyIssues tags every file with the EEO issue codes its text matches
+// Previous hits are replaced, so re-running after editing rules is safe
+// Returns the number of files with at least one issue
+func (d *DB) ClassifyIssues(rules []IssueRule) (int, error) {
+	op := logging.StartOperation("ClassifyIssues", map[string]interface{}{
+		"rule_count": len(rules),
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to classify issues")
+
+	compiled, err := compileIssueRules(rules)
+	if err != nil {
+		return 0, err
+	}
+
+	docs, err := d.loadCorpus(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM file_issues"); err != nil {
+		return 0, fmt.Errorf("failed to clear issue hits: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO file_issues (file_id, code, kind, phrase, snippet, field, offset)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare issue insert: %w", err)
+	}
+	defer stmt.Close()
+
+	tagged, totalHits := 0, 0
+	for _, doc := range docs {
+		hits := matchIssues(doc, compiled)
+		if len(hits) > 0 {
+			tagged++
+		}
+		for _, h := range hits {
+			if _, err := stmt.Exec(h.FileID, h.Code, h.Kind, h.Phrase, h.Snippet, h.Field, h.Offset); err != nil {
+				return 0, fmt.Errorf("failed to save issue hit: %w", err)
+			}
+		}
+		totalHits += len(hits)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit issue hits: %w", err)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"files_scanned": len(docs),
+		"files_tagged":  tagged,
+		"hits":          totalHits,
+	})
+	return tagged, nil
+}
+
+// GetIssueEvidence returns all issue hits recorded for a file
+func (d *DB) GetIssueEvidence(fileID int64) ([]IssueHit, error) {
+	rows, err := d.db.Query(`
+		SELECT file_id, code, kind, phrase, snippet, field, offset
+		FROM file_issues
+		WHERE file_id = ?
+		ORDER BY CASE field WHEN 'subject' THEN 0 WHEN 'file_name' THEN 1 ELSE 2 END, offset
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query issue evidence: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []IssueHit
+	for rows.Next() {
+		var h IssueHit
+		if err := rows.Scan(&h.FileID, &h.Code, &h.Kind, &h.Phrase, &h.Snippet, &h.Field, &h.Offset); err != nil {
+			return nil, fmt.Errorf("failed to scan issue hit: %w", err)
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating issue hits: %w", err)
+	}
+	return hits, nil
+}
+
+// GetIssueOptions returns every rule code with the number of files tagged with it
+// Codes with no hits are included so the filter UI shows the full rule set
+func (d *DB) GetIssueOptions(rules []IssueRule) ([]IssueOption, error) {
+	rows, err := d.db.Query(`
+		SELECT code, COUNT(DISTINCT file_id)
+		FROM file_issues
+		GROUP BY code
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count issues: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var code string
+		var count int
+		if err := rows.Scan(&code, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan issue count: %w", err)
+		}
+		counts[code] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating issue counts: %w", err)
+	}
+
+	options := make([]IssueOption, len(rules))
+	for i, rule := range rules {
+		options[i] = IssueOption{
+			Code:      rule.Code,
+			Kind:      rule.Kind,
+			Label:     rule.Label,
+			FileCount: counts[rule.Code],
+		}
+	}
+	return options, nil
+}
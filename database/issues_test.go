@@ -0,0 +1,54 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyIssuesOffsetsAreWithinTheirField(t *testing.T) {
+	d := newTestDB(t)
+	id := firstFileIDs(t, d, 1)[0]
+	text := "The complainant filed a reasonable accommodation request in May."
+	mustExec(t, d, "UPDATE files SET subject = 'Retaliation concerns', file_name = 'eeo complaint notes.pdf', extracted_text = ? WHERE id = ?", text, id)
+
+	if _, err := d.ClassifyIssues(DefaultIssueRules()); err != nil {
+		t.Fatal(err)
+	}
+	hits, err := d.GetIssueEvidence(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]IssueHit{
+		"retaliation":   {Field: IssueFieldSubject, Offset: 0},
+		"reprisal":      {Field: IssueFieldFileName, Offset: 0},
+		"accommodation": {Field: IssueFieldText, Offset: strings.Index(text, "reasonable accommodation")},
+	}
+	for _, h := range hits {
+		w, ok := want[h.Code]
+		if !ok {
+			t.Errorf("unexpected hit %s on %q", h.Code, h.Phrase)
+			continue
+		}
+		if h.Field != w.Field || h.Offset != w.Offset {
+			t.Errorf("%s hit in %s at %d, want %s at %d", h.Code, h.Field, h.Offset, w.Field, w.Offset)
+		}
+		delete(want, h.Code)
+	}
+	for code := range want {
+		t.Errorf("no %s hit", code)
+	}
+}
+
+func TestDefaultIssueRulesIgnoreEverydayWords(t *testing.T) {
+	compiled, err := compileIssueRules(DefaultIssueRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "Ada left the black binder at the promotion launch. Gina from the EPA ran the race; " +
+		"male and female restrooms are on each floor. I have faith the sex of the kitten is right. " +
+		"Your account is disabled until you telework again."
+	doc := corpusDocument{fileID: 1, text: "\n\n" + text, nameStart: 1, textStart: 2}
+	for _, h := range matchIssues(doc, compiled) {
+		t.Errorf("%s matched %q", h.Code, h.Phrase)
+	}
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Concordance DAT delimiters
+// Fields are quoted with þ (254) and separated by DC4 (20), which Concordance shows as ¶;
+// line breaks inside a value become ® (174) so every record stays on one line
+const (
+	datQuote     = "þ"
+	datSeparator = "\x14"
+	datNewline   = "®"
+	datBOM       = "\uFEFF"
+)
+
+// DAT fields a production can include
+const (
+	DATFieldBegBates   = "BegBates"
+	DATFieldEndBates   = "EndBates"
+	DATFieldCustodian  = "Custodian"
+	DATFieldFrom       = "From"
+	DATFieldTo         = "To"
+	DATFieldSubject    = "Subject"
+	DATFieldDateSent   = "DateSent"
+	DATFieldMD5        = "MD5"
+	DATFieldNativePath = "NativePath"
+	DATFieldTextPath   = "TextPath"
+)
+
+// DefaultDATFields is the column order used when a production does not choose fields
+var DefaultDATFields = []string{
+	DATFieldBegBates, DATFieldEndBates, DATFieldCustodian, DATFieldFrom, DATFieldTo,
+	DATFieldSubject, DATFieldDateSent, DATFieldMD5, DATFieldNativePath, DATFieldTextPath,
+}
+
+// imageExtensions are natives produced as images and listed in the OPT cross-reference
+var imageExtensions = map[string]bool{
+	".tif": true, ".tiff": true, ".jpg": true, ".jpeg": true, ".png": true,
+}
+
+// validateDATFields rejects unknown or repeated field names
+func validateDATFields(fields []string) error {
+	known := make(map[string]bool, len(DefaultDATFields))
+	for _, f := range DefaultDATFields {
+		known[f] = true
+	}
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !known[f] {
+			return fmt.Errorf("unknown load file field %q", f)
+		}
+		if seen[f] {
+			return fmt.Errorf("load file field %q listed twice", f)
+		}
+		seen[f] = true
+	}
+	return nil
+}
+
+// isImagePath reports whether a native is an image for OPT purposes
+func isImagePath(path string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// loadFilePath converts a zip entry name to the backslash form review platforms expect
+func loadFilePath(entry string) string {
+	return strings.ReplaceAll(entry, "/", `\`)
+}
+
+// datValue returns one field of a document's DAT record
+// DateSent is only filled for emails; the file date of other documents is not a send date
+// Slip-sheeted documents carry only Bates, custodian and the placeholder's own fields
+// (and redacted text, when they have it); their correspondents and subject belong on
+// the privilege log, not in the production
+func datValue(field string, doc ProducedDocument, file File, custodian string) string {
+	if doc.SlipSheet != "" {
+		switch field {
+		case DATFieldFrom, DATFieldTo, DATFieldSubject, DATFieldDateSent:
+			return ""
+		}
+	}
+	switch field {
+	case DATFieldBegBates:
+		return doc.BegBates
+	case DATFieldEndBates:
+		return doc.EndBates
+	case DATFieldCustodian:
+		return custodian
+	case DATFieldFrom:
+		return file.FromEmail
+	case DATFieldTo:
+		return file.ToEmail
+	case DATFieldSubject:
+		return file.Subject
+	case DATFieldDateSent:
+		if file.Category == "email" {
+			return file.Date.Format("01/02/2006")
+		}
+	case DATFieldMD5:
+		return doc.MD5
+	case DATFieldNativePath:
+		return loadFilePath(doc.NativePath)
+	case DATFieldTextPath:
+		return loadFilePath(doc.TextPath)
+	}
+	return ""
+}
+
+// datLine joins values into one quoted, delimited DAT record
+func datLine(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		v = strings.ReplaceAll(v, "\r\n", datNewline)
+		v = strings.NewReplacer("\n", datNewline, "\r", datNewline, datQuote, "").Replace(v)
+		quoted[i] = datQuote + v + datQuote
+	}
+	return strings.Join(quoted, datSeparator) + "\r\n"
+}
+
+// writeDAT writes a UTF-8 Concordance DAT with a header row followed by one record per document
+func writeDAT(w io.Writer, fields []string, docs []ProducedDocument, files map[int64]File, custodian string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(datBOM)
+	bw.WriteString(datLine(fields))
+	for _, doc := range docs {
+		values := make([]string, len(fields))
+		for i, field := range fields {
+			values[i] = datValue(field, doc, files[doc.FileID], custodian)
+		}
+		bw.WriteString(datLine(values))
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write DAT: %w", err)
+	}
+	return nil
+}
+
+// writeOPT writes the Opticon image cross-reference for documents produced as images
+// A withheld image is produced as a PDF slip sheet, so it is keyed on the produced file
+// Each line is BatesNumber,Volume,ImagePath,DocBreak,FolderBreak,BoxBreak,PageCount
+func writeOPT(w io.Writer, docs []ProducedDocument) error {
+	bw := bufio.NewWriter(w)
+	for _, doc := range docs {
+		if !isImagePath(doc.NativePath) {
+			continue
+		}
+		fmt.Fprintf(bw, "%s,%s,%s,Y,,,%d\r\n", doc.BegBates, doc.Volume, loadFilePath(doc.NativePath), doc.Pages)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write OPT: %w", err)
+	}
+	return nil
+}
+
+// hasImages reports whether any produced document needs an OPT entry
+func hasImages(docs []ProducedDocument) bool {
+	for _, doc := range docs {
+		if isImagePath(doc.NativePath) {
+			return true
+		}
+	}
+	return false
+}
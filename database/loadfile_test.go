@@ -0,0 +1,75 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDATEncodesDelimitersAndNewlines(t *testing.T) {
+	docs := []ProducedDocument{{
+		FileID: 1, BegBates: "DOI0000001", EndBates: "DOI0000002",
+		NativePath: "VOL001/NATIVES/DOI0000001-DOI0000002.pdf", MD5: "abc",
+	}}
+	files := map[int64]File{1: {
+		ID: 1, Category: "email", Subject: "Line one\r\nline two\nþquoted", FromEmail: "a@doi.gov",
+		Date: time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC),
+	}}
+	var b bytes.Buffer
+	fields := []string{DATFieldBegBates, DATFieldSubject, DATFieldDateSent, DATFieldNativePath}
+	if err := writeDAT(&b, fields, docs, files, "Custodian"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := datBOM +
+		"þBegBatesþ\x14þSubjectþ\x14þDateSentþ\x14þNativePathþ\r\n" +
+		"þDOI0000001þ\x14þLine one®line two®quotedþ\x14þ07/04/2023þ\x14þVOL001\\NATIVES\\DOI0000001-DOI0000002.pdfþ\r\n"
+	if b.String() != want {
+		t.Errorf("DAT is\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestDATValueOmitsCorrespondentsOfSlipSheets(t *testing.T) {
+	file := File{Category: "email", Subject: "Advice", FromEmail: "counsel@sol.doi.gov"}
+	doc := ProducedDocument{BegBates: "DOI0000009", SlipSheet: SlipSheetPrivileged}
+	for _, field := range []string{DATFieldFrom, DATFieldTo, DATFieldSubject, DATFieldDateSent} {
+		if v := datValue(field, doc, file, ""); v != "" {
+			t.Errorf("%s of a slip sheet is %q", field, v)
+		}
+	}
+	if v := datValue(DATFieldBegBates, doc, file, ""); v != "DOI0000009" {
+		t.Errorf("BegBates of a slip sheet is %q", v)
+	}
+}
+
+func TestWriteOPTListsProducedImagesOnly(t *testing.T) {
+	docs := []ProducedDocument{
+		{BegBates: "DOI0000001", Volume: "VOL001", Path: "scan.tif", NativePath: "VOL001/NATIVES/DOI0000001-DOI0000003.tif", Pages: 3},
+		// A withheld image goes out as a PDF slip sheet
+		{BegBates: "DOI0000004", Volume: "VOL001", Path: "photo.tif", NativePath: "VOL001/NATIVES/DOI0000004.pdf", Pages: 1, SlipSheet: SlipSheetPrivileged},
+		{BegBates: "DOI0000005", Volume: "VOL001", Path: "memo.pdf", NativePath: "VOL001/NATIVES/DOI0000005.pdf", Pages: 1},
+	}
+	var b bytes.Buffer
+	if err := writeOPT(&b, docs); err != nil {
+		t.Fatal(err)
+	}
+	want := "DOI0000001,VOL001,VOL001\\NATIVES\\DOI0000001-DOI0000003.tif,Y,,,3\r\n"
+	if b.String() != want {
+		t.Errorf("OPT is %q, want %q", b.String(), want)
+	}
+	if hasImages(docs[1:]) {
+		t.Error("a slip-sheeted image and a PDF need no OPT")
+	}
+}
+
+func TestValidateDATFields(t *testing.T) {
+	if err := validateDATFields([]string{DATFieldBegBates, DATFieldMD5}); err != nil {
+		t.Error(err)
+	}
+	for _, fields := range [][]string{{"Bates"}, {DATFieldMD5, DATFieldMD5}} {
+		if err := validateDATFields(fields); err == nil || !strings.Contains(err.Error(), "load file field") {
+			t.Errorf("%v: got %v", fields, err)
+		}
+	}
+}
@@ -89,12 +89,6 @@ func (d *DB) SeedMockData() error {
 			// Generate file name
 			fileName := fmt.Sprintf("file_%d_%d.pdf", fileCount, rand.Intn(10000))
 
-			// Determine if privileged (only for emails, 20% chance)
-			privileged := false
-			if dir.category == "email" && rand.Float32() < 0.2 {
-				privileged = true
-			}
-
 			// Create some duplicates (10% chance of being a duplicate)
 			duplicateHash := ""
 			if rand.Float32() < 0.1 && len(duplicateHashes) > 0 {
@@ -173,13 +167,13 @@ func (d *DB) SeedMockData() error {
 			`
 
 			path := fmt.Sprintf("%s/%s", dir.name, fileName)
-			_, err := d.db.Exec(query,
+			result, err := d.db.Exec(query,
 				path,
 				dir.name,
 				dir.category,
 				fileDate.Format(time.RFC3339),
 				fileSize,
-				privileged,
+				false, // Only a reviewer confirming a privilege screen hit marks a file privileged
 				duplicateHash,
 				fileName,
 				subject,  // NULL for non-email files
@@ -192,59 +186,32 @@ func (d *DB) SeedMockData() error {
 			if err != nil {
 				return fmt.Errorf("failed to insert file: %w", err)
 			}
+			fileID, err := result.LastInsertId()
+			if err != nil {
+				return fmt.Errorf("failed to get file ID: %w", err)
+			}
+			err = appendAudit(d.db, AuditIngest, fileID, 0, AuditActorSystem, map[string]interface{}{
+				"path": path,
+				"size": fileSize,
+			})
+			if err != nil {
+				return err
+			}
 
 			fileCount++
 		}
 	}
 
-	// Insert production requests
-	productionRequests := []struct {
-		id          string
-		title       string
-		description string
-	}{
-		{"PR-001", "Production Request #1", "Email communication timeline"},
-		{"PR-002", "Production Request #2", "Claims-related communications"},
-		{"PR-003", "Production Request #3", "Document review"},
-		{"PR-004", "Production Request #4", "Evidence compilation"},
-		{"PR-005", "Production Request #5", "Correspondence analysis"},
-	}
-
-	for _, pr := range productionRequests {
-		query := `
-			INSERT OR REPLACE INTO production_requests (id, title, description)
-			VALUES (?, ?, ?)
-		`
-		_, err := d.db.Exec(query, pr.id, pr.title, pr.description)
-		if err != nil {
-			return fmt.Errorf("failed to insert production request: %w", err)
-		}
-	}
-
-	logging.LogResult("SeedMockData", fileCount, map[string]interface{}{
-		"production_requests": len(productionRequests),
-		"topics_available":     len(topics),
-		"internal_emails":      len(internalEmails),
-		"external_emails":     len(externalEmails),
-	})
-
-	op.EndOperationWithResult(map[string]interface{}{
-		"files_seeded":         fileCount,
-		"production_requests": len(productionRequests),
-	})
-
 	logging.LogResult("SeedMockData", fileCount, map[string]interface{}{
-		"production_requests": len(productionRequests),
-		"topics_available":     len(topics),
-		"internal_emails":     len(internalEmails),
-		"external_emails":     len(externalEmails),
+		"topics_available": len(topics),
+		"internal_emails":  len(internalEmails),
+		"external_emails":  len(externalEmails),
 	})
 
 	op.EndOperationWithResult(map[string]interface{}{
-		"files_seeded":        fileCount,
-		"production_requests": len(productionRequests),
+		"files_seeded": fileCount,
 	})
 
-	fmt.Printf("Seeded %d files and %d production requests\n", fileCount, len(productionRequests))
+	fmt.Printf("Seeded %d files\n", fileCount)
 	return nil
 }
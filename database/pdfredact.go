@@ -0,0 +1,669 @@
+package database
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errPDFNotTextBased is returned for PDFs whose text cannot be found and rewritten
+// in their content streams: scans, encrypted files, compressed object streams,
+// embedded-font encodings and text drawn through form XObjects
+var errPDFNotTextBased = errors.New("not a text-based PDF")
+
+var (
+	pdfObjRegex      = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfRefRegex      = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+	pdfLengthRegex   = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	pdfFilterRegex   = regexp.MustCompile(`/Filter\s*(/[A-Za-z0-9]+|\[[^\]]*\])`)
+	pdfContentsRegex = regexp.MustCompile(`/Contents\s*(\[[^\]]*\]|\d+\s+\d+\s+R)`)
+	pdfAnnotsRegex   = regexp.MustCompile(`/Annots\s*(\[[^\]]*\]|\d+\s+\d+\s+R)`)
+	pdfKidsRegex     = regexp.MustCompile(`/Kids\s*\[([^\]]*)\]`)
+	pdfPagesRegex    = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pdfRootRegex     = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R`)
+	pdfPagesRefRegex = regexp.MustCompile(`/Pages\s+(\d+)\s+\d+\s+R`)
+	pdfInfoRegex     = regexp.MustCompile(`/Info\s+\d+\s+\d+\s+R`)
+	pdfMetadataRegex = regexp.MustCompile(`/Metadata\s+\d+\s+\d+\s+R`)
+	pdfIDRegex       = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+	pdfObjStmRegex   = regexp.MustCompile(`/Type\s*/(ObjStm|XRef)\b`)
+	pdfFormRegex     = regexp.MustCompile(`/Subtype\s*/Form\b`)
+)
+
+// pdfObject is one indirect object; stream is nil for objects without a stream
+type pdfObject struct {
+	gen    int
+	dict   []byte // Object body, or the stream dictionary
+	stream []byte // Raw (possibly compressed) stream data
+}
+
+// pdfFile is a PDF parsed far enough to find and rewrite page text
+// Later definitions of an object replace earlier ones, so incremental updates collapse
+// into a single revision when the file is written back out
+type pdfFile struct {
+	header  string
+	objects map[int]*pdfObject
+	trailer []byte
+}
+
+// pdfTextRun is one string shown on a page by Tj, TJ, ' or "
+type pdfTextRun struct {
+	start, end int    // Span of the string token in the decoded content stream
+	text       []byte // String bytes after escapes are resolved
+	offset     int    // Where text starts in the page text
+}
+
+// pdfContent is a page content stream with the text it shows
+type pdfContent struct {
+	num     int
+	content []byte // Decoded stream
+	runs    []pdfTextRun
+}
+
+// parsePDF reads the objects and trailer of a PDF with a classic cross-reference table
+func parsePDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF")
+	}
+	pdf := &pdfFile{objects: make(map[int]*pdfObject)}
+	header := data
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		header = data[:i]
+	}
+	pdf.header = string(header)
+
+	pos := 0
+	for {
+		loc := pdfObjRegex.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		gen, _ := strconv.Atoi(string(data[pos+loc[4] : pos+loc[5]]))
+		bodyStart := pos + loc[1]
+		endobj := bytes.Index(data[bodyStart:], []byte("endobj"))
+		if endobj < 0 {
+			return nil, fmt.Errorf("object %d is not terminated", num)
+		}
+		body := data[bodyStart : bodyStart+endobj]
+		next := bodyStart + endobj + len("endobj")
+
+		obj := &pdfObject{gen: gen, dict: bytes.TrimSpace(body)}
+		if s := pdfStreamKeyword(body); s >= 0 {
+			obj.dict = bytes.TrimSpace(body[:s])
+			dataStart := bodyStart + s + len("stream")
+			if dataStart < len(data) && data[dataStart] == '\r' {
+				dataStart++
+			}
+			if dataStart < len(data) && data[dataStart] == '\n' {
+				dataStart++
+			}
+
+			// Trust a direct /Length when endstream follows it; otherwise search for endstream
+			dataEnd := -1
+			if m := pdfLengthRegex.FindSubmatch(obj.dict); m != nil && m[2] == nil {
+				n, _ := strconv.Atoi(string(m[1]))
+				if dataStart+n <= len(data) &&
+					bytes.HasPrefix(bytes.TrimLeft(data[dataStart+n:], "\r\n "), []byte("endstream")) {
+					dataEnd = dataStart + n
+				}
+			}
+			if dataEnd < 0 {
+				i := bytes.Index(data[dataStart:], []byte("endstream"))
+				if i < 0 {
+					return nil, fmt.Errorf("stream in object %d is not terminated", num)
+				}
+				dataEnd = dataStart + i
+				if dataEnd > dataStart && data[dataEnd-1] == '\n' {
+					dataEnd--
+				}
+				if dataEnd > dataStart && data[dataEnd-1] == '\r' {
+					dataEnd--
+				}
+			}
+			obj.stream = data[dataStart:dataEnd]
+
+			e := bytes.Index(data[dataEnd:], []byte("endobj"))
+			if e < 0 {
+				return nil, fmt.Errorf("object %d is not terminated", num)
+			}
+			next = dataEnd + e + len("endobj")
+		}
+
+		if pdfObjStmRegex.Match(obj.dict) {
+			return nil, fmt.Errorf("%w: it uses compressed object streams", errPDFNotTextBased)
+		}
+		if pdfFormRegex.Match(obj.dict) {
+			return nil, fmt.Errorf("%w: it draws content through form XObjects", errPDFNotTextBased)
+		}
+		pdf.objects[num] = obj
+		pos = next
+	}
+
+	t := bytes.LastIndex(data, []byte("trailer"))
+	if t < 0 {
+		return nil, fmt.Errorf("%w: it has no trailer", errPDFNotTextBased)
+	}
+	pdf.trailer = data[t+len("trailer"):]
+	if s := bytes.Index(pdf.trailer, []byte("startxref")); s >= 0 {
+		pdf.trailer = pdf.trailer[:s]
+	}
+	if bytes.Contains(pdf.trailer, []byte("/Encrypt")) {
+		return nil, fmt.Errorf("%w: it is encrypted", errPDFNotTextBased)
+	}
+	return pdf, nil
+}
+
+// pdfStreamKeyword returns the position of the stream keyword in an object body, or -1
+func pdfStreamKeyword(body []byte) int {
+	for from := 0; ; {
+		i := bytes.Index(body[from:], []byte("stream"))
+		if i < 0 {
+			return -1
+		}
+		i += from
+		if i < 3 || string(body[i-3:i]) != "end" {
+			return i
+		}
+		from = i + len("stream")
+	}
+}
+
+// pages returns the object numbers of the page objects in document order
+func (p *pdfFile) pages() ([]int, error) {
+	m := pdfRootRegex.FindSubmatch(p.trailer)
+	if m == nil {
+		return nil, fmt.Errorf("PDF trailer has no /Root")
+	}
+	root, _ := strconv.Atoi(string(m[1]))
+	catalog := p.objects[root]
+	if catalog == nil {
+		return nil, fmt.Errorf("PDF catalog %d is missing", root)
+	}
+	m = pdfPagesRefRegex.FindSubmatch(catalog.dict)
+	if m == nil {
+		return nil, fmt.Errorf("PDF catalog has no page tree")
+	}
+	top, _ := strconv.Atoi(string(m[1]))
+
+	var pages []int
+	visited := make(map[int]bool)
+	var walk func(num int) error
+	walk = func(num int) error {
+		if visited[num] {
+			return fmt.Errorf("PDF page tree loops at object %d", num)
+		}
+		visited[num] = true
+		node := p.objects[num]
+		if node == nil {
+			return fmt.Errorf("PDF page tree object %d is missing", num)
+		}
+		if !pdfPagesRegex.Match(node.dict) {
+			pages = append(pages, num)
+			return nil
+		}
+		kids := pdfKidsRegex.FindSubmatch(node.dict)
+		if kids == nil {
+			return nil
+		}
+		for _, ref := range pdfRefRegex.FindAllSubmatch(kids[1], -1) {
+			kid, _ := strconv.Atoi(string(ref[1]))
+			if err := walk(kid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(top); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// pageContents decodes a page's content streams and finds the text they show
+// Returns the page text, which ends each text object with a newline
+func (p *pdfFile) pageContents(page int) ([]pdfContent, []byte, error) {
+	m := pdfContentsRegex.FindSubmatch(p.objects[page].dict)
+	if m == nil {
+		return nil, nil, nil // Blank page
+	}
+	refs := pdfRefRegex.FindAllSubmatch(m[1], -1)
+	if len(refs) == 1 {
+		// /Contents may point at an array of streams rather than a stream
+		num, _ := strconv.Atoi(string(refs[0][1]))
+		if obj := p.objects[num]; obj != nil && obj.stream == nil && bytes.HasPrefix(obj.dict, []byte("[")) {
+			refs = pdfRefRegex.FindAllSubmatch(obj.dict, -1)
+		}
+	}
+
+	var contents []pdfContent
+	var text []byte
+	for _, ref := range refs {
+		num, _ := strconv.Atoi(string(ref[1]))
+		obj := p.objects[num]
+		if obj == nil || obj.stream == nil {
+			return nil, nil, fmt.Errorf("page content stream %d is missing", num)
+		}
+		content, err := obj.decoded()
+		if err != nil {
+			return nil, nil, err
+		}
+		runs, err := scanPDFContent(content, &text)
+		if err != nil {
+			return nil, nil, err
+		}
+		contents = append(contents, pdfContent{num: num, content: content, runs: runs})
+	}
+	return contents, text, nil
+}
+
+// decoded returns a stream's data with its filter undone; only FlateDecode is supported
+func (o *pdfObject) decoded() ([]byte, error) {
+	if bytes.Contains(o.dict, []byte("/DecodeParms")) {
+		return nil, fmt.Errorf("%w: a content stream uses decode parameters", errPDFNotTextBased)
+	}
+	m := pdfFilterRegex.FindSubmatch(o.dict)
+	if m == nil {
+		return o.stream, nil
+	}
+	filters := strings.Fields(strings.Trim(string(m[1]), "[]"))
+	if len(filters) != 1 || filters[0] != "/FlateDecode" {
+		return nil, fmt.Errorf("%w: a content stream uses %s", errPDFNotTextBased, m[1])
+	}
+	r, err := zlib.NewReader(bytes.NewReader(o.stream))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress content stream: %w", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress content stream: %w", err)
+	}
+	return data, nil
+}
+
+// isPDFWhitespace and isPDFDelimiter classify content stream bytes
+func isPDFWhitespace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// scanPDFContent finds the literal strings shown by a content stream's text operators,
+// appending their bytes to page and returning where each one is
+func scanPDFContent(content []byte, page *[]byte) ([]pdfTextRun, error) {
+	var runs, pending []pdfTextRun
+	hexPending := false
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isPDFWhitespace(c):
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\r' && content[i] != '\n' {
+				i++
+			}
+		case c == '(':
+			text, end, err := readPDFLiteral(content, i)
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, pdfTextRun{start: i, end: end, text: text})
+			i = end
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated hex string in content stream")
+			}
+			hexPending = true
+			i += end + 1
+		case c == '/':
+			i++
+			for i < len(content) && !isPDFWhitespace(content[i]) && !isPDFDelimiter(content[i]) {
+				i++
+			}
+		case isPDFDelimiter(c):
+			i++
+		default:
+			start := i
+			for i < len(content) && !isPDFWhitespace(content[i]) && !isPDFDelimiter(content[i]) {
+				i++
+			}
+			op := string(content[start:i])
+			if strings.IndexByte("+-.0123456789", op[0]) >= 0 {
+				continue // A number operand
+			}
+			// Any operator consumes the operands before it
+			switch op {
+			case "Tj", "TJ", "'", `"`:
+				if hexPending {
+					return nil, fmt.Errorf("%w: text is shown as hex strings, usually glyph IDs of an embedded font", errPDFNotTextBased)
+				}
+				for _, run := range pending {
+					run.offset = len(*page)
+					*page = append(*page, run.text...)
+					runs = append(runs, run)
+				}
+			case "ET":
+				*page = append(*page, '\n')
+			case "BI":
+				// Inline image data is binary; skip to the EI that ends it
+				end := bytes.Index(content[i:], []byte("EI"))
+				for end >= 0 {
+					at := i + end
+					if isPDFWhitespace(content[at-1]) && (at+2 == len(content) || isPDFWhitespace(content[at+2])) {
+						break
+					}
+					next := bytes.Index(content[at+2:], []byte("EI"))
+					if next < 0 {
+						end = -1
+						break
+					}
+					end += 2 + next
+				}
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated inline image in content stream")
+				}
+				i += end + 2
+			}
+			pending, hexPending = nil, false
+		}
+	}
+	return runs, nil
+}
+
+// readPDFLiteral reads the literal string starting at content[i] == '('
+// Returns its bytes with escapes resolved and the position just past the closing ')'
+func readPDFLiteral(content []byte, i int) ([]byte, int, error) {
+	var out []byte
+	depth := 1
+	for j := i + 1; j < len(content); j++ {
+		c := content[j]
+		switch c {
+		case '\\':
+			j++
+			if j >= len(content) {
+				return nil, 0, fmt.Errorf("unterminated string in content stream")
+			}
+			switch e := content[j]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// Line continuation
+				if j+1 < len(content) && content[j+1] == '\n' {
+					j++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && j+1 < len(content) && content[j+1] >= '0' && content[j+1] <= '7'; k++ {
+						j++
+						v = v*8 + int(content[j]-'0')
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return out, j + 1, nil
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return nil, 0, fmt.Errorf("unterminated string in content stream")
+}
+
+// pdfLiteral writes bytes as a PDF literal string, escaping anything outside printable ASCII
+func pdfLiteral(text []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte('(')
+	for _, c := range text {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 32 || c > 126:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.Bytes()
+}
+
+// pdfPageTexts returns the text of each page, as shown by its content streams
+func pdfPageTexts(data []byte) ([][]byte, error) {
+	pdf, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := pdf.pages()
+	if err != nil {
+		return nil, err
+	}
+	texts := make([][]byte, len(pages))
+	for i, page := range pages {
+		if _, texts[i], err = pdf.pageContents(page); err != nil {
+			return nil, err
+		}
+	}
+	return texts, nil
+}
+
+// redactPDF removes the redacted characters from a text-based PDF
+// Each redacted character is replaced by 'X' inside the content stream, so the text is
+// gone rather than covered; rewritten streams are stored uncompressed, annotations on
+// redacted pages and the document metadata are dropped, and only objects the result
+// still uses are written, so earlier revisions of the file are not carried over
+func redactPDF(data []byte, redactions []Redaction) ([]byte, error) {
+	pdf, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := pdf.pages()
+	if err != nil {
+		return nil, err
+	}
+	byPage := make(map[int][]Redaction)
+	for _, r := range redactions {
+		if r.Page < 1 || r.Page > len(pages) {
+			return nil, fmt.Errorf("redaction %d is on page %d of a %d-page PDF", r.ID, r.Page, len(pages))
+		}
+		byPage[r.Page] = append(byPage[r.Page], r)
+	}
+
+	for i, page := range pages {
+		pageRedactions := byPage[i+1]
+		if len(pageRedactions) == 0 {
+			continue
+		}
+		contents, text, err := pdf.pageContents(page)
+		if err != nil {
+			return nil, err
+		}
+		redacted := make([]bool, len(text))
+		for _, r := range pageRedactions {
+			if r.End > len(text) {
+				return nil, fmt.Errorf("redaction %d ends at %d but page %d has %d characters; the PDF has changed since it was redacted",
+					r.ID, r.End, r.Page, len(text))
+			}
+			for k := r.Start; k < r.End; k++ {
+				redacted[k] = true
+			}
+		}
+
+		for _, c := range contents {
+			var b bytes.Buffer
+			pos := 0
+			for _, run := range c.runs {
+				replaced := append([]byte(nil), run.text...)
+				for k := range replaced {
+					if redacted[run.offset+k] {
+						replaced[k] = 'X'
+					}
+				}
+				b.Write(c.content[pos:run.start])
+				b.Write(pdfLiteral(replaced))
+				pos = run.end
+			}
+			b.Write(c.content[pos:])
+
+			obj := pdf.objects[c.num]
+			dict := pdfFilterRegex.ReplaceAll(obj.dict, nil)
+			dict = pdfLengthRegex.ReplaceAll(dict, []byte(fmt.Sprintf("/Length %d", b.Len())))
+			obj.dict, obj.stream = dict, b.Bytes()
+		}
+		// Annotations can carry their own text (comments, form values); they are removed
+		// along with the page's reference to them, which may be to an array object
+		pageDict := pdf.objects[page].dict
+		if m := pdfAnnotsRegex.FindSubmatch(pageDict); m != nil {
+			for _, ref := range pdfRefRegex.FindAllSubmatch(m[1], -1) {
+				num, _ := strconv.Atoi(string(ref[1]))
+				if array := pdf.objects[num]; array != nil && bytes.HasPrefix(array.dict, []byte("[")) {
+					for _, item := range pdfRefRegex.FindAllSubmatch(array.dict, -1) {
+						annot, _ := strconv.Atoi(string(item[1]))
+						delete(pdf.objects, annot)
+					}
+				}
+				delete(pdf.objects, num)
+			}
+			pdf.objects[page].dict = pdfAnnotsRegex.ReplaceAll(pageDict, nil)
+		}
+	}
+
+	// The document information dictionary and XMP metadata often repeat the title or
+	// subject that was redacted, so neither is carried over; nor is anything the
+	// rewritten document no longer refers to, such as an annotation's appearance stream
+	pdf.trailer = pdfInfoRegex.ReplaceAll(pdf.trailer, nil)
+	if root := pdf.objects[pdf.rootNum()]; root != nil {
+		root.dict = pdfMetadataRegex.ReplaceAll(root.dict, nil)
+	}
+	pdf.dropUnreachable()
+	return pdf.bytes(), nil
+}
+
+// rootNum returns the object number of the document catalog, or 0 when there is none
+func (p *pdfFile) rootNum() int {
+	m := pdfRootRegex.FindSubmatch(p.trailer)
+	if m == nil {
+		return 0
+	}
+	num, _ := strconv.Atoi(string(m[1]))
+	return num
+}
+
+// dropUnreachable removes objects that cannot be reached from the catalog or the
+// trailer. References are only looked for in dictionaries; stream data holds none
+func (p *pdfFile) dropUnreachable() {
+	reached := make(map[int]bool)
+	var queue []int
+	for _, ref := range pdfRefRegex.FindAllSubmatch(p.trailer, -1) {
+		num, _ := strconv.Atoi(string(ref[1]))
+		queue = append(queue, num)
+	}
+	for len(queue) > 0 {
+		num := queue[0]
+		queue = queue[1:]
+		obj := p.objects[num]
+		if obj == nil || reached[num] {
+			continue
+		}
+		reached[num] = true
+		for _, ref := range pdfRefRegex.FindAllSubmatch(obj.dict, -1) {
+			next, _ := strconv.Atoi(string(ref[1]))
+			queue = append(queue, next)
+		}
+	}
+	for num := range p.objects {
+		if !reached[num] {
+			delete(p.objects, num)
+		}
+	}
+}
+
+// bytes writes the PDF back out with a fresh cross-reference table
+func (p *pdfFile) bytes() []byte {
+	nums := make([]int, 0, len(p.objects))
+	for num := range p.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	size := 1
+	if len(nums) > 0 {
+		size = nums[len(nums)-1] + 1
+	}
+
+	var b bytes.Buffer
+	b.WriteString(p.header + "\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make(map[int]int, len(nums))
+	for _, num := range nums {
+		obj := p.objects[num]
+		offsets[num] = b.Len()
+		fmt.Fprintf(&b, "%d %d obj\n", num, obj.gen)
+		b.Write(obj.dict)
+		if obj.stream != nil {
+			b.WriteString("\nstream\n")
+			b.Write(obj.stream)
+			b.WriteString("\nendstream")
+		}
+		b.WriteString("\nendobj\n")
+	}
+
+	// Cross-reference entries are exactly 20 bytes; see SlipSheetPDF
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for num := 1; num < size; num++ {
+		if offset, ok := offsets[num]; ok {
+			fmt.Fprintf(&b, "%010d %05d n \n", offset, p.objects[num].gen)
+		} else {
+			b.WriteString("0000000000 00000 f \n")
+		}
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d %s", size, pdfRootRegex.Find(p.trailer))
+	if info := pdfInfoRegex.Find(p.trailer); info != nil {
+		fmt.Fprintf(&b, " %s", info)
+	}
+	if id := pdfIDRegex.Find(p.trailer); id != nil {
+		fmt.Fprintf(&b, " %s", id)
+	}
+	fmt.Fprintf(&b, " >>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return b.Bytes()
+}
+
+// latin1String converts PDF string bytes to text one character per byte, so string
+// offsets in PDF page text line up with character positions
+func latin1String(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
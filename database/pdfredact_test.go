@@ -0,0 +1,119 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes objects 1..n with a classic cross-reference table; object 1 is the catalog
+func buildPDF(objects []string, trailer string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return b.Bytes()
+}
+
+// annotatedPDF is one page showing text, with an indirect /Annots array, document
+// information and XMP metadata that all repeat the sensitive word
+func annotatedPDF(text string) []byte {
+	content := fmt.Sprintf("BT /F1 12 Tf 72 700 Td (%s) Tj ET", text)
+	return buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Metadata 9 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> /Annots 6 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"[7 0 R]",
+		"<< /Type /Annot /Subtype /Text /Rect [0 0 10 10] /Contents (Comment on Bluebird) /AP << /N 10 0 R >> >>",
+		"<< /Title (Bluebird staffing) >>",
+		"<< /Type /Metadata /Subtype /XML /Length 20 >>\nstream\n<x>Bluebird memo</x>\n\nendstream",
+		"<< /Length 12 >>\nstream\n(Bluebird) Tj\nendstream",
+		"<< /Note (an object nothing refers to: Bluebird) >>",
+	}, "/Info 8 0 R")
+}
+
+func TestRedactPDFRemovesTextAnnotationsAndMetadata(t *testing.T) {
+	data := annotatedPDF("Reassign the Bluebird team")
+	texts, err := pdfPageTexts(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := bytes.Index(texts[0], []byte("Bluebird"))
+	if start < 0 {
+		t.Fatalf("page text is %q", texts[0])
+	}
+
+	out, err := redactPDF(data, []Redaction{{ID: 1, Page: 1, Start: start, End: start + len("Bluebird")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("Bluebird")) {
+		t.Errorf("redacted PDF still contains the redacted word:\n%s", out)
+	}
+	for _, gone := range []string{"/Annots", "/Info", "/Metadata"} {
+		if bytes.Contains(out, []byte(gone)) {
+			t.Errorf("redacted PDF still has %s", gone)
+		}
+	}
+
+	texts, err = pdfPageTexts(out)
+	if err != nil {
+		t.Fatalf("redacted PDF does not parse: %v", err)
+	}
+	if want := "Reassign the XXXXXXXX team"; !strings.Contains(string(texts[0]), want) {
+		t.Errorf("page text is %q, want it to contain %q", texts[0], want)
+	}
+}
+
+func TestRedactPDFWritesValidCrossReferences(t *testing.T) {
+	out, err := redactPDF(annotatedPDF("Bluebird"), []Redaction{{ID: 1, Page: 1, Start: 0, End: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf, err := parsePDF(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every in-use xref entry must point at its object
+	xref := bytes.LastIndex(out, []byte("\nxref\n")) + 1
+	lines := strings.Split(string(out[xref:]), "\n")
+	var size int
+	fmt.Sscanf(lines[1], "0 %d", &size)
+	entries := lines[3 : 3+size-1]
+	for i, entry := range entries {
+		if !strings.HasSuffix(entry, " n ") {
+			continue
+		}
+		num := i + 1
+		var offset int
+		fmt.Sscanf(entry, "%d", &offset)
+		if !bytes.HasPrefix(out[offset:], []byte(fmt.Sprintf("%d 0 obj", num))) {
+			t.Errorf("xref entry for object %d points at %q", num, out[offset:offset+10])
+		}
+	}
+	if len(pdf.objects) != 5 {
+		t.Errorf("redacted PDF has %d objects, want the 5 the page still uses", len(pdf.objects))
+	}
+}
+
+func TestRedactPDFRejectsStaleRanges(t *testing.T) {
+	data := annotatedPDF("short")
+	if _, err := redactPDF(data, []Redaction{{ID: 4, Page: 1, Start: 0, End: 50}}); err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Errorf("got %v, want a changed-PDF error", err)
+	}
+	if _, err := redactPDF(data, []Redaction{{ID: 5, Page: 2, Start: 0, End: 1}}); err == nil {
+		t.Error("redaction on a missing page was accepted")
+	}
+}
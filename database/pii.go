@@ -0,0 +1,432 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// PII types found by the default rules
+const (
+	PIITypeSSN     = "ssn"
+	PIITypePhone   = "phone"
+	PIITypeDOB     = "dob"
+	PIITypeMedical = "medical"
+)
+
+// Validators a PII rule can run on each match to discard look-alikes
+const (
+	PIICheckSSN   = "ssn"   // Issued SSN ranges; see validSSN
+	PIICheckPhone = "phone" // North American numbering plan; see validPhone
+	PIICheckDate  = "date"  // A real calendar date in the past; see validBirthDate
+)
+
+// piiChecks maps a rule's Check to its validator
+var piiChecks = map[string]func(string) bool{
+	PIICheckSSN:   validSSN,
+	PIICheckPhone: validPhone,
+	PIICheckDate:  validBirthDate,
+}
+
+// PIIRule finds one kind of personal information in extracted text
+// A rule matches either Pattern, a regular expression, or Terms, a lexicon matched
+// case-insensitively on word boundaries. With Near, a match only counts when one of
+// those words appears within NearWindow bytes of it, in the same clause
+type PIIRule struct {
+	Type       string   `json:"type"`
+	Label      string   `json:"label"`
+	Pattern    string   `json:"pattern"`
+	Terms      []string `json:"terms"`
+	Near       []string `json:"near"`
+	NearWindow int      `json:"near_window"` // Defaults to 40
+	Check      string   `json:"check"`       // Validator run on each match; see PIICheckSSN
+}
+
+// PIIFinding is one piece of personal information found in a file's extracted text
+type PIIFinding struct {
+	FileID  int64  `json:"file_id"`
+	Type    string `json:"type"`
+	Matched string `json:"matched"`
+	Snippet string `json:"snippet"`
+	Offset  int    `json:"offset"` // Byte offset in the extracted text, as used by text redactions
+	Length  int    `json:"length"`
+}
+
+// PIIOption is a PII type with the number of files containing it, for the filter UI
+type PIIOption struct {
+	Type      string `json:"type"`
+	Label     string `json:"label"`
+	FileCount int    `json:"file_count"`
+}
+
+// medicalTerms are conditions and treatments that mark medical information
+var medicalTerms = []string{
+	"diagnosis", "diagnosed", "prognosis", "prescription", "prescribed", "medication",
+	"diabetes", "diabetic", "cancer", "chemotherapy", "tumor", "hiv", "aids", "hepatitis",
+	"hypertension", "heart attack", "stroke", "asthma", "copd", "epilepsy", "seizure",
+	"multiple sclerosis", "arthritis", "migraine", "depression", "anxiety", "ptsd",
+	"bipolar", "schizophrenia", "adhd", "autism", "substance abuse", "rehab",
+	"surgery", "physical therapy", "psychiatrist", "psychologist", "therapist",
+	"medical leave", "doctor's note", "treating physician", "hospitalized", "miscarriage",
+}
+
+// DefaultPIIRules returns the built-in PII rules
+// SSNs are found formatted anywhere and as bare nine-digit numbers near "SSN"; dates
+// only count as dates of birth near words like "born"
+func DefaultPIIRules() []PIIRule {
+	const date = `\b(?:\d{1,2}[/-]\d{1,2}[/-](?:\d{4}|\d{2})|\d{4}-\d{2}-\d{2}|` +
+		`(?:jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.? \d{1,2},? \d{4})\b`
+	return []PIIRule{
+		{Type: PIITypeSSN, Label: "Social Security Number", Pattern: `\b\d{3}[- ]\d{2}[- ]\d{4}\b`, Check: PIICheckSSN},
+		{Type: PIITypeSSN, Label: "Social Security Number", Pattern: `\b\d{9}\b`, Check: PIICheckSSN,
+			Near: []string{"ssn", "social security", "ss#", "soc sec"}},
+		{Type: PIITypePhone, Label: "Phone Number", Pattern: `(?:\+?1[-. ]?)?(?:\(\d{3}\)\s?|\b\d{3}[-. ])\d{3}[-. ]\d{4}\b`, Check: PIICheckPhone},
+		{Type: PIITypeDOB, Label: "Date of Birth", Pattern: `(?i)` + date, Check: PIICheckDate,
+			Near: []string{"born", "birth", "birthday", "dob", "d.o.b"}},
+		{Type: PIITypeMedical, Label: "Medical Information", Terms: medicalTerms},
+	}
+}
+
+// LoadPIIRules reads a rule set from a JSON file (an array of PIIRule)
+// Lets a matter add its own identifiers, such as employee numbers, without a rebuild
+func LoadPIIRules(path string) ([]PIIRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load PII rules: %w", err)
+	}
+
+	var rules []PIIRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("could not parse PII rules: %w", err)
+	}
+	if _, err := compilePIIRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// compiledPIIRule pairs a rule with its matchers
+type compiledPIIRule struct {
+	rule    PIIRule
+	pattern *regexp.Regexp
+	near    *regexp.Regexp // nil when the rule needs no nearby word
+	check   func(string) bool
+}
+
+// compilePIIRules validates rules and builds their regexps
+func compilePIIRules(rules []PIIRule) ([]compiledPIIRule, error) {
+	compiled := make([]compiledPIIRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Type == "" {
+			return nil, fmt.Errorf("PII rule %q must have a type", rule.Label)
+		}
+		if (rule.Pattern == "") == (len(rule.Terms) == 0) {
+			return nil, fmt.Errorf("PII rule %q must have either a pattern or terms", rule.Type)
+		}
+
+		cr := compiledPIIRule{rule: rule}
+		source := rule.Pattern
+		if source == "" {
+			source = `(?i)\b(?:` + quotePhrases(rule.Terms) + `)\b`
+		}
+		var err error
+		if cr.pattern, err = regexp.Compile(source); err != nil {
+			return nil, fmt.Errorf("invalid pattern for PII rule %q: %w", rule.Type, err)
+		}
+		if len(rule.Near) > 0 {
+			if cr.near, err = regexp.Compile(`(?i)(?:^|\W)(?:` + quotePhrases(rule.Near) + `)(?:\W|$)`); err != nil {
+				return nil, fmt.Errorf("invalid nearby words for PII rule %q: %w", rule.Type, err)
+			}
+		}
+		if rule.Check != "" {
+			if cr.check = piiChecks[rule.Check]; cr.check == nil {
+				return nil, fmt.Errorf("PII rule %q has unknown check %q", rule.Type, rule.Check)
+			}
+		}
+		compiled = append(compiled, cr)
+	}
+	return compiled, nil
+}
+
+// quotePhrases joins phrases into a regexp alternation
+func quotePhrases(phrases []string) string {
+	alternatives := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		alternatives[i] = regexp.QuoteMeta(strings.ToLower(phrase))
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// matchPII returns every finding in the text, at most one per type and range
+// Later rules do not report ranges an earlier rule of the same type already found
+func matchPII(fileID int64, text string, rules []compiledPIIRule) []PIIFinding {
+	var findings []PIIFinding
+	seen := make(map[string]bool)
+	for _, cr := range rules {
+		window := cr.rule.NearWindow
+		if window <= 0 {
+			window = 40
+		}
+		for _, loc := range cr.pattern.FindAllStringIndex(text, -1) {
+			matched := text[loc[0]:loc[1]]
+			if cr.check != nil && !cr.check(matched) {
+				continue
+			}
+			if cr.near != nil {
+				from, to := loc[0]-window, loc[1]+window
+				if from < 0 {
+					from = 0
+				}
+				if to > len(text) {
+					to = len(text)
+				}
+				// The nearby word must be in the same clause, so "born" does not vouch
+				// for every date on the line
+				if i := strings.LastIndexAny(text[from:loc[0]], ";\n"); i >= 0 {
+					from += i + 1
+				}
+				if i := strings.IndexAny(text[loc[1]:to], ";\n"); i >= 0 {
+					to = loc[1] + i
+				}
+				if !cr.near.MatchString(text[from:to]) {
+					continue
+				}
+			}
+			key := fmt.Sprintf("%s:%d:%d", cr.rule.Type, loc[0], loc[1])
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			findings = append(findings, PIIFinding{
+				FileID:  fileID,
+				Type:    cr.rule.Type,
+				Matched: matched,
+				Snippet: snippetAround(text, loc[0], loc[1], 40),
+				Offset:  loc[0],
+				Length:  loc[1] - loc[0],
+			})
+		}
+	}
+	return findings
+}
+
+// digitsOf strips everything but digits
+func digitsOf(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validSSN applies the issuance rules SSNs follow, since they carry no check digit:
+// no 000, 666 or 9xx area, no 00 group, no 0000 serial, and none of the well-known
+// sample numbers or single repeated digits
+func validSSN(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) != 9 {
+		return false
+	}
+	area, group, serial := digits[:3], digits[3:5], digits[5:]
+	if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+		return false
+	}
+	switch digits {
+	case "123456789", "078051120", "219099999":
+		return false
+	}
+	return strings.Count(digits, digits[:1]) != 9
+}
+
+// validPhone accepts ten-digit North American numbers (with an optional leading 1)
+// whose area code and exchange start with 2-9 and whose area code is not an N11 service code
+func validPhone(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	if len(digits) != 10 {
+		return false
+	}
+	area, exchange := digits[:3], digits[3:6]
+	if area[0] < '2' || exchange[0] < '2' {
+		return false
+	}
+	return area[1:] != "11"
+}
+
+// birthDateLayouts are the date forms the default DOB pattern matches
+var birthDateLayouts = []string{
+	"1/2/2006", "1/2/06", "1-2-2006", "1-2-06", "2006-01-02",
+	"Jan 2, 2006", "Jan 2 2006", "January 2, 2006", "January 2 2006",
+}
+
+// validBirthDate accepts dates that parse and fall between 1900 and today
+// Go reads two-digit years below 69 as 20xx; one that lands in the future is a 19xx
+// birth year, so "5/6/55" is 1955
+func validBirthDate(s string) bool {
+	s = strings.Join(strings.Fields(strings.Replace(s, ".", "", 1)), " ")
+	// Normalise "SEPT" and case so time.Parse's month names match
+	if len(s) > 0 {
+		s = strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+	}
+	s = strings.Replace(s, "Sept ", "Sep ", 1)
+	for _, layout := range birthDateLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(layout, "06") && t.After(time.Now()) {
+			t = t.AddDate(-100, 0, 0)
+		}
+		return t.Year() >= 1900 && t.Before(time.Now())
+	}
+	return false
+}
+
+// DetectPII scans the text ExtractText stored for each file with the given rules
+// Files whose text has not been extracted yet are not scanned
+// Previous findings are replaced, so re-running after editing rules is safe
+// Returns the number of files with at least one finding
+func (d *DB) DetectPII(rules []PIIRule) (int, error) {
+	op := logging.StartOperation("DetectPII", map[string]interface{}{
+		"rule_count": len(rules),
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to detect PII")
+
+	compiled, err := compilePIIRules(rules)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only extracted text is scanned, so offsets line up with text redactions
+	rows, err := d.db.Query("SELECT id, extracted_text FROM files WHERE extracted_text IS NOT NULL AND extracted_text != '' ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query extracted text: %w", err)
+	}
+	var findings []PIIFinding
+	scanned := 0
+	for rows.Next() {
+		var id int64
+		var text sql.NullString
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan extracted text: %w", err)
+		}
+		findings = append(findings, matchPII(id, text.String, compiled)...)
+		scanned++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating extracted text: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM pii_findings"); err != nil {
+		return 0, fmt.Errorf("failed to clear PII findings: %w", err)
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO pii_findings (file_id, type, matched, snippet, offset, length)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare PII finding insert: %w", err)
+	}
+	defer stmt.Close()
+
+	files := make(map[int64]bool)
+	for _, f := range findings {
+		if _, err := stmt.Exec(f.FileID, f.Type, f.Matched, f.Snippet, f.Offset, f.Length); err != nil {
+			return 0, fmt.Errorf("failed to save PII finding: %w", err)
+		}
+		files[f.FileID] = true
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit PII findings: %w", err)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"files_scanned": scanned,
+		"files_flagged": len(files),
+		"findings":      len(findings),
+	})
+	return len(files), nil
+}
+
+// GetPIIFindings returns the PII found in a file, in text order
+func (d *DB) GetPIIFindings(fileID int64) ([]PIIFinding, error) {
+	rows, err := d.db.Query(`
+		SELECT file_id, type, matched, snippet, offset, length
+		FROM pii_findings
+		WHERE file_id = ?
+		ORDER BY offset, type
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query PII findings: %w", err)
+	}
+	defer rows.Close()
+
+	var findings []PIIFinding
+	for rows.Next() {
+		var f PIIFinding
+		if err := rows.Scan(&f.FileID, &f.Type, &f.Matched, &f.Snippet, &f.Offset, &f.Length); err != nil {
+			return nil, fmt.Errorf("failed to scan PII finding: %w", err)
+		}
+		findings = append(findings, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating PII findings: %w", err)
+	}
+	return findings, nil
+}
+
+// GetPIIOptions returns every PII type in the rules with the number of files containing it
+// Types with no findings are included so the filter UI shows the full rule set
+func (d *DB) GetPIIOptions(rules []PIIRule) ([]PIIOption, error) {
+	rows, err := d.db.Query("SELECT type, COUNT(DISTINCT file_id) FROM pii_findings GROUP BY type")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count PII findings: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var piiType string
+		var count int
+		if err := rows.Scan(&piiType, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan PII count: %w", err)
+		}
+		counts[piiType] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating PII counts: %w", err)
+	}
+
+	var options []PIIOption
+	listed := make(map[string]bool)
+	for _, rule := range rules {
+		if listed[rule.Type] {
+			continue
+		}
+		listed[rule.Type] = true
+		options = append(options, PIIOption{Type: rule.Type, Label: rule.Label, FileCount: counts[rule.Type]})
+	}
+	return options, nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidSSN(t *testing.T) {
+	cases := map[string]bool{
+		"212-55-1234": true,
+		"212 55 1234": true,
+		"000-55-1234": false, // No 000 area
+		"666-55-1234": false,
+		"912-55-1234": false, // 9xx is ITIN
+		"212-00-1234": false,
+		"212-55-0000": false,
+		"078-05-1120": false, // The Woolworth wallet card
+		"123-45-6789": false,
+		"555-55-5555": false,
+		"212-55-123":  false,
+	}
+	for s, want := range cases {
+		if got := validSSN(s); got != want {
+			t.Errorf("validSSN(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestValidPhone(t *testing.T) {
+	cases := map[string]bool{
+		"(202) 555-0143":  true,
+		"1-202-555-0143":  true,
+		"202.555.0143":    true,
+		"102-555-0143":    false, // Area code starts with 1
+		"202-155-0143":    false, // Exchange starts with 1
+		"911-555-0143":    false, // N11 service code
+		"202-555-014":     false,
+		"2-202-555-0143x": false,
+	}
+	for s, want := range cases {
+		if got := validPhone(s); got != want {
+			t.Errorf("validPhone(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestValidBirthDate(t *testing.T) {
+	cases := map[string]bool{
+		"5/6/55":         true, // 1955, not 2055
+		"5/6/1955":       true,
+		"12-31-01":       true,
+		"1955-05-06":     true,
+		"SEPT. 3, 1961":  true,
+		"January 2 1970": true,
+		"2/30/1980":      false, // No such day
+		"5/6/1899":       false,
+		"5/6/2999":       false, // Four-digit years are taken as written
+		"13/1/1980":      false,
+	}
+	for s, want := range cases {
+		if got := validBirthDate(s); got != want {
+			t.Errorf("validBirthDate(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestMatchPIIDefaultRules(t *testing.T) {
+	rules, err := compilePIIRules(DefaultPIIRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "Employee SSN 212551234 on file. Born 5/6/55; meeting 5/6/24.\n" +
+		"Call (202) 555-0143. Ref 212551234. Diagnosed with asthma."
+	got := map[string][]string{}
+	for _, f := range matchPII(7, text, rules) {
+		if text[f.Offset:f.Offset+f.Length] != f.Matched {
+			t.Errorf("%s finding %q does not sit at offset %d", f.Type, f.Matched, f.Offset)
+		}
+		got[f.Type] = append(got[f.Type], f.Matched)
+	}
+	want := map[string][]string{
+		PIITypeSSN:     {"212551234"}, // The bare number counts only near "SSN"
+		PIITypeDOB:     {"5/6/55"},    // The meeting date is in another clause
+		PIITypePhone:   {"(202) 555-0143"},
+		PIITypeMedical: {"Diagnosed", "asthma"},
+	}
+	for typ, matches := range want {
+		if strings.Join(got[typ], "|") != strings.Join(matches, "|") {
+			t.Errorf("%s: got %q, want %q", typ, got[typ], matches)
+		}
+	}
+}
+
+func TestLoadPIIRules(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`[{"type": "employee_id", "label": "Employee ID", "pattern": "\\bE\\d{6}\\b"}]`), 0644)
+	rules, err := LoadPIIRules(good)
+	if err != nil || len(rules) != 1 || rules[0].Type != "employee_id" {
+		t.Fatalf("got %v, %v", rules, err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`[{"type": "x", "pattern": "a", "check": "luhn"}]`), 0644)
+	if _, err := LoadPIIRules(bad); err == nil || !strings.Contains(err.Error(), "unknown check") {
+		t.Errorf("got %v, want an unknown check error", err)
+	}
+}
+
+func TestDetectPIIScansExtractedText(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := t.TempDir()
+	moveToLake(t, d, root, ids[0], "Mail/hr.eml", []byte("Subject: Onboarding\r\n\r\nMy SSN is 212-55-1234."))
+	moveToLake(t, d, root, ids[1], "Notes/lunch.txt", []byte("Lunch order for Friday."))
+
+	// Nothing is flagged until the natives' text is extracted
+	if n, err := d.DetectPII(DefaultPIIRules()); err != nil || n != 0 {
+		t.Fatalf("flagged %d files (%v) before extraction, want none", n, err)
+	}
+	if _, err := d.ExtractText(root); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.DetectPII(DefaultPIIRules()); err != nil || n != 1 {
+		t.Fatalf("flagged %d files (%v), want 1", n, err)
+	}
+
+	findings, err := d.GetPIIFindings(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Type != PIITypeSSN || findings[0].Matched != "212-55-1234" {
+		t.Errorf("findings %+v, want the SSN", findings)
+	}
+	found, err := d.SearchFileIDs(FileFilters{PIITypes: []string{PIITypeSSN}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != ids[0] {
+		t.Errorf("PII filter found %v, want [%d]", found, ids[0])
+	}
+}
@@ -0,0 +1,441 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Privilege bases
+const (
+	PrivilegeAttorneyClient      = "attorney_client"
+	PrivilegeWorkProduct         = "work_product"
+	PrivilegeDeliberativeProcess = "deliberative_process"
+)
+
+// Privilege treatments
+const (
+	TreatmentWithheld       = "withheld"        // Withheld in full
+	TreatmentRedacted       = "redacted"        // Produced with privileged portions redacted
+	TreatmentFamilyWithheld = "family_withheld" // Not privileged itself, withheld with a privileged family member
+)
+
+// privilegeBasisLabels are the basis names printed on the log
+var privilegeBasisLabels = map[string]string{
+	PrivilegeAttorneyClient:      "Attorney-Client Privilege",
+	PrivilegeWorkProduct:         "Attorney Work Product",
+	PrivilegeDeliberativeProcess: "Deliberative Process Privilege",
+}
+
+// privilegeTreatmentLabels are the treatment names printed on the log
+var privilegeTreatmentLabels = map[string]string{
+	TreatmentWithheld:       "Withheld",
+	TreatmentRedacted:       "Redacted",
+	TreatmentFamilyWithheld: "Withheld (family)",
+}
+
+// PrivilegeEntry is the privilege claim recorded for one file
+type PrivilegeEntry struct {
+	FileID      int64     `json:"file_id"`
+	Bases       []string  `json:"bases"`       // One or more privilege bases
+	Description string    `json:"description"` // Subject matter, without revealing privileged content
+	Author      string    `json:"author"`
+	Recipients  []string  `json:"recipients"`
+	Treatment   string    `json:"treatment"` // "withheld" or "redacted"
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PrivilegeLogEntry is one row of a privilege log
+type PrivilegeLogEntry struct {
+	LogID        string    `json:"log_id"` // e.g. "PRIV-0001"
+	FileID       int64     `json:"file_id"`
+	FamilyID     string    `json:"family_id"` // e.g. "FAM-000123", the family's first file; empty for a lone file
+	Date         time.Time `json:"date"`
+	DocumentType string    `json:"document_type"`
+	FileName     string    `json:"file_name"`
+	Author       string    `json:"author"`
+	Recipients   []string  `json:"recipients"`
+	Description  string    `json:"description"`
+	Bases        []string  `json:"bases"`
+	Treatment    string    `json:"treatment"`
+	Complete     bool      `json:"complete"` // Has a basis and a description
+}
+
+// PrivilegeLog is a generated privilege log
+type PrivilegeLog struct {
+	ProductionRequestID string              `json:"production_request_id"`
+	GeneratedAt         time.Time           `json:"generated_at"`
+	Entries             []PrivilegeLogEntry `json:"entries"`
+	Incomplete          []int64             `json:"incomplete"` // Privileged files still missing a basis or description
+}
+
+// isValidPrivilegeBasis reports whether basis is a known privilege basis
+func isValidPrivilegeBasis(basis string) bool {
+	_, ok := privilegeBasisLabels[basis]
+	return ok
+}
+
+// SetPrivilegeEntry records a privilege claim for a file and marks the file privileged
+// Author and recipients default to the email sender and recipient when left empty
+func (d *DB) SetPrivilegeEntry(entry PrivilegeEntry) error {
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to record privilege")
+
+	if len(entry.Bases) == 0 {
+		return fmt.Errorf("at least one privilege basis is required")
+	}
+	for _, basis := range entry.Bases {
+		if !isValidPrivilegeBasis(basis) {
+			return fmt.Errorf("unknown privilege basis %q", basis)
+		}
+	}
+	if entry.Treatment == "" {
+		entry.Treatment = TreatmentWithheld
+	}
+	if entry.Treatment != TreatmentWithheld && entry.Treatment != TreatmentRedacted {
+		return fmt.Errorf("unknown privilege treatment %q", entry.Treatment)
+	}
+
+	if entry.Author == "" || len(entry.Recipients) == 0 {
+		var fromEmail, toEmail sql.NullString
+		err := d.db.QueryRow("SELECT from_email, to_email FROM files WHERE id = ?", entry.FileID).Scan(&fromEmail, &toEmail)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("file %d not found", entry.FileID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get file: %w", err)
+		}
+		if entry.Author == "" {
+			entry.Author = fromEmail.String
+		}
+		if len(entry.Recipients) == 0 && toEmail.String != "" {
+			entry.Recipients = []string{toEmail.String}
+		}
+	}
+
+	basesJSON, err := json.Marshal(entry.Bases)
+	if err != nil {
+		return fmt.Errorf("failed to encode privilege bases: %w", err)
+	}
+	if entry.Recipients == nil {
+		entry.Recipients = []string{}
+	}
+	recipientsJSON, err := json.Marshal(entry.Recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encode recipients: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO privilege_entries (file_id, bases, description, author, recipients, treatment, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(file_id) DO UPDATE SET
+			bases = excluded.bases,
+			description = excluded.description,
+			author = excluded.author,
+			recipients = excluded.recipients,
+			treatment = excluded.treatment,
+			updated_at = excluded.updated_at
+	`, entry.FileID, string(basesJSON), strings.TrimSpace(entry.Description), entry.Author, string(recipientsJSON),
+		entry.Treatment, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record privilege entry: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE files SET privileged = 1 WHERE id = ?", entry.FileID); err != nil {
+		return fmt.Errorf("failed to mark file privileged: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ClearPrivilegeEntry removes a file's privilege claim and unmarks it
+func (d *DB) ClearPrivilegeEntry(fileID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM privilege_entries WHERE file_id = ?", fileID); err != nil {
+		return fmt.Errorf("failed to delete privilege entry: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE files SET privileged = 0 WHERE id = ?", fileID); err != nil {
+		return fmt.Errorf("failed to unmark file privileged: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetPrivilegeEntry returns a file's privilege claim, or nil if none is recorded
+func (d *DB) GetPrivilegeEntry(fileID int64) (*PrivilegeEntry, error) {
+	var e PrivilegeEntry
+	var basesJSON, recipientsJSON, updatedAt string
+	err := d.db.QueryRow(`
+		SELECT file_id, bases, description, author, recipients, treatment, updated_at
+		FROM privilege_entries WHERE file_id = ?
+	`, fileID).Scan(&e.FileID, &basesJSON, &e.Description, &e.Author, &recipientsJSON, &e.Treatment, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get privilege entry: %w", err)
+	}
+	if err := json.Unmarshal([]byte(basesJSON), &e.Bases); err != nil {
+		return nil, fmt.Errorf("failed to decode privilege bases: %w", err)
+	}
+	if err := json.Unmarshal([]byte(recipientsJSON), &e.Recipients); err != nil {
+		return nil, fmt.Errorf("failed to decode recipients: %w", err)
+	}
+	e.UpdatedAt = parseTimestamp(updatedAt)
+	return &e, nil
+}
+
+// GeneratePrivilegeLog builds the privilege log for the files matching filters
+// A file is logged when it is flagged privileged or coded privileged for
+// filters.ProductionRequestID; ExcludePrivileged is ignored
+// With withholdFamilies, non-privileged members of a family with a withheld
+// privileged member are logged as withheld with their family; otherwise they
+// are left to be produced and only the family ID ties them together
+func (d *DB) GeneratePrivilegeLog(filters FileFilters, withholdFamilies bool) (*PrivilegeLog, error) {
+	op := logging.StartOperation("GeneratePrivilegeLog", map[string]interface{}{
+		"production_request_id": filters.ProductionRequestID,
+		"withhold_families":     withholdFamilies,
+	})
+	defer op.EndOperation()
+
+	filters.ExcludePrivileged = false
+	ids, err := d.SearchFileIDs(filters)
+	if err != nil {
+		return nil, err
+	}
+	inScope := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		inScope[id] = true
+	}
+
+	families, err := d.fileFamilies()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT f.id, f.date, f.category, f.file_name, f.from_email, f.to_email, f.privileged,
+		       (SELECT decision FROM review_decisions WHERE file_id = f.id AND production_request_id = ?),
+		       p.bases, p.description, p.author, p.recipients, p.treatment
+		FROM files f
+		LEFT JOIN privilege_entries p ON p.file_id = f.id
+		ORDER BY f.date, f.id
+	`, filters.ProductionRequestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query privileged files: %w", err)
+	}
+	defer rows.Close()
+
+	type fileRow struct {
+		entry      PrivilegeLogEntry
+		privileged bool
+	}
+	var ordered []fileRow
+	byID := make(map[int64]int)
+	for rows.Next() {
+		var r fileRow
+		var dateStr string
+		var fromEmail, toEmail, decision, bases, description, author, recipients, treatment sql.NullString
+		if err := rows.Scan(&r.entry.FileID, &dateStr, &r.entry.DocumentType, &r.entry.FileName, &fromEmail, &toEmail,
+			&r.privileged, &decision, &bases, &description, &author, &recipients, &treatment); err != nil {
+			return nil, fmt.Errorf("failed to scan privileged file: %w", err)
+		}
+		r.entry.Date, _ = time.Parse(time.RFC3339, dateStr)
+		r.privileged = r.privileged || decision.String == DecisionPrivileged || bases.Valid
+		r.entry.Treatment = TreatmentWithheld
+		r.entry.Author = fromEmail.String
+		if toEmail.String != "" {
+			r.entry.Recipients = []string{toEmail.String}
+		}
+		if bases.Valid {
+			if err := json.Unmarshal([]byte(bases.String), &r.entry.Bases); err != nil {
+				return nil, fmt.Errorf("failed to decode privilege bases: %w", err)
+			}
+			if err := json.Unmarshal([]byte(recipients.String), &r.entry.Recipients); err != nil {
+				return nil, fmt.Errorf("failed to decode recipients: %w", err)
+			}
+			r.entry.Description = description.String
+			r.entry.Author = author.String
+			r.entry.Treatment = treatment.String
+		}
+		r.entry.Complete = len(r.entry.Bases) > 0 && r.entry.Description != ""
+		r.entry.FamilyID = families[r.entry.FileID]
+		byID[r.entry.FileID] = len(ordered)
+		ordered = append(ordered, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating privileged files: %w", err)
+	}
+
+	// Families with a privileged member withheld in full
+	withheldFamilies := make(map[string]int64)
+	for _, r := range ordered {
+		if inScope[r.entry.FileID] && r.privileged && r.entry.Treatment == TreatmentWithheld && r.entry.FamilyID != "" {
+			if _, ok := withheldFamilies[r.entry.FamilyID]; !ok {
+				withheldFamilies[r.entry.FamilyID] = r.entry.FileID
+			}
+		}
+	}
+
+	log := &PrivilegeLog{
+		ProductionRequestID: filters.ProductionRequestID,
+		GeneratedAt:         time.Now().UTC(),
+	}
+	// Family members point at the privileged document withholding them
+	withheldWith := make(map[int]int64)
+	for _, r := range ordered {
+		if !inScope[r.entry.FileID] {
+			continue
+		}
+		entry := r.entry
+		if !r.privileged {
+			privilegedMember, ok := withheldFamilies[entry.FamilyID]
+			if !withholdFamilies || !ok {
+				continue
+			}
+			entry.Treatment = TreatmentFamilyWithheld
+			entry.Complete = true
+			withheldWith[len(log.Entries)] = privilegedMember
+		} else if !entry.Complete {
+			log.Incomplete = append(log.Incomplete, entry.FileID)
+		}
+		entry.LogID = fmt.Sprintf("PRIV-%04d", len(log.Entries)+1)
+		log.Entries = append(log.Entries, entry)
+	}
+
+	// Descriptions name the privileged document by its log ID, known only now
+	logIDs := make(map[int64]string, len(log.Entries))
+	for _, e := range log.Entries {
+		logIDs[e.FileID] = e.LogID
+	}
+	for i, privilegedMember := range withheldWith {
+		log.Entries[i].Description = "Non-privileged family member withheld with privileged document " + logIDs[privilegedMember]
+	}
+
+	logging.LogResult("GeneratePrivilegeLog", len(log.Entries), map[string]interface{}{
+		"incomplete": len(log.Incomplete),
+	})
+	return log, nil
+}
+
+// fileFamilies maps each file in a family of two or more to its family ID
+func (d *DB) fileFamilies() (map[int64]string, error) {
+	rows, err := d.db.Query("SELECT id, path, category, subject FROM files ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []groupCandidate
+	for rows.Next() {
+		var c groupCandidate
+		var subject sql.NullString
+		if err := rows.Scan(&c.id, &c.path, &c.category, &subject); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		c.subject = subject.String
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating files: %w", err)
+	}
+
+	families := make(map[int64]string)
+	for _, group := range groupCandidates(candidates, false) {
+		if len(group) < 2 {
+			continue
+		}
+		familyID := fmt.Sprintf("FAM-%06d", group[0])
+		for _, id := range group {
+			families[id] = familyID
+		}
+	}
+	return families, nil
+}
+
+// privilegeLogRows renders the log as a header row followed by one row per entry
+func privilegeLogRows(log *PrivilegeLog) [][]string {
+	rows := [][]string{{
+		"Log ID", "Document ID", "Family ID", "Date", "Document Type", "File Name",
+		"Author", "Recipients", "Description", "Privilege Basis", "Treatment",
+	}}
+	for _, e := range log.Entries {
+		bases := make([]string, len(e.Bases))
+		for i, b := range e.Bases {
+			bases[i] = privilegeBasisLabels[b]
+		}
+		date := ""
+		if !e.Date.IsZero() {
+			date = e.Date.Format("2006-01-02")
+		}
+		rows = append(rows, []string{
+			e.LogID,
+			fmt.Sprintf("%d", e.FileID),
+			e.FamilyID,
+			date,
+			e.DocumentType,
+			e.FileName,
+			e.Author,
+			strings.Join(e.Recipients, "; "),
+			e.Description,
+			strings.Join(bases, "; "),
+			privilegeTreatmentLabels[e.Treatment],
+		})
+	}
+	return rows
+}
+
+// ExportPrivilegeLog generates the privilege log and writes it as CSV and XLSX
+// Returns the paths of both files
+func (d *DB) ExportPrivilegeLog(filters FileFilters, withholdFamilies bool, outputDir string) (string, string, error) {
+	log, err := d.GeneratePrivilegeLog(filters, withholdFamilies)
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	name := "privilege_log"
+	if filters.ProductionRequestID != "" {
+		name = filters.ProductionRequestID + "_" + name
+	}
+	name += "_" + log.GeneratedAt.Format("20060102_150405")
+	rows := privilegeLogRows(log)
+
+	csvPath := filepath.Join(outputDir, name+".csv")
+	csvFile, err := os.Create(csvPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create privilege log CSV: %w", err)
+	}
+	w := csv.NewWriter(csvFile)
+	if err := w.WriteAll(rows); err != nil {
+		csvFile.Close()
+		return "", "", fmt.Errorf("failed to write privilege log CSV: %w", err)
+	}
+	if err := csvFile.Close(); err != nil {
+		return "", "", fmt.Errorf("failed to close privilege log CSV: %w", err)
+	}
+
+	xlsxPath := filepath.Join(outputDir, name+".xlsx")
+	if err := WriteXLSX(xlsxPath, "Privilege Log", rows); err != nil {
+		return "", "", err
+	}
+	return csvPath, xlsxPath, nil
+}
@@ -0,0 +1,444 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Reasons a document is flagged by the privilege screen
+const (
+	ScreenReasonCounselAddress = "counsel_address" // Sent by or to a counsel address
+	ScreenReasonCounselName    = "counsel_name"    // Counsel or a counsel office named in the text
+	ScreenReasonPhrase         = "privilege_phrase"
+)
+
+// Privilege review queue statuses
+const (
+	QueueStatusPending   = "pending"   // Awaiting privilege review
+	QueueStatusConfirmed = "confirmed" // Reviewer found it privileged; see privilege_entries
+	QueueStatusRejected  = "rejected"  // Reviewer found it not privileged
+)
+
+// Counsel is an attorney or legal office whose involvement suggests privilege
+// Addresses are full email addresses or "@domain" for a whole office
+type Counsel struct {
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases"` // Other names matched in text, e.g. "RSO"
+	Addresses []string `json:"addresses"`
+}
+
+// PrivilegeScreen configures the privilege screening engine
+type PrivilegeScreen struct {
+	Counsel []Counsel `json:"counsel"`
+	Phrases []string  `json:"phrases"` // Matched case-insensitively on word boundaries
+}
+
+// PrivilegeScreenHit is one piece of evidence that a file may be privileged
+type PrivilegeScreenHit struct {
+	FileID  int64  `json:"file_id"`
+	Reason  string `json:"reason"`
+	Matched string `json:"matched"` // Address, name or phrase as found
+	Counsel string `json:"counsel"` // Counsel the match belongs to; empty for phrases
+	Snippet string `json:"snippet"`
+	Offset  int    `json:"offset"` // Byte offset in the document text; -1 for address matches
+}
+
+// PrivilegeQueueItem is a file awaiting or past privilege review
+type PrivilegeQueueItem struct {
+	FileID     int64      `json:"file_id"`
+	Path       string     `json:"path"`
+	Status     string     `json:"status"`
+	Reasons    []string   `json:"reasons"` // Distinct screen reasons
+	HitCount   int        `json:"hit_count"`
+	Reviewer   string     `json:"reviewer"`
+	Note       string     `json:"note"`
+	ScreenedAt time.Time  `json:"screened_at"`
+	DecidedAt  *time.Time `json:"decided_at"`
+}
+
+// DefaultPrivilegeScreen returns the built-in screen for the matter
+// Counsel are the Regional Solicitor's office and agency counsel; phrases are
+// the usual privilege legends and deliberative-process markers
+func DefaultPrivilegeScreen() PrivilegeScreen {
+	return PrivilegeScreen{
+		Counsel: []Counsel{
+			{"Office of the Solicitor", []string{"Regional Solicitor", "Solicitor's Office", "Field Solicitor"}, []string{"@sol.doi.gov"}},
+			{"Agency Counsel", []string{"agency counsel", "agency representative", "employment law"}, []string{}},
+			{"Outside Counsel", []string{"outside counsel"}, []string{"@legal.com"}},
+		},
+		Phrases: []string{
+			"attorney-client privileged", "attorney client privilege", "privileged and confidential",
+			"attorney work product", "prepared in anticipation of litigation", "at the direction of counsel",
+			"legal advice", "request for legal opinion", "litigation hold",
+			"deliberative process", "predecisional", "pre-decisional", "draft - do not distribute",
+		},
+	}
+}
+
+// LoadPrivilegeScreen reads a screen configuration from a JSON file
+// Lets counsel maintain the attorney list per matter without a rebuild
+func LoadPrivilegeScreen(path string) (*PrivilegeScreen, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load privilege screen: %w", err)
+	}
+
+	var screen PrivilegeScreen
+	if err := json.Unmarshal(data, &screen); err != nil {
+		return nil, fmt.Errorf("could not parse privilege screen: %w", err)
+	}
+	for _, c := range screen.Counsel {
+		if c.Name == "" {
+			return nil, fmt.Errorf("every counsel entry needs a name")
+		}
+	}
+	if len(screen.Counsel) == 0 && len(screen.Phrases) == 0 {
+		return nil, fmt.Errorf("privilege screen has no counsel and no phrases")
+	}
+	return &screen, nil
+}
+
+// compiledCounsel pairs a counsel entry with its name matcher and normalized addresses
+type compiledCounsel struct {
+	counsel   Counsel
+	names     *regexp.Regexp // nil when the entry has no names to match
+	addresses []string       // Lower-cased; "@domain" entries match by suffix
+}
+
+// compiledScreen is a PrivilegeScreen ready for matching
+type compiledScreen struct {
+	counsel []compiledCounsel
+	phrases *regexp.Regexp
+}
+
+// phrasePattern builds a case-insensitive, word-bounded alternation of phrases
+func phrasePattern(phrases []string) (*regexp.Regexp, error) {
+	var alternatives []string
+	for _, phrase := range phrases {
+		if strings.TrimSpace(phrase) != "" {
+			alternatives = append(alternatives, regexp.QuoteMeta(strings.ToLower(strings.TrimSpace(phrase))))
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile(`(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+}
+
+// compilePrivilegeScreen prepares the screen's matchers
+func compilePrivilegeScreen(screen PrivilegeScreen) (*compiledScreen, error) {
+	cs := &compiledScreen{}
+	for _, c := range screen.Counsel {
+		names, err := phrasePattern(append([]string{c.Name}, c.Aliases...))
+		if err != nil {
+			return nil, fmt.Errorf("invalid names for counsel %q: %w", c.Name, err)
+		}
+		compiled := compiledCounsel{counsel: c, names: names}
+		for _, addr := range c.Addresses {
+			if addr = strings.ToLower(strings.TrimSpace(addr)); addr != "" {
+				compiled.addresses = append(compiled.addresses, addr)
+			}
+		}
+		cs.counsel = append(cs.counsel, compiled)
+	}
+
+	phrases, err := phrasePattern(screen.Phrases)
+	if err != nil {
+		return nil, fmt.Errorf("invalid privilege phrases: %w", err)
+	}
+	cs.phrases = phrases
+	return cs, nil
+}
+
+// matchesAddress reports whether email belongs to one of the counsel addresses
+func (c compiledCounsel) matchesAddress(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+	for _, addr := range c.addresses {
+		if strings.HasPrefix(addr, "@") && strings.HasSuffix(email, addr) {
+			return true
+		}
+		if email == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// screenDocument returns every piece of privilege evidence in one file
+func (cs *compiledScreen) screenDocument(fileID int64, fromEmail, toEmail, text string) []PrivilegeScreenHit {
+	var hits []PrivilegeScreenHit
+	addresses := []string{fromEmail}
+	if !strings.EqualFold(strings.TrimSpace(toEmail), strings.TrimSpace(fromEmail)) {
+		addresses = append(addresses, toEmail) // A note to self is one piece of evidence
+	}
+	for _, c := range cs.counsel {
+		for _, email := range addresses {
+			if c.matchesAddress(email) {
+				hits = append(hits, PrivilegeScreenHit{
+					FileID:  fileID,
+					Reason:  ScreenReasonCounselAddress,
+					Matched: email,
+					Counsel: c.counsel.Name,
+					Snippet: fmt.Sprintf("From: %s To: %s", fromEmail, toEmail),
+					Offset:  -1,
+				})
+			}
+		}
+		if c.names == nil {
+			continue
+		}
+		for _, loc := range c.names.FindAllStringIndex(text, -1) {
+			hits = append(hits, PrivilegeScreenHit{
+				FileID:  fileID,
+				Reason:  ScreenReasonCounselName,
+				Matched: text[loc[0]:loc[1]],
+				Counsel: c.counsel.Name,
+				Snippet: snippetAround(text, loc[0], loc[1], 40),
+				Offset:  loc[0],
+			})
+		}
+	}
+	if cs.phrases != nil {
+		for _, loc := range cs.phrases.FindAllStringIndex(text, -1) {
+			hits = append(hits, PrivilegeScreenHit{
+				FileID:  fileID,
+				Reason:  ScreenReasonPhrase,
+				Matched: text[loc[0]:loc[1]],
+				Snippet: snippetAround(text, loc[0], loc[1], 40),
+				Offset:  loc[0],
+			})
+		}
+	}
+	return hits
+}
+
+// ScreenPrivilege runs the screen over every file and queues flagged files for privilege review
+// Evidence is replaced on each run. Files already confirmed or rejected keep their decision;
+// pending files that no longer match are dropped from the queue.
+// The privileged flag is never set here - only a reviewer's confirmation sets it.
+// Returns the number of files pending review
+func (d *DB) ScreenPrivilege(screen PrivilegeScreen) (int, error) {
+	op := logging.StartOperation("ScreenPrivilege", map[string]interface{}{
+		"counsel_count": len(screen.Counsel),
+		"phrase_count":  len(screen.Phrases),
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to screen for privilege")
+
+	compiled, err := compilePrivilegeScreen(screen)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := d.db.Query("SELECT id, subject, file_name, extracted_text, from_email, to_email FROM files ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query files: %w", err)
+	}
+	var hits []PrivilegeScreenHit
+	scanned := 0
+	for rows.Next() {
+		var id int64
+		var fileName string
+		var subject, extractedText, fromEmail, toEmail sql.NullString
+		if err := rows.Scan(&id, &subject, &fileName, &extractedText, &fromEmail, &toEmail); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file: %w", err)
+		}
+		text := documentText(subject.String, fileName, extractedText.String)
+		hits = append(hits, compiled.screenDocument(id, fromEmail.String, toEmail.String, text)...)
+		scanned++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating files: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM privilege_screen_hits"); err != nil {
+		return 0, fmt.Errorf("failed to clear screen hits: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM privilege_queue WHERE status = ?", QueueStatusPending); err != nil {
+		return 0, fmt.Errorf("failed to clear pending queue: %w", err)
+	}
+
+	hitStmt, err := tx.Prepare(`
+		INSERT INTO privilege_screen_hits (file_id, reason, matched, counsel, snippet, offset)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare screen hit insert: %w", err)
+	}
+	defer hitStmt.Close()
+
+	queueStmt, err := tx.Prepare(`
+		INSERT INTO privilege_queue (file_id, status, screened_at) VALUES (?, ?, ?)
+		ON CONFLICT(file_id) DO UPDATE SET screened_at = excluded.screened_at
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare queue insert: %w", err)
+	}
+	defer queueStmt.Close()
+
+	screenedAt := time.Now().UTC().Format(time.RFC3339)
+	queued := make(map[int64]bool)
+	for _, h := range hits {
+		if _, err := hitStmt.Exec(h.FileID, h.Reason, h.Matched, h.Counsel, h.Snippet, h.Offset); err != nil {
+			return 0, fmt.Errorf("failed to save screen hit: %w", err)
+		}
+		if !queued[h.FileID] {
+			queued[h.FileID] = true
+			if _, err := queueStmt.Exec(h.FileID, QueueStatusPending, screenedAt); err != nil {
+				return 0, fmt.Errorf("failed to queue file %d: %w", h.FileID, err)
+			}
+		}
+	}
+
+	var pending int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM privilege_queue WHERE status = ?", QueueStatusPending).Scan(&pending); err != nil {
+		return 0, fmt.Errorf("failed to count pending queue: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit privilege screen: %w", err)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"files_scanned": scanned,
+		"files_flagged": len(queued),
+		"pending":       pending,
+		"hits":          len(hits),
+	})
+	return pending, nil
+}
+
+// GetPrivilegeScreenEvidence returns the screen hits recorded for a file
+func (d *DB) GetPrivilegeScreenEvidence(fileID int64) ([]PrivilegeScreenHit, error) {
+	rows, err := d.db.Query(`
+		SELECT file_id, reason, matched, counsel, snippet, offset
+		FROM privilege_screen_hits
+		WHERE file_id = ?
+		ORDER BY offset
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query screen evidence: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []PrivilegeScreenHit
+	for rows.Next() {
+		var h PrivilegeScreenHit
+		if err := rows.Scan(&h.FileID, &h.Reason, &h.Matched, &h.Counsel, &h.Snippet, &h.Offset); err != nil {
+			return nil, fmt.Errorf("failed to scan screen hit: %w", err)
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating screen hits: %w", err)
+	}
+	return hits, nil
+}
+
+// GetPrivilegeQueue returns queued files with the given status ("" for all), oldest screening first
+func (d *DB) GetPrivilegeQueue(status string) ([]PrivilegeQueueItem, error) {
+	query := `
+		SELECT q.file_id, f.path, q.status, q.reviewer, q.note, q.screened_at, q.decided_at,
+		       (SELECT GROUP_CONCAT(DISTINCT reason) FROM privilege_screen_hits WHERE file_id = q.file_id),
+		       (SELECT COUNT(*) FROM privilege_screen_hits WHERE file_id = q.file_id)
+		FROM privilege_queue q
+		JOIN files f ON f.id = q.file_id
+	`
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE q.status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY q.screened_at, q.file_id"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query privilege queue: %w", err)
+	}
+	defer rows.Close()
+
+	var items []PrivilegeQueueItem
+	for rows.Next() {
+		var item PrivilegeQueueItem
+		var screenedAt string
+		var decidedAt, reasons sql.NullString
+		if err := rows.Scan(&item.FileID, &item.Path, &item.Status, &item.Reviewer, &item.Note, &screenedAt, &decidedAt,
+			&reasons, &item.HitCount); err != nil {
+			return nil, fmt.Errorf("failed to scan privilege queue item: %w", err)
+		}
+		item.ScreenedAt = parseTimestamp(screenedAt)
+		if item.DecidedAt, err = parseOptionalDate(decidedAt); err != nil {
+			return nil, err
+		}
+		if reasons.Valid {
+			item.Reasons = strings.Split(reasons.String, ",")
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating privilege queue: %w", err)
+	}
+	return items, nil
+}
+
+// ConfirmPrivilege resolves a queued file as privileged and records its log entry
+// This is the step that sets the privileged flag
+func (d *DB) ConfirmPrivilege(entry PrivilegeEntry, reviewer, note string) error {
+	if strings.TrimSpace(reviewer) == "" {
+		return fmt.Errorf("reviewer is required")
+	}
+	if err := d.SetPrivilegeEntry(entry); err != nil {
+		return err
+	}
+	return d.resolvePrivilegeQueueItem(entry.FileID, QueueStatusConfirmed, reviewer, note)
+}
+
+// RejectPrivilege resolves a queued file as not privileged and clears any privilege claim
+func (d *DB) RejectPrivilege(fileID int64, reviewer, note string) error {
+	if strings.TrimSpace(reviewer) == "" {
+		return fmt.Errorf("reviewer is required")
+	}
+	if err := d.ClearPrivilegeEntry(fileID); err != nil {
+		return err
+	}
+	return d.resolvePrivilegeQueueItem(fileID, QueueStatusRejected, reviewer, note)
+}
+
+// resolvePrivilegeQueueItem records a reviewer's decision on a queued file
+// Files confirmed outside the queue get a queue row so the decision is kept
+func (d *DB) resolvePrivilegeQueueItem(fileID int64, status, reviewer, note string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := d.db.Exec(`
+		INSERT INTO privilege_queue (file_id, status, reviewer, note, screened_at, decided_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(file_id) DO UPDATE SET
+			status = excluded.status,
+			reviewer = excluded.reviewer,
+			note = excluded.note,
+			decided_at = excluded.decided_at
+	`, fileID, status, strings.TrimSpace(reviewer), note, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to resolve privilege queue item: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import "testing"
+
+func TestSeededFilesAreNotPrivileged(t *testing.T) {
+	d := newTestDB(t)
+	var privileged int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM files WHERE privileged = 1").Scan(&privileged); err != nil {
+		t.Fatal(err)
+	}
+	if privileged != 0 {
+		t.Errorf("%d seeded files are marked privileged before any review", privileged)
+	}
+}
+
+func TestScreenDocumentCountsNoteToSelfOnce(t *testing.T) {
+	cs, err := compilePrivilegeScreen(DefaultPrivilegeScreen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := cs.screenDocument(1, "jane.doe@sol.doi.gov", "Jane.Doe@sol.doi.gov", "notes")
+	if len(hits) != 1 || hits[0].Reason != ScreenReasonCounselAddress || hits[0].Counsel != "Office of the Solicitor" {
+		t.Fatalf("got %+v, want one counsel address hit", hits)
+	}
+
+	hits = cs.screenDocument(2, "jane.doe@sol.doi.gov", "counsel@legal.com", "")
+	if len(hits) != 2 || hits[0].Counsel == hits[1].Counsel {
+		t.Errorf("got %+v, want a hit for each counsel", hits)
+	}
+}
+
+func TestScreenDocumentFindsNamesAndPhrases(t *testing.T) {
+	cs, err := compilePrivilegeScreen(DefaultPrivilegeScreen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "Per the Regional Solicitor, this is PRIVILEGED AND CONFIDENTIAL. Solicitation ends Friday."
+	reasons := map[string]string{}
+	for _, hit := range cs.screenDocument(3, "a@doi.gov", "b@doi.gov", text) {
+		reasons[hit.Reason] = hit.Matched
+		if text[hit.Offset:hit.Offset+len(hit.Matched)] != hit.Matched {
+			t.Errorf("%q does not sit at offset %d", hit.Matched, hit.Offset)
+		}
+	}
+	if reasons[ScreenReasonCounselName] != "Regional Solicitor" || reasons[ScreenReasonPhrase] != "PRIVILEGED AND CONFIDENTIAL" || len(reasons) != 2 {
+		t.Errorf("got %v", reasons)
+	}
+}
+
+func TestOnlyConfirmationMarksFilesPrivileged(t *testing.T) {
+	d := newTestDB(t)
+	id := firstFileIDs(t, d, 1)[0]
+	mustExec(t, d, "UPDATE files SET extracted_text = 'Privileged and confidential legal advice' WHERE id = ?", id)
+
+	pending, err := d.ScreenPrivilege(PrivilegeScreen{Phrases: []string{"legal advice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 1 {
+		t.Fatalf("%d files pending review, want 1", pending)
+	}
+	file, _ := d.GetFileByID(id)
+	if file.Privileged {
+		t.Fatal("screening marked the file privileged")
+	}
+
+	entry := PrivilegeEntry{FileID: id, Bases: []string{PrivilegeAttorneyClient}, Description: "Legal advice on staffing",
+		Treatment: TreatmentWithheld}
+	if err := d.ConfirmPrivilege(entry, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+	if file, _ = d.GetFileByID(id); !file.Privileged {
+		t.Error("confirmation did not mark the file privileged")
+	}
+}
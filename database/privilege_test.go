@@ -0,0 +1,187 @@
+package database
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// privilegeFixture scopes four files to category "priv-test", dated in ID order
+// files[1] is an attachment in files[0]'s family; files[0] is withheld as
+// attorney-client, files[2] is coded privileged without a log entry and
+// files[3] is not privileged
+func privilegeFixture(t *testing.T, d *DB) []int64 {
+	t.Helper()
+	ids := firstFileIDs(t, d, 4)
+	for i, id := range ids {
+		mustExec(t, d, "UPDATE files SET category = 'priv-test', date = ?, path = ? WHERE id = ?",
+			fmt.Sprintf("2022-01-%02dT00:00:00Z", i+1), fmt.Sprintf("Priv/msg_%c.eml", 'a'+i), id)
+	}
+	mustExec(t, d, "UPDATE files SET path = 'Priv/msg_a/attachment.pdf' WHERE id = ?", ids[1])
+
+	err := d.SetPrivilegeEntry(PrivilegeEntry{
+		FileID:      ids[0],
+		Bases:       []string{PrivilegeAttorneyClient},
+		Description: "Email requesting legal advice on the reassignment",
+		Author:      "counsel@agency.gov",
+		Recipients:  []string{"manager@agency.gov"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RecordReviewDecision(ids[2], "PR-001", DecisionPrivileged, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func logFileIDs(log *PrivilegeLog) []int64 {
+	var ids []int64
+	for _, e := range log.Entries {
+		ids = append(ids, e.FileID)
+	}
+	return ids
+}
+
+func TestSetPrivilegeEntry(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	if err := d.SetPrivilegeEntry(PrivilegeEntry{FileID: ids[0]}); err == nil {
+		t.Error("recorded privilege without a basis")
+	}
+	if err := d.SetPrivilegeEntry(PrivilegeEntry{FileID: ids[0], Bases: []string{"secret"}}); err == nil {
+		t.Error("recorded an unknown privilege basis")
+	}
+	err := d.SetPrivilegeEntry(PrivilegeEntry{FileID: ids[0], Bases: []string{PrivilegeWorkProduct}, Treatment: "hidden"})
+	if err == nil {
+		t.Error("recorded an unknown treatment")
+	}
+
+	err = d.SetPrivilegeEntry(PrivilegeEntry{FileID: ids[0], Bases: []string{PrivilegeWorkProduct}, Description: " Draft brief "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromEmail, toEmail string
+	if err := d.db.QueryRow("SELECT from_email, to_email FROM files WHERE id = ?", ids[0]).Scan(&fromEmail, &toEmail); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := d.GetPrivilegeEntry(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The seeded file is an email, so author and recipients come from it
+	if entry.Author != fromEmail || !reflect.DeepEqual(entry.Recipients, []string{toEmail}) {
+		t.Errorf("entry author %q recipients %v, want %q and [%s]", entry.Author, entry.Recipients, fromEmail, toEmail)
+	}
+	if entry.Treatment != TreatmentWithheld || entry.Description != "Draft brief" {
+		t.Errorf("entry treatment %q description %q", entry.Treatment, entry.Description)
+	}
+	if f, _ := d.GetFileByID(ids[0]); !f.Privileged {
+		t.Error("file not marked privileged")
+	}
+
+	if err := d.ClearPrivilegeEntry(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := d.GetPrivilegeEntry(ids[0]); entry != nil {
+		t.Errorf("cleared entry still recorded: %+v", entry)
+	}
+	if f, _ := d.GetFileByID(ids[0]); f.Privileged {
+		t.Error("cleared file still marked privileged")
+	}
+}
+
+func TestGeneratePrivilegeLog(t *testing.T) {
+	d := newTestDB(t)
+	ids := privilegeFixture(t, d)
+	filters := FileFilters{ProductionRequestID: "PR-001", Categories: []string{"priv-test"}, ExcludePrivileged: true}
+
+	log, err := d.GeneratePrivilegeLog(filters, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logFileIDs(log); !reflect.DeepEqual(got, []int64{ids[0], ids[2]}) {
+		t.Fatalf("logged files %v, want %v", got, []int64{ids[0], ids[2]})
+	}
+	first := log.Entries[0]
+	if first.LogID != "PRIV-0001" || !first.Complete || first.Author != "counsel@agency.gov" ||
+		first.FamilyID == "" || first.Treatment != TreatmentWithheld {
+		t.Errorf("first entry %+v", first)
+	}
+	// Coded privileged in review but not yet described
+	if log.Entries[1].Complete || !reflect.DeepEqual(log.Incomplete, []int64{ids[2]}) {
+		t.Errorf("incomplete %v, want [%d]", log.Incomplete, ids[2])
+	}
+}
+
+func TestGeneratePrivilegeLogWithholdsFamilies(t *testing.T) {
+	d := newTestDB(t)
+	ids := privilegeFixture(t, d)
+	filters := FileFilters{ProductionRequestID: "PR-001", Categories: []string{"priv-test"}}
+
+	log, err := d.GeneratePrivilegeLog(filters, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logFileIDs(log); !reflect.DeepEqual(got, []int64{ids[0], ids[1], ids[2]}) {
+		t.Fatalf("logged files %v, want %v", got, ids[:3])
+	}
+	member := log.Entries[1]
+	if member.Treatment != TreatmentFamilyWithheld || member.FamilyID != log.Entries[0].FamilyID ||
+		!strings.HasSuffix(member.Description, "PRIV-0001") {
+		t.Errorf("family member entry %+v", member)
+	}
+
+	// A redacted document is produced, so its family is too
+	err = d.SetPrivilegeEntry(PrivilegeEntry{
+		FileID:      ids[0],
+		Bases:       []string{PrivilegeAttorneyClient},
+		Description: "Email requesting legal advice on the reassignment",
+		Treatment:   TreatmentRedacted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log, err = d.GeneratePrivilegeLog(filters, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := logFileIDs(log); !reflect.DeepEqual(got, []int64{ids[0], ids[2]}) {
+		t.Errorf("logged files %v after redaction, want %v", got, []int64{ids[0], ids[2]})
+	}
+}
+
+func TestExportPrivilegeLog(t *testing.T) {
+	d := newTestDB(t)
+	privilegeFixture(t, d)
+	filters := FileFilters{ProductionRequestID: "PR-001", Categories: []string{"priv-test"}}
+
+	csvPath, xlsxPath, err := d.ExportPrivilegeLog(filters, true, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[0][0] != "Log ID" {
+		t.Fatalf("got %d CSV rows, want a header and 3 entries", len(rows))
+	}
+	if rows[1][3] != "2022-01-01" || rows[1][7] != "manager@agency.gov" ||
+		rows[1][9] != "Attorney-Client Privilege" || rows[1][10] != "Withheld" {
+		t.Errorf("first row %v", rows[1])
+	}
+	if rows[2][10] != "Withheld (family)" {
+		t.Errorf("family member treatment %q", rows[2][10])
+	}
+	if info, err := os.Stat(xlsxPath); err != nil || info.Size() == 0 {
+		t.Errorf("XLSX not written: %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+package database
+
+import (
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// defaultProductionRequests is the request list served with the matter
+// It seeds an empty database so the store and the JSON file start out agreeing
+//
+//go:embed production_requests.json
+var defaultProductionRequests []byte
+
+// Production request statuses
+const (
+	RequestStatusOpen       = "open"
+	RequestStatusInProgress = "in_progress"
+	RequestStatusProduced   = "produced"
+	RequestStatusObjected   = "objected"
+	RequestStatusWithdrawn  = "withdrawn"
+)
+
+// ProductionRequest is a request for production served on the client
+// ID is the stable key used by FileFilters and CreateZipFile ("PR-004");
+// Number is the request number as served (4)
+type ProductionRequest struct {
+	ID          string     `json:"id"`
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	ServedDate  *time.Time `json:"served_date"`
+	DueDate     *time.Time `json:"due_date"`
+	Status      string     `json:"status"`
+	Objections  string     `json:"objections"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// productionRequestJSON is the shape of production_requests.json entries
+// Only id and description are required; the rest is optional
+type productionRequestJSON struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ServedDate  string `json:"served_date"` // YYYY-MM-DD
+	DueDate     string `json:"due_date"`    // YYYY-MM-DD
+	Status      string `json:"status"`
+	Objections  string `json:"objections"`
+}
+
+// ProductionRequestID builds the stable ID for a request number
+func ProductionRequestID(number int) string {
+	return fmt.Sprintf("PR-%03d", number)
+}
+
+// productionRequestTitle is the caption used when a request has no title
+func productionRequestTitle(number int) string {
+	return fmt.Sprintf("REQUEST FOR PRODUCTION NO: %d", number)
+}
+
+// isValidRequestStatus reports whether status is one of the known statuses
+func isValidRequestStatus(status string) bool {
+	switch status {
+	case RequestStatusOpen, RequestStatusInProgress, RequestStatusProduced, RequestStatusObjected, RequestStatusWithdrawn:
+		return true
+	}
+	return false
+}
+
+// seedProductionRequests loads the embedded request list into an empty table
+// Rows with an empty updated_at are the old mock PR-001..PR-005 seed, written before
+// requests were editable; they are replaced by the served requests of the same number
+// once, and every other row is left alone
+func (d *DB) seedProductionRequests() error {
+	var count, legacy int
+	err := d.db.QueryRow("SELECT COUNT(*), COUNT(CASE WHEN updated_at = '' THEN 1 END) FROM production_requests").Scan(&count, &legacy)
+	if err != nil {
+		return fmt.Errorf("failed to count production requests: %w", err)
+	}
+	if count > 0 && legacy == 0 {
+		return nil
+	}
+
+	var entries []productionRequestJSON
+	if err := json.Unmarshal(defaultProductionRequests, &entries); err != nil {
+		return fmt.Errorf("could not parse production requests: %w", err)
+	}
+	if count > 0 {
+		rows, err := d.db.Query("SELECT id FROM production_requests WHERE updated_at != ''")
+		if err != nil {
+			return fmt.Errorf("failed to query production requests: %w", err)
+		}
+		defer rows.Close()
+		current := map[string]bool{}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return fmt.Errorf("failed to scan production request: %w", err)
+			}
+			current[id] = true
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating production requests: %w", err)
+		}
+
+		kept := entries[:0]
+		for _, e := range entries {
+			if !current[ProductionRequestID(e.ID)] {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+
+	_, err = d.importProductionRequestEntries(entries)
+	return err
+}
+
+// GetProductionRequests returns all production requests in request-number order
+func (d *DB) GetProductionRequests() ([]ProductionRequest, error) {
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to get production requests")
+
+	rows, err := d.db.Query(`
+		SELECT id, number, title, description, served_date, due_date, status, objections, created_at, updated_at
+		FROM production_requests
+		ORDER BY number, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query production requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []ProductionRequest
+	for rows.Next() {
+		pr, err := scanProductionRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating production requests: %w", err)
+	}
+	return requests, nil
+}
+
+// GetProductionRequest returns one production request by ID
+func (d *DB) GetProductionRequest(id string) (*ProductionRequest, error) {
+	row := d.db.QueryRow(`
+		SELECT id, number, title, description, served_date, due_date, status, objections, created_at, updated_at
+		FROM production_requests
+		WHERE id = ?
+	`, id)
+	pr, err := scanProductionRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("production request %s not found", id)
+	}
+	return pr, err
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProductionRequest reads one production_requests row
+func scanProductionRequest(row rowScanner) (*ProductionRequest, error) {
+	var pr ProductionRequest
+	var servedDate, dueDate sql.NullString
+	var createdAt, updatedAt string
+	err := row.Scan(&pr.ID, &pr.Number, &pr.Title, &pr.Description, &servedDate, &dueDate,
+		&pr.Status, &pr.Objections, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan production request: %w", err)
+	}
+
+	if pr.ServedDate, err = parseOptionalDate(servedDate); err != nil {
+		return nil, err
+	}
+	if pr.DueDate, err = parseOptionalDate(dueDate); err != nil {
+		return nil, err
+	}
+	// SQLite CURRENT_TIMESTAMP is "YYYY-MM-DD HH:MM:SS"; rows we write use RFC3339
+	pr.CreatedAt = parseTimestamp(createdAt)
+	pr.UpdatedAt = parseTimestamp(updatedAt)
+	return &pr, nil
+}
+
+// parseTimestamp accepts RFC3339 or SQLite's CURRENT_TIMESTAMP format
+// Unparseable values give the zero time rather than failing the whole query
+func parseTimestamp(value string) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	if t, err := time.Parse("2006-01-02 15:04:05", value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// CreateProductionRequest adds a request; ID defaults to PR-NNN from Number
+// and Title to the served caption
+func (d *DB) CreateProductionRequest(pr ProductionRequest) (*ProductionRequest, error) {
+	if err := normalizeProductionRequest(&pr); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := d.db.Exec(`
+		INSERT INTO production_requests (id, number, title, description, served_date, due_date, status, objections, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, pr.ID, pr.Number, pr.Title, pr.Description, formatOptionalDate(pr.ServedDate), formatOptionalDate(pr.DueDate),
+		pr.Status, pr.Objections, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create production request: %w", err)
+	}
+	return d.GetProductionRequest(pr.ID)
+}
+
+// UpdateProductionRequest replaces all editable fields of an existing request
+func (d *DB) UpdateProductionRequest(pr ProductionRequest) (*ProductionRequest, error) {
+	if err := normalizeProductionRequest(&pr); err != nil {
+		return nil, err
+	}
+
+	result, err := d.db.Exec(`
+		UPDATE production_requests
+		SET number = ?, title = ?, description = ?, served_date = ?, due_date = ?, status = ?, objections = ?, updated_at = ?
+		WHERE id = ?
+	`, pr.Number, pr.Title, pr.Description, formatOptionalDate(pr.ServedDate), formatOptionalDate(pr.DueDate),
+		pr.Status, pr.Objections, time.Now().UTC().Format(time.RFC3339), pr.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update production request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("production request %s not found", pr.ID)
+	}
+	return d.GetProductionRequest(pr.ID)
+}
+
+// productionRequestDependents lists the tables whose rows belong to a request
+var productionRequestDependents = []struct{ table, noun string }{
+	{"review_decisions", "review decisions"},
+	{"review_batches", "review batches"},
+	{"saved_searches", "saved searches"},
+	{"relevance_models", "relevance models"},
+	{"elusion_samples", "elusion samples"},
+	{"productions", "productions"},
+}
+
+// DeleteProductionRequest removes a request that nothing refers to yet
+// A request that saved searches, review work or productions refer to is refused rather
+// than orphaning them; withdraw it instead
+func (d *DB) DeleteProductionRequest(id string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Delete first so the transaction holds the write lock while dependents are counted;
+	// nothing can refer to the request between the check and the commit
+	result, err := tx.Exec("DELETE FROM production_requests WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete production request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("production request %s not found", id)
+	}
+
+	var inUse []string
+	for _, dep := range productionRequestDependents {
+		var n int
+		err := tx.QueryRow("SELECT COUNT(*) FROM "+dep.table+" WHERE production_request_id = ?", id).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("failed to count %s: %w", dep.noun, err)
+		}
+		if n > 0 {
+			inUse = append(inUse, fmt.Sprintf("%d %s", n, dep.noun))
+		}
+	}
+	if len(inUse) > 0 {
+		return fmt.Errorf("production request %s has %s; mark it withdrawn instead of deleting it",
+			id, strings.Join(inUse, ", "))
+	}
+	return tx.Commit()
+}
+
+// normalizeProductionRequest fills defaults and validates a request before writing
+func normalizeProductionRequest(pr *ProductionRequest) error {
+	// ASSUMPTION: A request is identified by its ID or its served number
+	if pr.ID == "" {
+		if pr.Number <= 0 {
+			return fmt.Errorf("production request needs an ID or a positive number")
+		}
+		pr.ID = ProductionRequestID(pr.Number)
+	}
+	if strings.TrimSpace(pr.Description) == "" {
+		return fmt.Errorf("production request %s needs a description", pr.ID)
+	}
+	if pr.Title == "" && pr.Number > 0 {
+		pr.Title = productionRequestTitle(pr.Number)
+	}
+	if pr.Status == "" {
+		pr.Status = RequestStatusOpen
+	}
+	if !isValidRequestStatus(pr.Status) {
+		return fmt.Errorf("unknown production request status %q", pr.Status)
+	}
+	if pr.ServedDate != nil && pr.DueDate != nil && pr.DueDate.Before(*pr.ServedDate) {
+		return fmt.Errorf("due date must not be before served date")
+	}
+	return nil
+}
+
+// ImportProductionRequestsJSON loads requests from a production_requests.json file
+// Existing requests keep their status, dates and objections unless the file sets them
+// Returns the number of requests imported
+func (d *DB) ImportProductionRequestsJSON(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not load production requests: %w", err)
+	}
+	return d.importProductionRequests(data)
+}
+
+// importProductionRequests upserts the requests in a JSON array
+func (d *DB) importProductionRequests(data []byte) (int, error) {
+	var entries []productionRequestJSON
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("could not parse production requests: %w", err)
+	}
+	return d.importProductionRequestEntries(entries)
+}
+
+// importProductionRequestEntries upserts parsed requests in one transaction
+func (d *DB) importProductionRequestEntries(entries []productionRequestJSON) (int, error) {
+	op := logging.StartOperation("ImportProductionRequests", map[string]interface{}{
+		"requests": len(entries),
+	})
+	defer op.EndOperation()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range entries {
+		// ASSUMPTION: Request numbers in the file are positive and unique
+		if e.ID <= 0 {
+			return 0, fmt.Errorf("production request has invalid id %d", e.ID)
+		}
+		if e.Title == "" {
+			e.Title = productionRequestTitle(e.ID)
+		}
+		if e.Status != "" && !isValidRequestStatus(e.Status) {
+			return 0, fmt.Errorf("production request %d has unknown status %q", e.ID, e.Status)
+		}
+		servedDate, err := parseImportDate(e.ServedDate)
+		if err != nil {
+			return 0, fmt.Errorf("production request %d: %w", e.ID, err)
+		}
+		dueDate, err := parseImportDate(e.DueDate)
+		if err != nil {
+			return 0, fmt.Errorf("production request %d: %w", e.ID, err)
+		}
+
+		// NULLIF/COALESCE keep stored values when the file leaves a field out
+		_, err = tx.Exec(`
+			INSERT INTO production_requests (id, number, title, description, served_date, due_date, status, objections, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), 'open'), ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				number = excluded.number,
+				title = excluded.title,
+				description = excluded.description,
+				served_date = COALESCE(excluded.served_date, production_requests.served_date),
+				due_date = COALESCE(excluded.due_date, production_requests.due_date),
+				status = CASE WHEN ? = '' THEN production_requests.status ELSE excluded.status END,
+				objections = CASE WHEN ? = '' THEN production_requests.objections ELSE excluded.objections END,
+				updated_at = excluded.updated_at
+		`, ProductionRequestID(e.ID), e.ID, e.Title, e.Description, servedDate, dueDate,
+			e.Status, e.Objections, now, now, e.Status, e.Objections)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import production request %d: %w", e.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit production requests: %w", err)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"imported": len(entries),
+	})
+	return len(entries), nil
+}
+
+// parseImportDate converts an optional YYYY-MM-DD date to a stored value
+func parseImportDate(value string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q (want YYYY-MM-DD): %w", value, err)
+	}
+	return t.Format(time.RFC3339), nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSeedProductionRequestsKeepsEdits(t *testing.T) {
+	d := newTestDB(t)
+	pr, err := d.GetProductionRequest("PR-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr.Title = "Edited caption"
+	if _, err := d.UpdateProductionRequest(*pr); err != nil {
+		t.Fatal(err)
+	}
+	custom := ProductionRequest{ID: "SUPP-A", Description: "Supplemental request without a number"}
+	if _, err := d.CreateProductionRequest(custom); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.seedProductionRequests(); err != nil {
+		t.Fatal(err)
+	}
+	if pr, _ = d.GetProductionRequest("PR-001"); pr.Title != "Edited caption" {
+		t.Errorf("restart reverted PR-001's title to %q", pr.Title)
+	}
+	if _, err := d.GetProductionRequest("SUPP-A"); err != nil {
+		t.Errorf("restart lost the unnumbered request: %v", err)
+	}
+}
+
+func TestSeedProductionRequestsReplacesLegacyMockRows(t *testing.T) {
+	d := newTestDB(t)
+	// Mock rows from before requests were editable had no number or updated_at
+	mustExec(t, d, "UPDATE production_requests SET number = 0, title = 'Mock', updated_at = '' WHERE id = 'PR-002'")
+	mustExec(t, d, "UPDATE production_requests SET title = 'Kept' WHERE id = 'PR-003'")
+
+	if err := d.seedProductionRequests(); err != nil {
+		t.Fatal(err)
+	}
+	pr, err := d.GetProductionRequest("PR-002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 2 || pr.Title == "Mock" {
+		t.Errorf("legacy row not replaced: number %d, title %q", pr.Number, pr.Title)
+	}
+	if pr, _ = d.GetProductionRequest("PR-003"); pr.Title != "Kept" {
+		t.Errorf("seeding overwrote PR-003's title with %q", pr.Title)
+	}
+}
+
+func TestDeleteProductionRequestRefusesDependents(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	if err := d.RecordReviewDecisions(ids, "PR-001", DecisionResponsive, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	err := d.DeleteProductionRequest("PR-001")
+	if err == nil || !strings.Contains(err.Error(), "1 review decisions") {
+		t.Fatalf("got %v, want a refusal naming the review decision", err)
+	}
+	if _, err := d.GetProductionRequest("PR-001"); err != nil {
+		t.Errorf("refused delete still removed the request: %v", err)
+	}
+
+	if err := d.DeleteProductionRequest("PR-002"); err != nil {
+		t.Errorf("unused request: %v", err)
+	}
+	if _, err := d.GetProductionRequest("PR-002"); err == nil {
+		t.Error("deleted request still found")
+	}
+	if err := d.DeleteProductionRequest("PR-002"); err == nil {
+		t.Error("deleting a missing request succeeded")
+	}
+}
@@ -0,0 +1,381 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// ProductionSummary is one row of the production history
+type ProductionSummary struct {
+	ID                  int64     `json:"id"`
+	ProductionRequestID string    `json:"production_request_id"`
+	Directory           string    `json:"directory"`
+	BatesPrefix         string    `json:"bates_prefix"`
+	BegBates            string    `json:"beg_bates"`
+	EndBates            string    `json:"end_bates"`
+	DocumentCount       int       `json:"document_count"`
+	VolumeCount         int       `json:"volume_count"`
+	OutputHash          string    `json:"output_hash"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// DocumentProduction answers "was this document produced, and where?"
+type DocumentProduction struct {
+	ProductionID        int64     `json:"production_id"`
+	ProductionRequestID string    `json:"production_request_id"`
+	BegBates            string    `json:"beg_bates"`
+	EndBates            string    `json:"end_bates"`
+	Volume              string    `json:"volume"`
+	NativePath          string    `json:"native_path"`
+	SlipSheet           string    `json:"slip_sheet,omitempty"` // Set when the document was withheld and only a placeholder went out
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// recordProduction stores a written production with its members, Bates assignments,
+// settings and hashes, and returns its ID
+func (d *DB) recordProduction(output *ProductionOutput, sourceRoot string) (int64, error) {
+	settingsJSON, err := json.Marshal(output.Settings)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode production settings: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO productions (production_request_id, directory, bates_prefix, beg_bates, end_bates,
+		                         settings, source_root, output_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, output.ProductionRequestID, output.Directory, output.BatesPrefix,
+		output.Documents[0].BegBates, output.Documents[len(output.Documents)-1].EndBates,
+		string(settingsJSON), sourceRoot, output.OutputHash, output.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to record production: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get production ID: %w", err)
+	}
+
+	volumeStmt, err := tx.Prepare(`
+		INSERT INTO production_volumes (production_id, name, path, file_count, size, beg_bates, end_bates, sha256)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare volume insert: %w", err)
+	}
+	defer volumeStmt.Close()
+	for _, v := range output.Volumes {
+		if _, err := volumeStmt.Exec(id, v.Name, v.Path, v.FileCount, v.Size, v.BegBates, v.EndBates, v.SHA256); err != nil {
+			return 0, fmt.Errorf("failed to record volume %s: %w", v.Name, err)
+		}
+	}
+
+	docStmt, err := tx.Prepare(`
+		INSERT INTO production_documents (production_id, file_id, position, source_path, beg_bates, end_bates,
+		                                  pages, volume, native_path, text_path, size, sha256, md5, slip_sheet, redactions,
+		                                  pages_estimated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare document insert: %w", err)
+	}
+	defer docStmt.Close()
+	for i, doc := range output.Documents {
+		if _, err := docStmt.Exec(id, doc.FileID, i, doc.Path, doc.BegBates, doc.EndBates,
+			doc.Pages, doc.Volume, doc.NativePath, doc.TextPath, doc.Size, doc.SHA256, doc.MD5, doc.SlipSheet, doc.Redactions,
+			doc.PagesEstimated); err != nil {
+			return 0, fmt.Errorf("failed to record produced document %d: %w", doc.FileID, err)
+		}
+		err := appendAudit(tx, AuditProduction, doc.FileID, id, AuditActorSystem, map[string]interface{}{
+			"production_request_id": output.ProductionRequestID,
+			"beg_bates":             doc.BegBates,
+			"end_bates":             doc.EndBates,
+			"native_path":           doc.NativePath,
+			"sha256":                doc.SHA256,
+			"slip_sheet":            doc.SlipSheet,
+			"redactions":            doc.Redactions,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = appendAudit(tx, AuditProduction, 0, id, AuditActorSystem, map[string]interface{}{
+		"production_request_id": output.ProductionRequestID,
+		"directory":             output.Directory,
+		"documents":             len(output.Documents),
+		"volumes":               len(output.Volumes),
+		"output_hash":           output.OutputHash,
+		"encrypted":             output.Settings.Encrypt,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit production record: %w", err)
+	}
+	return id, nil
+}
+
+// GetProductions returns the production history, newest first
+// An empty requestID returns productions for every request
+func (d *DB) GetProductions(requestID string) ([]ProductionSummary, error) {
+	query := `
+		SELECT p.id, p.production_request_id, p.directory, p.bates_prefix, p.beg_bates, p.end_bates,
+		       (SELECT COUNT(*) FROM production_documents WHERE production_id = p.id),
+		       (SELECT COUNT(*) FROM production_volumes WHERE production_id = p.id),
+		       p.output_hash, p.created_at
+		FROM productions p
+	`
+	args := []interface{}{}
+	if requestID != "" {
+		query += " WHERE p.production_request_id = ?"
+		args = append(args, requestID)
+	}
+	query += " ORDER BY p.created_at DESC, p.id DESC"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query productions: %w", err)
+	}
+	defer rows.Close()
+
+	var productions []ProductionSummary
+	for rows.Next() {
+		var p ProductionSummary
+		var createdAt string
+		if err := rows.Scan(&p.ID, &p.ProductionRequestID, &p.Directory, &p.BatesPrefix, &p.BegBates, &p.EndBates,
+			&p.DocumentCount, &p.VolumeCount, &p.OutputHash, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan production: %w", err)
+		}
+		p.CreatedAt = parseTimestamp(createdAt)
+		productions = append(productions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating productions: %w", err)
+	}
+	return productions, nil
+}
+
+// GetProduction returns a recorded production with its volumes and documents in Bates order
+// Returns nil if the production does not exist
+func (d *DB) GetProduction(id int64) (*ProductionOutput, error) {
+	output, _, err := d.loadProduction(id)
+	return output, err
+}
+
+// loadProduction reads a production record and the data lake root it was produced from
+func (d *DB) loadProduction(id int64) (*ProductionOutput, string, error) {
+	output := &ProductionOutput{ID: id}
+	var settingsJSON, sourceRoot, createdAt string
+	err := d.db.QueryRow(`
+		SELECT production_request_id, directory, bates_prefix, settings, source_root, output_hash, created_at
+		FROM productions WHERE id = ?
+	`, id).Scan(&output.ProductionRequestID, &output.Directory, &output.BatesPrefix, &settingsJSON,
+		&sourceRoot, &output.OutputHash, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get production: %w", err)
+	}
+	if err := json.Unmarshal([]byte(settingsJSON), &output.Settings); err != nil {
+		return nil, "", fmt.Errorf("failed to decode production settings: %w", err)
+	}
+	output.CreatedAt = parseTimestamp(createdAt)
+
+	volumeRows, err := d.db.Query(`
+		SELECT name, path, file_count, size, beg_bates, end_bates, sha256
+		FROM production_volumes WHERE production_id = ? ORDER BY name
+	`, id)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query production volumes: %w", err)
+	}
+	defer volumeRows.Close()
+	for volumeRows.Next() {
+		var v ProductionVolume
+		if err := volumeRows.Scan(&v.Name, &v.Path, &v.FileCount, &v.Size, &v.BegBates, &v.EndBates, &v.SHA256); err != nil {
+			return nil, "", fmt.Errorf("failed to scan production volume: %w", err)
+		}
+		output.Volumes = append(output.Volumes, v)
+	}
+	if err := volumeRows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating production volumes: %w", err)
+	}
+
+	docRows, err := d.db.Query(`
+		SELECT pd.file_id, pd.source_path, f.date, pd.beg_bates, pd.end_bates, pd.pages, pd.volume,
+		       pd.native_path, pd.text_path, pd.size, pd.sha256, pd.md5, pd.slip_sheet, pd.redactions, pd.pages_estimated
+		FROM production_documents pd
+		JOIN files f ON f.id = pd.file_id
+		WHERE pd.production_id = ?
+		ORDER BY pd.position
+	`, id)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query produced documents: %w", err)
+	}
+	defer docRows.Close()
+	for docRows.Next() {
+		var doc ProducedDocument
+		var date string
+		if err := docRows.Scan(&doc.FileID, &doc.Path, &date, &doc.BegBates, &doc.EndBates, &doc.Pages, &doc.Volume,
+			&doc.NativePath, &doc.TextPath, &doc.Size, &doc.SHA256, &doc.MD5, &doc.SlipSheet, &doc.Redactions, &doc.PagesEstimated); err != nil {
+			return nil, "", fmt.Errorf("failed to scan produced document: %w", err)
+		}
+		doc.Date = parseTimestamp(date)
+		output.Documents = append(output.Documents, doc)
+	}
+	if err := docRows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating produced documents: %w", err)
+	}
+	return output, sourceRoot, nil
+}
+
+// GetDocumentProductions returns every production a file went out in, oldest first
+// An empty result means the document has never been produced; rows with a SlipSheet
+// only numbered it, so its content has not gone out in them
+func (d *DB) GetDocumentProductions(fileID int64) ([]DocumentProduction, error) {
+	rows, err := d.db.Query(`
+		SELECT p.id, p.production_request_id, pd.beg_bates, pd.end_bates, pd.volume, pd.native_path, pd.slip_sheet, p.created_at
+		FROM production_documents pd
+		JOIN productions p ON p.id = pd.production_id
+		WHERE pd.file_id = ?
+		ORDER BY p.created_at, p.id
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document productions: %w", err)
+	}
+	defer rows.Close()
+
+	var productions []DocumentProduction
+	for rows.Next() {
+		var p DocumentProduction
+		var createdAt string
+		if err := rows.Scan(&p.ProductionID, &p.ProductionRequestID, &p.BegBates, &p.EndBates, &p.Volume,
+			&p.NativePath, &p.SlipSheet, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan document production: %w", err)
+		}
+		p.CreatedAt = parseTimestamp(createdAt)
+		productions = append(productions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating document productions: %w", err)
+	}
+	return productions, nil
+}
+
+// RecreateProduction writes a recorded production again under outputDir
+// Members, Bates numbers, volumes, settings and timestamps come from the record, so the
+// volumes are byte-identical when the data lake, metadata, tags and redactions are unchanged.
+// An empty sourceRoot reuses the data lake root the production was made from, and an
+// encrypted production needs the password it was made with.
+// Fails, removing the copy, if the result's output hash differs from the recorded one
+func (d *DB) RecreateProduction(id int64, sourceRoot, outputDir, password string) (*ProductionOutput, error) {
+	op := logging.StartOperation("RecreateProduction", map[string]interface{}{
+		"production_id": id,
+		"output_dir":    outputDir,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to re-create a production")
+
+	recorded, recordedRoot, err := d.loadProduction(id)
+	if err != nil {
+		return nil, err
+	}
+	if recorded == nil {
+		return nil, fmt.Errorf("production %d not found", id)
+	}
+	if sourceRoot == "" {
+		sourceRoot = recordedRoot
+	}
+	if recorded.Settings.Encrypt {
+		if err := checkProductionPassword(id, recorded.Settings, password); err != nil {
+			return nil, err
+		}
+		recorded.Settings.Password = password
+	}
+
+	fileIDs := make([]int64, len(recorded.Documents))
+	for i, doc := range recorded.Documents {
+		fileIDs[i] = doc.FileID
+	}
+	files, err := d.GetFilesByIDs(fileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+	hashes, err := d.getContentHashes(fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Rebuild the plan from the record; only what writeProduction fills in is recomputed
+	docs := make([]ProducedDocument, len(recorded.Documents))
+	for i, doc := range recorded.Documents {
+		docs[i] = ProducedDocument{
+			FileID:     doc.FileID,
+			Path:       doc.Path,
+			Date:       doc.Date,
+			BegBates:   doc.BegBates,
+			EndBates:   doc.EndBates,
+			Pages:      doc.Pages,
+			Volume:     doc.Volume,
+			NativePath: doc.NativePath,
+			Size:       doc.Size,
+			SlipSheet:  doc.SlipSheet,
+		}
+	}
+	output := &ProductionOutput{
+		ID:                  id,
+		ProductionRequestID: recorded.ProductionRequestID,
+		BatesPrefix:         recorded.BatesPrefix,
+		Settings:            recorded.Settings,
+		CreatedAt:           recorded.CreatedAt,
+		Documents:           docs,
+	}
+	if err := d.writeProduction(context.Background(), output, files, hashes, sourceRoot, outputDir, nil); err != nil {
+		return nil, err
+	}
+
+	if output.OutputHash != recorded.OutputHash {
+		var differing []string
+		for i, v := range output.Volumes {
+			if i >= len(recorded.Volumes) || v.SHA256 != recorded.Volumes[i].SHA256 {
+				differing = append(differing, v.Name)
+			}
+		}
+		os.RemoveAll(output.Directory)
+		return nil, fmt.Errorf("re-created production %d does not match the original (volumes differ: %s); metadata, text, tags or redactions may have changed since",
+			id, strings.Join(differing, ", "))
+	}
+
+	err = appendAudit(d.db, AuditProduction, 0, id, AuditActorSystem, map[string]interface{}{
+		"recreated_in": output.Directory,
+		"output_hash":  output.OutputHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"production_dir": output.Directory,
+		"output_hash":    output.OutputHash,
+	})
+	return output, nil
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// producedFixture produces three one-page PDFs from a fresh data lake
+func producedFixture(t *testing.T, d *DB, settings ProductionSettings) ([]int64, string, *ProductionOutput) {
+	t.Helper()
+	ids := firstFileIDs(t, d, 3)
+	content := map[int64][]byte{}
+	for i, id := range ids {
+		content[id] = onePagePDF(string(rune('A' + i)))
+	}
+	root := writeLake(t, d, content)
+	return ids, root, produce(t, d, ids, root, settings)
+}
+
+func TestRecreateProductionIsByteIdentical(t *testing.T) {
+	d := newTestDB(t)
+	_, _, original := producedFixture(t, d, ProductionSettings{VolumeSizeLimit: 1 << 20, EDRMXML: true})
+
+	before, err := d.queryAuditEntries("action = ? AND production_id = ?", AuditProduction, original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty source root reuses the data lake the production was made from
+	recreated, err := d.RecreateProduction(original.ID, "", t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated.OutputHash != original.OutputHash || len(recreated.Volumes) != len(original.Volumes) {
+		t.Fatalf("copy hash %s with %d volumes, original %s with %d", recreated.OutputHash, len(recreated.Volumes),
+			original.OutputHash, len(original.Volumes))
+	}
+	for i, v := range recreated.Volumes {
+		want, err := os.ReadFile(original.Volumes[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(v.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from the original", v.Name)
+		}
+	}
+
+	entries, err := d.queryAuditEntries("action = ? AND production_id = ?", AuditProduction, original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(before)+1 || !strings.Contains(entries[len(entries)-1].Details, "recreated_in") {
+		t.Errorf("re-creation added %d audit entries, want one recording it", len(entries)-len(before))
+	}
+}
+
+func TestRecreateProductionRejectsChangedMetadata(t *testing.T) {
+	d := newTestDB(t)
+	ids, _, original := producedFixture(t, d, ProductionSettings{})
+	// The subject is a DAT field, so the load file no longer matches
+	mustExec(t, d, "UPDATE files SET subject = 'Edited after production' WHERE id = ?", ids[1])
+
+	outputDir := t.TempDir()
+	if _, err := d.RecreateProduction(original.ID, "", outputDir, ""); err == nil {
+		t.Fatal("re-created a production whose metadata changed")
+	}
+	if leftover, _ := os.ReadDir(outputDir); len(leftover) != 0 {
+		t.Errorf("mismatched copy left %d entries in the output directory", len(leftover))
+	}
+}
+
+func TestRecreateProductionReadsMovedDataLake(t *testing.T) {
+	d := newTestDB(t)
+	_, root, original := producedFixture(t, d, ProductionSettings{})
+
+	moved := filepath.Join(t.TempDir(), "lake")
+	if err := os.Rename(root, moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.RecreateProduction(original.ID, "", t.TempDir(), ""); err == nil {
+		t.Error("re-created a production from a data lake that is gone")
+	}
+	recreated, err := d.RecreateProduction(original.ID, moved, t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated.OutputHash != original.OutputHash {
+		t.Error("copy from the moved data lake differs from the original")
+	}
+}
+
+func TestRecreateEncryptedProductionNeedsPassword(t *testing.T) {
+	d := newTestDB(t)
+	_, _, original := producedFixture(t, d, ProductionSettings{Encrypt: true, Password: "correct horse"})
+
+	if _, err := d.RecreateProduction(original.ID, "", t.TempDir(), ""); err == nil {
+		t.Error("re-created an encrypted production without its password")
+	}
+	if _, err := d.RecreateProduction(original.ID, "", t.TempDir(), "wrong"); err == nil {
+		t.Error("re-created an encrypted production with the wrong password")
+	}
+	recreated, err := d.RecreateProduction(original.ID, "", t.TempDir(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated.OutputHash != original.OutputHash {
+		t.Error("encrypted copy differs from the original")
+	}
+}
+
+func TestRecreateProductionUnknownID(t *testing.T) {
+	d := newTestDB(t)
+	if _, err := d.RecreateProduction(999, "", t.TempDir(), ""); err == nil {
+		t.Error("re-created a production that was never made")
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ProductionProgress is a snapshot of a production being written
+type ProductionProgress struct {
+	FilesDone  int    `json:"files_done"`
+	FilesTotal int    `json:"files_total"`
+	BytesDone  int64  `json:"bytes_done"` // Document bytes read from the data lake
+	BytesTotal int64  `json:"bytes_total"`
+	Volume     string `json:"volume"`      // Volume being written
+	ETASeconds int64  `json:"eta_seconds"` // -1 until enough has been written to estimate
+}
+
+// ProgressFunc receives production progress from the goroutine writing the production
+type ProgressFunc func(ProductionProgress)
+
+// progressInterval is the least time between reports while a document is being copied
+const progressInterval = 250 * time.Millisecond
+
+// progressTracker counts a production's documents and bytes and reports them
+// Completed documents are always reported; byte counts within a document are throttled
+type progressTracker struct {
+	report     ProgressFunc // nil when nobody is listening
+	started    time.Time
+	lastReport time.Time
+	state      ProductionProgress
+}
+
+// newProgressTracker tracks the planned documents; report may be nil
+func newProgressTracker(docs []ProducedDocument, report ProgressFunc) *progressTracker {
+	t := &progressTracker{report: report, started: time.Now()}
+	t.state.FilesTotal = len(docs)
+	for _, doc := range docs {
+		t.state.BytesTotal += doc.Size
+	}
+	return t
+}
+
+// startVolume notes the volume now being written
+func (t *progressTracker) startVolume(volume string) {
+	t.state.Volume = volume
+	t.emit()
+}
+
+// addBytes counts bytes read for the current document
+func (t *progressTracker) addBytes(n int64) {
+	t.state.BytesDone += n
+	if time.Since(t.lastReport) >= progressInterval {
+		t.emit()
+	}
+}
+
+// fileDone counts a finished document
+func (t *progressTracker) fileDone() {
+	t.state.FilesDone++
+	t.emit()
+}
+
+// emit reports the current state with an ETA projected from the bytes rate so far
+func (t *progressTracker) emit() {
+	if t.report == nil {
+		return
+	}
+	t.lastReport = time.Now()
+	t.state.ETASeconds = -1
+	if elapsed := time.Since(t.started); t.state.BytesDone > 0 && elapsed > 0 {
+		remaining := t.state.BytesTotal - t.state.BytesDone
+		if remaining < 0 {
+			remaining = 0
+		}
+		t.state.ETASeconds = int64(elapsed.Seconds() * float64(remaining) / float64(t.state.BytesDone))
+	}
+	t.report(t.state)
+}
+
+// progressReader counts bytes as they are read and stops once ctx is cancelled, so a
+// large document does not have to finish copying before a cancelled job unwinds
+type progressReader struct {
+	ctx     context.Context
+	r       io.Reader
+	tracker *progressTracker
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := p.r.Read(b)
+	p.tracker.addBytes(int64(n))
+	return n, err
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateZipFileReportsProgress(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 3)
+	content := map[int64][]byte{}
+	var total int64
+	for _, id := range ids {
+		content[id] = onePagePDF("progress")
+		total += int64(len(content[id]))
+	}
+	root := writeLake(t, d, content)
+
+	var reports []ProductionProgress
+	_, err := d.CreateZipFile(context.Background(), "PR-001", ids, root, t.TempDir(), ProductionSettings{},
+		func(p ProductionProgress) { reports = append(reports, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := reports[len(reports)-1]
+	if last.FilesDone != 3 || last.FilesTotal != 3 || last.BytesDone != total || last.BytesTotal != total {
+		t.Errorf("final progress %+v, want 3 of 3 files and %d of %d bytes", last, total, total)
+	}
+	if last.ETASeconds != 0 {
+		t.Errorf("ETA at completion is %d, want 0", last.ETASeconds)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].BytesDone < reports[i-1].BytesDone || reports[i].FilesDone < reports[i-1].FilesDone {
+			t.Fatalf("progress went backwards: %+v then %+v", reports[i-1], reports[i])
+		}
+	}
+}
+
+func TestCreateZipFileCancelRemovesPartialOutput(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 3)
+	content := map[int64][]byte{}
+	for _, id := range ids {
+		content[id] = append(onePagePDF("big"), bytes.Repeat([]byte("x"), 4<<20)...)
+	}
+	root := writeLake(t, d, content)
+	before, err := d.GetBatesSequence(DefaultBatesPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outputDir := t.TempDir()
+	_, err = d.CreateZipFile(ctx, "PR-001", ids, root, outputDir, ProductionSettings{}, func(p ProductionProgress) {
+		if p.BytesDone > 0 {
+			cancel() // Mid-document
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(outputDir, "*")); len(left) != 0 {
+		t.Errorf("cancelled production left %v behind", left)
+	}
+	after, err := d.GetBatesSequence(DefaultBatesPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != nil && after.NextNumber != before.NextNumber {
+		t.Errorf("Bates sequence moved from %d to %d", before.NextNumber, after.NextNumber)
+	}
+	if before == nil && after != nil && after.NextNumber != after.StartNumber {
+		t.Errorf("Bates numbers %d-%d were not released", after.StartNumber, after.NextNumber-1)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(os.TempDir(), "production-entry-*")); len(tmp) != 0 {
+		t.Errorf("staged entries left in the temp directory: %v", tmp)
+	}
+}
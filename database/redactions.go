@@ -0,0 +1,386 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"signal-from-noise/assert"
+)
+
+// Redaction reason codes, printed in place of redacted text
+const (
+	RedactionPII         = "PII" // Personal identifiers: SSN, date of birth, contact details
+	RedactionMedical     = "MED" // Medical information
+	RedactionPrivileged  = "AC"  // Attorney-client privilege
+	RedactionWorkProduct = "WP"  // Attorney work product
+)
+
+// redactionReasonLabels describe each reason code for the redaction log
+var redactionReasonLabels = map[string]string{
+	RedactionPII:         "Personally Identifiable Information",
+	RedactionMedical:     "Medical Information",
+	RedactionPrivileged:  "Attorney-Client Privilege",
+	RedactionWorkProduct: "Attorney Work Product",
+}
+
+// Actions recorded in the redaction log
+const (
+	RedactionActionAdded   = "added"
+	RedactionActionRemoved = "removed"
+)
+
+// Redaction marks a range of a document to be removed before production
+// PDFs are redacted page by page: Page is 1-based and the offsets count characters of
+// that page's text as returned by GetPDFPageText. Other documents are redacted in their
+// extracted text, with Page 0 and byte offsets into it. End is exclusive
+type Redaction struct {
+	ID         int64     `json:"id"`
+	FileID     int64     `json:"file_id"`
+	Page       int       `json:"page"`
+	Start      int       `json:"start"`
+	End        int       `json:"end"`
+	ReasonCode string    `json:"reason_code"`
+	RedactedBy string    `json:"redacted_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RedactionLogEntry records who added or removed a redaction
+// The redacted text itself is never logged
+type RedactionLogEntry struct {
+	ID          int64     `json:"id"`
+	RedactionID int64     `json:"redaction_id"`
+	FileID      int64     `json:"file_id"`
+	Action      string    `json:"action"`
+	Actor       string    `json:"actor"`
+	Page        int       `json:"page"`
+	Start       int       `json:"start"`
+	End         int       `json:"end"`
+	ReasonCode  string    `json:"reason_code"`
+	At          time.Time `json:"at"`
+}
+
+// RedactionReasonLabel returns the description of a reason code, or "" if it is unknown
+func RedactionReasonLabel(code string) string {
+	return redactionReasonLabels[code]
+}
+
+// isPDFPath reports whether a document is redacted as a PDF rather than as text
+func isPDFPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pdf")
+}
+
+// AddRedaction records a redaction and logs who made it, returning its ID
+// The range is checked against the document's current text; sourceRoot is the data lake
+// root, needed to read PDF pages
+func (d *DB) AddRedaction(r Redaction, sourceRoot string) (int64, error) {
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to record redactions")
+
+	r.ReasonCode = strings.ToUpper(strings.TrimSpace(r.ReasonCode))
+	r.RedactedBy = strings.TrimSpace(r.RedactedBy)
+	if redactionReasonLabels[r.ReasonCode] == "" {
+		return 0, fmt.Errorf("unknown redaction reason code %q", r.ReasonCode)
+	}
+	if r.RedactedBy == "" {
+		return 0, fmt.Errorf("redactions must record who made them")
+	}
+	if r.Start < 0 || r.End <= r.Start {
+		return 0, fmt.Errorf("invalid redaction range %d-%d", r.Start, r.End)
+	}
+
+	var path string
+	err := d.db.QueryRow("SELECT path FROM files WHERE id = ?", r.FileID).Scan(&path)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("file %d not found", r.FileID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	// The range must fall inside the text it will be applied to
+	var length int
+	unit := "characters"
+	if isPDFPath(path) {
+		if r.Page < 1 {
+			return 0, fmt.Errorf("PDF redactions need a page number")
+		}
+		data, err := os.ReadFile(sourcePath(sourceRoot, path))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		pages, err := pdfPageTexts(data)
+		if err != nil {
+			return 0, fmt.Errorf("%s cannot be redacted: %w", path, err)
+		}
+		if r.Page > len(pages) {
+			return 0, fmt.Errorf("%s has %d pages", path, len(pages))
+		}
+		length = len(pages[r.Page-1])
+	} else {
+		if r.Page != 0 {
+			return 0, fmt.Errorf("only PDFs are redacted by page; use page 0 for extracted text")
+		}
+		text, err := d.getExtractedText(r.FileID)
+		if err != nil {
+			return 0, err
+		}
+		if text == "" {
+			return 0, fmt.Errorf("%s has no extracted text to redact; extract its text first", path)
+		}
+		length = len(text)
+		unit = "bytes"
+	}
+	if r.End > length {
+		return 0, fmt.Errorf("redaction ends at %d but the text is %d %s long", r.End, length, unit)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	result, err := tx.Exec(`
+		INSERT INTO redactions (file_id, page, start_offset, end_offset, reason_code, redacted_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, r.FileID, r.Page, r.Start, r.End, r.ReasonCode, r.RedactedBy, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record redaction: %w", err)
+	}
+	r.ID, err = result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get redaction ID: %w", err)
+	}
+	if err := logRedaction(tx, r, RedactionActionAdded, r.RedactedBy, now); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit redaction: %w", err)
+	}
+	return r.ID, nil
+}
+
+// RemoveRedaction deletes a redaction, logging who removed it
+func (d *DB) RemoveRedaction(id int64, actor string) error {
+	actor = strings.TrimSpace(actor)
+	if actor == "" {
+		return fmt.Errorf("removing a redaction must record who removed it")
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	r := Redaction{ID: id}
+	err = tx.QueryRow("SELECT file_id, page, start_offset, end_offset, reason_code FROM redactions WHERE id = ?", id).
+		Scan(&r.FileID, &r.Page, &r.Start, &r.End, &r.ReasonCode)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("redaction %d not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get redaction: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM redactions WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete redaction: %w", err)
+	}
+	if err := logRedaction(tx, r, RedactionActionRemoved, actor, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// logRedaction appends a redaction log entry and its audit entry inside the caller's transaction
+func logRedaction(tx *sql.Tx, r Redaction, action, actor, at string) error {
+	_, err := tx.Exec(`
+		INSERT INTO redaction_log (redaction_id, file_id, action, actor, page, start_offset, end_offset, reason_code, at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.ID, r.FileID, action, actor, r.Page, r.Start, r.End, r.ReasonCode, at)
+	if err != nil {
+		return fmt.Errorf("failed to log redaction: %w", err)
+	}
+	return appendAudit(tx, AuditRedaction, r.FileID, 0, actor, map[string]interface{}{
+		"action":       action,
+		"redaction_id": r.ID,
+		"page":         r.Page,
+		"start":        r.Start,
+		"end":          r.End,
+		"reason_code":  r.ReasonCode,
+	})
+}
+
+// GetRedactions returns a file's redactions in page and offset order
+func (d *DB) GetRedactions(fileID int64) ([]Redaction, error) {
+	redactions, err := d.getRedactionsByFile([]int64{fileID})
+	if err != nil {
+		return nil, err
+	}
+	return redactions[fileID], nil
+}
+
+// getRedactionsByFile returns the redactions of the given files, in page and offset order
+func (d *DB) getRedactionsByFile(fileIDs []int64) (map[int64][]Redaction, error) {
+	redactions := make(map[int64][]Redaction)
+	for start := 0; start < len(fileIDs); start += 500 {
+		end := start + 500
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+		chunk := fileIDs[start:end]
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+
+		rows, err := d.db.Query(`
+			SELECT id, file_id, page, start_offset, end_offset, reason_code, redacted_by, created_at
+			FROM redactions WHERE file_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")+`)
+			ORDER BY file_id, page, start_offset, id
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query redactions: %w", err)
+		}
+		for rows.Next() {
+			var r Redaction
+			var createdAt string
+			if err := rows.Scan(&r.ID, &r.FileID, &r.Page, &r.Start, &r.End, &r.ReasonCode, &r.RedactedBy, &createdAt); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan redaction: %w", err)
+			}
+			r.CreatedAt = parseTimestamp(createdAt)
+			redactions[r.FileID] = append(redactions[r.FileID], r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating redactions: %w", err)
+		}
+	}
+	return redactions, nil
+}
+
+// GetRedactionLog returns the redaction log for a file, oldest first
+// A fileID of 0 returns the log for every file
+func (d *DB) GetRedactionLog(fileID int64) ([]RedactionLogEntry, error) {
+	query := `
+		SELECT id, redaction_id, file_id, action, actor, page, start_offset, end_offset, reason_code, at
+		FROM redaction_log
+	`
+	args := []interface{}{}
+	if fileID != 0 {
+		query += " WHERE file_id = ?"
+		args = append(args, fileID)
+	}
+	query += " ORDER BY id"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query redaction log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []RedactionLogEntry
+	for rows.Next() {
+		var e RedactionLogEntry
+		var at string
+		if err := rows.Scan(&e.ID, &e.RedactionID, &e.FileID, &e.Action, &e.Actor, &e.Page, &e.Start, &e.End,
+			&e.ReasonCode, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan redaction log entry: %w", err)
+		}
+		e.At = parseTimestamp(at)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating redaction log: %w", err)
+	}
+	return entries, nil
+}
+
+// GetPDFPageText returns the text of each page of a PDF in the data lake, the text
+// that PDF redaction offsets count characters of
+func (d *DB) GetPDFPageText(fileID int64, sourceRoot string) ([]string, error) {
+	var path string
+	if err := d.db.QueryRow("SELECT path FROM files WHERE id = ?", fileID).Scan(&path); err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	if !isPDFPath(path) {
+		return nil, fmt.Errorf("%s is not a PDF", path)
+	}
+	data, err := os.ReadFile(sourcePath(sourceRoot, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	pages, err := pdfPageTexts(data)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = latin1String(page)
+	}
+	return texts, nil
+}
+
+// redactText replaces each redacted range of text with "[REDACTED: CODE]"
+// Overlapping ranges are merged, listing every code; ranges are widened to whole
+// characters so no partial UTF-8 sequence is left behind
+func redactText(text string, redactions []Redaction) (string, error) {
+	type span struct {
+		start, end int
+		codes      []string
+	}
+	var spans []span
+	sorted := append([]Redaction(nil), redactions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	for _, r := range sorted {
+		if r.End > len(text) {
+			return "", fmt.Errorf("redaction %d ends at %d but the text is %d bytes long; the text has changed since it was redacted",
+				r.ID, r.End, len(text))
+		}
+		start, end := r.Start, r.End
+		for start > 0 && !utf8.RuneStart(text[start]) {
+			start--
+		}
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end++
+		}
+		if n := len(spans); n > 0 && start <= spans[n-1].end {
+			last := &spans[n-1]
+			if end > last.end {
+				last.end = end
+			}
+			if !containsString(last.codes, r.ReasonCode) {
+				last.codes = append(last.codes, r.ReasonCode)
+			}
+			continue
+		}
+		spans = append(spans, span{start, end, []string{r.ReasonCode}})
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, s := range spans {
+		b.WriteString(text[pos:s.start])
+		b.WriteString("[REDACTED: " + strings.Join(s.codes, ", ") + "]")
+		pos = s.end
+	}
+	b.WriteString(text[pos:])
+	return b.String(), nil
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
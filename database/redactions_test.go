@@ -0,0 +1,219 @@
+package database
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// textDocument gives a file an email native in a fresh data lake and extracts its text
+func textDocument(t *testing.T, d *DB, fileID int64, text string) string {
+	t.Helper()
+	root := t.TempDir()
+	moveToLake(t, d, root, fileID, "Mail/redact_me.eml", []byte(text))
+	if _, err := d.IndexContentHashes(root); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ExtractText(root); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestRedactTextMergesOverlapsAndWidensToCharacters(t *testing.T) {
+	text := "SSN 123-45-6789, née Smith, seen by Dr. Jones"
+	redactions := []Redaction{
+		{ID: 2, Start: 8, End: 15, ReasonCode: RedactionPII},
+		{ID: 1, Start: 4, End: 10, ReasonCode: RedactionPII},
+		{ID: 3, Start: 12, End: 15, ReasonCode: RedactionMedical},
+		// Starts inside the two-byte "é"
+		{ID: 4, Start: strings.Index(text, "é") + 1, End: strings.Index(text, " Smith"), ReasonCode: RedactionPII},
+	}
+	got, err := redactText(text, redactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SSN [REDACTED: PII, MED], n[REDACTED: PII] Smith, seen by Dr. Jones"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	_, err = redactText("short", []Redaction{{ID: 9, Start: 0, End: 10, ReasonCode: RedactionPII}})
+	if err == nil || !strings.Contains(err.Error(), "text has changed") {
+		t.Errorf("got %v, want a changed-text error", err)
+	}
+}
+
+func TestAddRedactionValidates(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := textDocument(t, d, ids[0], "Call me at 555-0100.")
+
+	cases := []struct {
+		name string
+		r    Redaction
+		want string
+	}{
+		{"unknown reason", Redaction{FileID: ids[0], End: 3, ReasonCode: "XX", RedactedBy: "kim"}, "unknown redaction reason"},
+		{"no reviewer", Redaction{FileID: ids[0], End: 3, ReasonCode: RedactionPII}, "who made them"},
+		{"empty range", Redaction{FileID: ids[0], Start: 3, End: 3, ReasonCode: RedactionPII, RedactedBy: "kim"}, "invalid redaction range"},
+		{"unknown file", Redaction{FileID: 999999, End: 3, ReasonCode: RedactionPII, RedactedBy: "kim"}, "not found"},
+		{"page on text", Redaction{FileID: ids[0], Page: 1, End: 3, ReasonCode: RedactionPII, RedactedBy: "kim"}, "only PDFs"},
+		{"past the text", Redaction{FileID: ids[0], End: 50, ReasonCode: RedactionPII, RedactedBy: "kim"}, "20 bytes long"},
+		// The seeded file is a PDF with no native in this data lake
+		{"PDF without page", Redaction{FileID: ids[1], End: 3, ReasonCode: RedactionPII, RedactedBy: "kim"}, "need a page"},
+	}
+	for _, c := range cases {
+		_, err := d.AddRedaction(c.r, root)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want an error containing %q", c.name, err, c.want)
+		}
+	}
+
+	mustExec(t, d, "UPDATE files SET path = 'Mail/no_text.eml', extracted_text = NULL WHERE id = ?", ids[1])
+	_, err := d.AddRedaction(Redaction{FileID: ids[1], End: 3, ReasonCode: RedactionPII, RedactedBy: "kim"}, root)
+	if err == nil || !strings.Contains(err.Error(), "no extracted text") {
+		t.Errorf("got %v, want a missing-text error", err)
+	}
+	if redactions, _ := d.GetRedactions(ids[0]); len(redactions) != 0 {
+		t.Errorf("rejected redactions were stored: %+v", redactions)
+	}
+}
+
+func TestRedactionsAreStoredAndLogged(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	root := textDocument(t, d, ids[0], "Call me at 555-0100 about my diagnosis.")
+
+	phone, err := d.AddRedaction(Redaction{FileID: ids[0], Start: 11, End: 19, ReasonCode: " pii ", RedactedBy: " kim "}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnosis, err := d.AddRedaction(Redaction{FileID: ids[0], Start: 29, End: 38, ReasonCode: RedactionMedical, RedactedBy: "lee"}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redactions, err := d.GetRedactions(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redactions) != 2 || redactions[0].ID != phone || redactions[0].ReasonCode != RedactionPII ||
+		redactions[0].RedactedBy != "kim" || redactions[1].Start != 29 {
+		t.Fatalf("stored redactions %+v", redactions)
+	}
+
+	if err := d.RemoveRedaction(diagnosis, " "); err == nil {
+		t.Error("removed a redaction without recording who removed it")
+	}
+	if err := d.RemoveRedaction(diagnosis, "pat"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RemoveRedaction(diagnosis, "pat"); err == nil {
+		t.Error("removed a redaction twice")
+	}
+	if redactions, _ = d.GetRedactions(ids[0]); len(redactions) != 1 {
+		t.Errorf("got %d redactions after removal, want 1", len(redactions))
+	}
+
+	log, err := d.GetRedactionLog(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range log {
+		got = append(got, e.Action+" "+e.Actor+" "+e.ReasonCode)
+	}
+	want := []string{"added kim PII", "added lee MED", "removed pat MED"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redaction log %v, want %v", got, want)
+	}
+	if all, _ := d.GetRedactionLog(0); len(all) != 3 {
+		t.Errorf("log for every file has %d entries, want 3", len(all))
+	}
+}
+
+func TestAddRedactionChecksPDFPages(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("Bluebird")})
+
+	pages, err := d.GetPDFPageText(ids[0], root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := strings.Index(pages[0], "Bluebird")
+	if len(pages) != 1 || start < 0 {
+		t.Fatalf("page text %q", pages)
+	}
+	r := Redaction{FileID: ids[0], Page: 1, Start: start, End: start + 8, ReasonCode: RedactionPrivileged, RedactedBy: "kim"}
+	if _, err := d.AddRedaction(r, root); err != nil {
+		t.Fatal(err)
+	}
+	r.Page = 2
+	if _, err := d.AddRedaction(r, root); err == nil || !strings.Contains(err.Error(), "1 pages") {
+		t.Errorf("got %v, want a page count error", err)
+	}
+	r.Page, r.End = 1, len(pages[0])+1
+	if _, err := d.AddRedaction(r, root); err == nil || !strings.Contains(err.Error(), "characters long") {
+		t.Errorf("got %v, want a page length error", err)
+	}
+}
+
+func TestExtractTextReadsPDFPages(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("Bluebird")})
+	if n, err := d.ExtractText(root); err != nil || n != 1 {
+		t.Fatalf("extracted %d files (%v), want 1", n, err)
+	}
+	if text, _ := extractedText(t, d, ids[0]); !strings.Contains(text, "Bluebird") {
+		t.Errorf("PDF text %q", text)
+	}
+}
+
+func TestProductionRedactsExtractedText(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	root := textDocument(t, d, ids[0], "SSN 123-45-6789 on file.")
+	_, err := d.AddRedaction(Redaction{FileID: ids[0], Start: 4, End: 15, ReasonCode: RedactionPII, RedactedBy: "kim"}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := produce(t, d, ids, root, ProductionSettings{})
+	doc := out.Documents[0]
+	if doc.SlipSheet != SlipSheetRedacted || doc.Redactions != 1 {
+		t.Fatalf("produced %+v, want the native withheld with one redaction", doc)
+	}
+	r, err := zip.OpenReader(out.Volumes[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var text string
+	for _, f := range r.File {
+		if f.Name == doc.TextPath {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			text = string(data)
+		}
+	}
+	if text != "SSN [REDACTED: PII] on file." {
+		t.Errorf("produced text %q", text)
+	}
+
+	// Text that shrank since the redaction was made is refused rather than guessed at
+	mustExec(t, d, "UPDATE files SET extracted_text = 'SSN' WHERE id = ?", ids[0])
+	_, err = d.CreateZipFile(context.Background(), "PR-001", ids, root, t.TempDir(), ProductionSettings{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "text has changed") {
+		t.Errorf("got %v, want a changed-text error", err)
+	}
+}
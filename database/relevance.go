@@ -0,0 +1,292 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+	"signal-from-noise/textmodel"
+)
+
+// Relevance model settings
+const (
+	// MinRelevanceExamples is the fewest coded documents per class before training
+	MinRelevanceExamples = 5
+	// RelevanceRetrainInterval is how many new decisions trigger automatic retraining
+	RelevanceRetrainInterval = 25
+	// relevanceFolds is the cross-validation fold count in the training report
+	relevanceFolds = 5
+)
+
+// Sort orders accepted by FileFilters.SortBy
+const (
+	SortByDate      = "date"      // Newest first (default)
+	SortByRelevance = "relevance" // Highest relevance score first, unscored last
+)
+
+// RelevanceStatus reports a production request's relevance model and how current it is
+type RelevanceStatus struct {
+	ProductionRequestID string     `json:"production_request_id"`
+	Trained             bool       `json:"trained"`
+	TrainedAt           *time.Time `json:"trained_at"`
+	PositiveCount       int        `json:"positive_count"` // Responsive or privileged examples at training
+	NegativeCount       int        `json:"negative_count"` // Non-responsive examples at training
+	VocabularySize      int        `json:"vocabulary_size"`
+	// Cross-validated accuracy on the coded documents; 0 when folds lacked both classes
+	Accuracy            float64  `json:"accuracy"`
+	CodedSinceTraining  int      `json:"coded_since_training"` // Documents coded after training; recoding is not counted
+	NeedsRetraining     bool     `json:"needs_retraining"`
+	ScoredCount         int      `json:"scored_count"`
+	UnreviewedHistogram []int    `json:"unreviewed_histogram"` // Unreviewed files per score decile, 0.0-0.1 first
+	ResponsiveTerms     []string `json:"responsive_terms"`
+	NonResponsiveTerms  []string `json:"non_responsive_terms"`
+}
+
+// relevanceModelRecord is the stored model row for a production request
+type relevanceModelRecord struct {
+	id        int64
+	trainedAt time.Time
+	positive  int
+	negative  int
+	vocabSize int
+	accuracy  float64
+	model     *textmodel.LogisticModel
+}
+
+// TrainRelevanceModel trains a responsiveness classifier from the request's review decisions
+// and scores every file. Privileged documents count as responsive: they answer the request
+// even though they are withheld.
+func (d *DB) TrainRelevanceModel(requestID string) (*RelevanceStatus, error) {
+	op := logging.StartOperation("TrainRelevanceModel", map[string]interface{}{
+		"production_request_id": requestID,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to train a relevance model")
+
+	if _, err := d.GetProductionRequest(requestID); err != nil {
+		return nil, err
+	}
+
+	labels := make(map[int64]bool)
+	rows, err := d.db.Query("SELECT file_id, decision FROM review_decisions WHERE production_request_id = ?", requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query review decisions: %w", err)
+	}
+	var positives, negatives int
+	for rows.Next() {
+		var fileID int64
+		var decision string
+		if err := rows.Scan(&fileID, &decision); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan review decision: %w", err)
+		}
+		responsive := decision != DecisionNonResponsive
+		labels[fileID] = responsive
+		if responsive {
+			positives++
+		} else {
+			negatives++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating review decisions: %w", err)
+	}
+
+	// ASSUMPTION: A handful of each class is the least that yields a useful ranking
+	if positives < MinRelevanceExamples || negatives < MinRelevanceExamples {
+		return nil, fmt.Errorf("training needs at least %d responsive and %d non-responsive decisions, have %d and %d",
+			MinRelevanceExamples, MinRelevanceExamples, positives, negatives)
+	}
+
+	corpus, err := d.loadCorpus(nil)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(corpus))
+	var codedTexts []string
+	var codedLabels []bool
+	for i, doc := range corpus {
+		texts[i] = doc.text
+		if label, ok := labels[doc.fileID]; ok {
+			codedTexts = append(codedTexts, doc.text)
+			codedLabels = append(codedLabels, label)
+		}
+	}
+
+	model, err := textmodel.TrainLogistic(texts, codedTexts, codedLabels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to train relevance model: %w", err)
+	}
+	accuracy, err := model.CrossValidate(codedTexts, codedLabels, relevanceFolds)
+	if err != nil {
+		accuracy = 0
+	}
+
+	modelJSON, err := json.Marshal(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode relevance model: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO relevance_models (production_request_id, positive_count, negative_count, vocabulary_size, accuracy, model, trained_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, requestID, positives, negatives, model.Vectorizer.Size(), accuracy, string(modelJSON), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store relevance model: %w", err)
+	}
+	modelID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relevance model id: %w", err)
+	}
+
+	// Only the latest model is kept; scores always come from it
+	if _, err := tx.Exec("DELETE FROM relevance_models WHERE production_request_id = ? AND id != ?", requestID, modelID); err != nil {
+		return nil, fmt.Errorf("failed to remove old relevance models: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM relevance_scores WHERE production_request_id = ?", requestID); err != nil {
+		return nil, fmt.Errorf("failed to clear relevance scores: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO relevance_scores (file_id, production_request_id, score) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare relevance score insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, doc := range corpus {
+		if _, err := stmt.Exec(doc.fileID, requestID, model.Score(doc.text)); err != nil {
+			return nil, fmt.Errorf("failed to store relevance score for file %d: %w", doc.fileID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit relevance model: %w", err)
+	}
+
+	logging.LogResult("TrainRelevanceModel", len(corpus), map[string]interface{}{
+		"positive": positives,
+		"negative": negatives,
+		"accuracy": accuracy,
+	})
+	return d.GetRelevanceStatus(requestID)
+}
+
+// RefreshRelevanceModel retrains a request's model once enough new decisions have been
+// recorded, returning the new status, or nil when the model is current or has never
+// been trained explicitly. Training takes a while, so callers run it in the background
+func (d *DB) RefreshRelevanceModel(requestID string) (*RelevanceStatus, error) {
+	status, err := d.GetRelevanceStatus(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if !status.NeedsRetraining {
+		return nil, nil
+	}
+	status, err = d.TrainRelevanceModel(requestID)
+	if err != nil {
+		logging.LogError("RefreshRelevanceModel", err, map[string]interface{}{
+			"production_request_id": requestID,
+		})
+		return nil, err
+	}
+	return status, nil
+}
+
+// loadRelevanceModel returns the request's current model, or nil if none has been trained
+func (d *DB) loadRelevanceModel(requestID string) (*relevanceModelRecord, error) {
+	var rec relevanceModelRecord
+	var trainedAt, modelJSON string
+	err := d.db.QueryRow(`
+		SELECT id, trained_at, positive_count, negative_count, vocabulary_size, accuracy, model
+		FROM relevance_models
+		WHERE production_request_id = ?
+		ORDER BY id DESC LIMIT 1
+	`, requestID).Scan(&rec.id, &trainedAt, &rec.positive, &rec.negative, &rec.vocabSize, &rec.accuracy, &modelJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load relevance model: %w", err)
+	}
+	rec.trainedAt = parseTimestamp(trainedAt)
+	rec.model = &textmodel.LogisticModel{}
+	if err := json.Unmarshal([]byte(modelJSON), rec.model); err != nil {
+		return nil, fmt.Errorf("failed to decode relevance model: %w", err)
+	}
+	return &rec, nil
+}
+
+// GetRelevanceStatus reports whether a request's model is trained and how current it is
+func (d *DB) GetRelevanceStatus(requestID string) (*RelevanceStatus, error) {
+	rec, err := d.loadRelevanceModel(requestID)
+	if err != nil {
+		return nil, err
+	}
+	status := &RelevanceStatus{
+		ProductionRequestID: requestID,
+		UnreviewedHistogram: make([]int, 10),
+	}
+	if rec == nil {
+		return status, nil
+	}
+
+	status.Trained = true
+	status.TrainedAt = &rec.trainedAt
+	status.PositiveCount = rec.positive
+	status.NegativeCount = rec.negative
+	status.VocabularySize = rec.vocabSize
+	status.Accuracy = rec.accuracy
+	status.ResponsiveTerms = rec.model.TopTerms(10, true)
+	status.NonResponsiveTerms = rec.model.TopTerms(10, false)
+
+	// Decision timestamps have one-second resolution, so new decisions are
+	// counted against the training set size rather than by time
+	var coded int
+	err = d.db.QueryRow("SELECT COUNT(*) FROM review_decisions WHERE production_request_id = ?", requestID).Scan(&coded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count review decisions: %w", err)
+	}
+	if coded > rec.positive+rec.negative {
+		status.CodedSinceTraining = coded - rec.positive - rec.negative
+	}
+	status.NeedsRetraining = status.CodedSinceTraining >= RelevanceRetrainInterval
+
+	rows, err := d.db.Query(`
+		SELECT MIN(CAST(s.score * 10 AS INTEGER), 9), COUNT(*)
+		FROM relevance_scores s
+		WHERE s.production_request_id = ?
+		  AND s.file_id NOT IN (SELECT file_id FROM review_decisions WHERE production_request_id = ?)
+		GROUP BY 1
+	`, requestID, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relevance histogram: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan relevance histogram: %w", err)
+		}
+		status.UnreviewedHistogram[bucket] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating relevance histogram: %w", err)
+	}
+
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM relevance_scores WHERE production_request_id = ?", requestID).
+		Scan(&status.ScoredCount); err != nil {
+		return nil, fmt.Errorf("failed to count relevance scores: %w", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+// codeForRelevance gives files distinguishable text and records decisions on them:
+// the first responsive files mention the grievance, the rest are lunch orders
+func codeForRelevance(t *testing.T, d *DB, ids []int64, responsive int) {
+	t.Helper()
+	for i, id := range ids {
+		text := fmt.Sprintf("lunch order sandwiches catering %d", i)
+		decision := DecisionNonResponsive
+		if i < responsive {
+			text = fmt.Sprintf("grievance retaliation supervisor promotion %d", i)
+			decision = DecisionResponsive
+		}
+		mustExec(t, d, "UPDATE files SET extracted_text = ? WHERE id = ?", text, id)
+		if err := d.RecordReviewDecision(id, "PR-001", decision, "reviewer", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecordReviewDecisionsDoesNotRetrain(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 10+RelevanceRetrainInterval)
+	codeForRelevance(t, d, ids[:10], 5)
+
+	if status, err := d.RefreshRelevanceModel("PR-001"); err != nil || status != nil {
+		t.Fatalf("untrained model refreshed: %+v, %v", status, err)
+	}
+	first, err := d.TrainRelevanceModel("PR-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codeForRelevance(t, d, ids[10:], RelevanceRetrainInterval/2)
+	status, err := d.GetRelevanceStatus("PR-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.TrainedAt.Equal(*first.TrainedAt) || status.PositiveCount != 5 || !status.NeedsRetraining {
+		t.Fatalf("recording decisions retrained the model: %+v", status)
+	}
+
+	status, err = d.RefreshRelevanceModel("PR-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == nil || status.CodedSinceTraining != 0 || status.PositiveCount+status.NegativeCount != len(ids) {
+		t.Errorf("refresh gave %+v, want a model trained on all %d decisions", status, len(ids))
+	}
+	if status, err = d.RefreshRelevanceModel("PR-001"); err != nil || status != nil {
+		t.Errorf("current model refreshed again: %+v, %v", status, err)
+	}
+}
+
+func TestTrainRelevanceModelNeedsBothClasses(t *testing.T) {
+	d := newTestDB(t)
+	codeForRelevance(t, d, firstFileIDs(t, d, 8), 8)
+	if _, err := d.TrainRelevanceModel("PR-001"); err == nil {
+		t.Error("trained on responsive decisions alone")
+	}
+}
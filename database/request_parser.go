@@ -0,0 +1,347 @@
+package database
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"signal-from-noise/logging"
+	"signal-from-noise/textmodel"
+)
+
+// FilterDraft is a proposed set of filters parsed from a request description
+// The user confirms or edits it; nothing here is applied automatically
+type FilterDraft struct {
+	Filters        FileFilters      `json:"filters"`
+	DateRanges     []DateRangeMatch `json:"date_ranges"`     // Every range found; Filters uses their envelope
+	MentionedDates []time.Time      `json:"mentioned_dates"` // Single dates that are events, not ranges
+	People         []PersonMatch    `json:"people"`
+	UnmatchedNames []string         `json:"unmatched_names"` // Names with no matching email address
+	KeyTerms       []string         `json:"key_terms"`
+}
+
+// DateRangeMatch is one date range found in the text
+type DateRangeMatch struct {
+	Text  string    `json:"text"` // Phrase the range was read from
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// PersonMatch is a name in the text matched to a known email address
+type PersonMatch struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// DraftFilters proposes filters for a production request from its description
+func (d *DB) DraftFilters(requestID string) (*FilterDraft, error) {
+	op := logging.StartOperation("DraftFilters", map[string]interface{}{
+		"production_request_id": requestID,
+	})
+	defer op.EndOperation()
+
+	pr, err := d.GetProductionRequest(requestID)
+	if err != nil {
+		return nil, err
+	}
+	people, err := d.GetPeople()
+	if err != nil {
+		return nil, err
+	}
+
+	draft := ParseRequestDescription(pr.Description, people, time.Now().UTC())
+	draft.Filters.ProductionRequestID = requestID
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"date_ranges":     len(draft.DateRanges),
+		"people":          len(draft.People),
+		"unmatched_names": len(draft.UnmatchedNames),
+		"key_terms":       len(draft.KeyTerms),
+	})
+	return &draft, nil
+}
+
+// Date expressions: "January 1, 2024", "Jan. 2020", "1/10/2024"
+const (
+	monthPattern = `(?:January|February|March|April|May|June|July|August|September|October|November|December|` +
+		`Jan|Feb|Mar|Apr|Jun|Jul|Aug|Sep|Sept|Oct|Nov|Dec)\.?`
+	datePattern = `(?:` + monthPattern + `\s+\d{1,2},?\s+\d{4}|` + monthPattern + `\s+\d{4}|\d{1,2}/\d{1,2}/\d{4})`
+)
+
+var (
+	dateRegex = regexp.MustCompile(`(?i)` + datePattern)
+
+	// "from X through Y", "between X and Y", "X to the present"
+	// "and" only joins a range after "between"; "on X and Y" lists two dates
+	rangeRegex = regexp.MustCompile(`(?i)(?:between\s+(` + datePattern + `)\s+and|(?:from\s+)?(` + datePattern +
+		`)\s+(?:through|thru|to|until|-))\s+(` + datePattern + `|the\s+present|present|today|date)`)
+
+	// "calendar years 2023 and 2024", "fiscal years 2022, 2023, and 2024"
+	yearSpanRegex = regexp.MustCompile(`(?i)\b(calendar|fiscal)?\s*years?\s+(\d{4}(?:\s*(?:,|and|through|to|-)\s*(?:and\s+)?\d{4})*)`)
+	yearRegex     = regexp.MustCompile(`\d{4}`)
+
+	// "filed in 2024", "during June 2024"
+	inPeriodRegex = regexp.MustCompile(`(?i)\b(?:in|during)\s+(` + monthPattern + `\s+\d{4}|\d{4})\b`)
+
+	// Capitalized words, including hyphenated surnames like Cortelyou-Hamilton
+	nameRegex = regexp.MustCompile(`\b[A-Z][a-zA-Z']+(?:-[A-Z][a-zA-Z']+)*\b`)
+)
+
+// requestBoilerplate is language common to every request that says nothing about scope
+var requestBoilerplate = map[string]bool{
+	"documents": true, "document": true, "related": true, "relating": true, "regarding": true,
+	"communications": true, "communication": true, "emails": true, "email": true,
+	"memoranda": true, "memorandum": true, "agency": true, "employment": true, "present": true,
+	"submitted": true, "support": true, "supporting": true, "received": true, "including": true,
+	"through": true, "calendar": true, "fiscal": true, "years": true, "year": true, "prior": true,
+	"filed": true, "referenced": true, "evidencing": true, "two": true, "issue": true,
+	"january": true, "february": true, "march": true, "april": true, "may": true, "june": true,
+	"july": true, "august": true, "september": true, "october": true, "november": true, "december": true,
+}
+
+// nonNames are capitalized words that are never people
+var nonNames = map[string]bool{
+	"All": true, "Any": true, "You": true, "Your": true, "The": true, "Agency": true,
+	"Regional": true, "Solicitor": true, "Office": true, "Field": true, "Federal": true,
+	"Department": true, "Interior": true, "Land": true, "Law": true, "Specialist": true,
+	"Title": true, "Act": true, "Issue": true, "Chief": true, "Justice": true, "Commission": true,
+}
+
+// ParseRequestDescription extracts a draft filter set from request text
+// now resolves open-ended ranges such as "to the present"
+func ParseRequestDescription(description string, people *PeopleList, now time.Time) FilterDraft {
+	var draft FilterDraft
+
+	draft.DateRanges = parseDateRanges(description, now)
+	if len(draft.DateRanges) > 0 {
+		// FileFilters holds one range, so propose the envelope of all of them
+		start, end := draft.DateRanges[0].Start, draft.DateRanges[0].End
+		for _, r := range draft.DateRanges[1:] {
+			if r.Start.Before(start) {
+				start = r.Start
+			}
+			if r.End.After(end) {
+				end = r.End
+			}
+		}
+		draft.Filters.DateStart = &start
+		draft.Filters.DateEnd = &end
+	} else {
+		for _, m := range dateRegex.FindAllString(description, -1) {
+			if t, _, ok := parseDateExpression(m); ok {
+				draft.MentionedDates = append(draft.MentionedDates, t)
+			}
+		}
+	}
+
+	var matched []string
+	for _, name := range candidateNames(description) {
+		if email := matchPerson(name, people); email != "" {
+			matched = append(matched, name)
+			draft.People = append(draft.People, PersonMatch{Name: name, Email: email})
+			draft.Filters.People = append(draft.Filters.People, email)
+		} else {
+			draft.UnmatchedNames = append(draft.UnmatchedNames, name)
+		}
+	}
+	if len(draft.Filters.People) > 0 {
+		draft.Filters.PeopleFilterType = "specific"
+	}
+
+	// Unmatched names stay in the key terms - "Touhy" or "Knoxville" may be the subject
+	draft.KeyTerms = keyTerms(description, matched)
+	draft.Filters.Keywords = draft.KeyTerms
+
+	return draft
+}
+
+// parseDateRanges finds explicit ranges first, then whole-year spans
+func parseDateRanges(text string, now time.Time) []DateRangeMatch {
+	var ranges []DateRangeMatch
+
+	for _, m := range rangeRegex.FindAllStringSubmatch(text, -1) {
+		startExpr := m[1]
+		if startExpr == "" {
+			startExpr = m[2]
+		}
+		start, _, ok := parseDateExpression(startExpr)
+		if !ok {
+			continue
+		}
+		var end time.Time
+		switch strings.ToLower(strings.Join(strings.Fields(m[3]), " ")) {
+		case "the present", "present", "today", "date":
+			end = now
+		default:
+			_, e, ok := parseDateExpression(m[3])
+			if !ok {
+				continue
+			}
+			end = e
+		}
+		if end.Before(start) {
+			continue
+		}
+		ranges = append(ranges, DateRangeMatch{Text: strings.TrimSpace(m[0]), Start: start, End: end})
+	}
+	if len(ranges) > 0 {
+		return ranges
+	}
+
+	for _, m := range yearSpanRegex.FindAllStringSubmatch(text, -1) {
+		first, last := 0, 0
+		for _, y := range yearRegex.FindAllString(m[2], -1) {
+			year, _ := strconv.Atoi(y)
+			if first == 0 || year < first {
+				first = year
+			}
+			if year > last {
+				last = year
+			}
+		}
+		fiscal := strings.EqualFold(m[1], "fiscal")
+		ranges = append(ranges, yearRange(strings.TrimSpace(m[0]), first, last, fiscal))
+	}
+	if len(ranges) > 0 {
+		return ranges
+	}
+
+	for _, m := range inPeriodRegex.FindAllStringSubmatch(text, -1) {
+		if year, err := strconv.Atoi(m[1]); err == nil {
+			ranges = append(ranges, yearRange(strings.TrimSpace(m[0]), year, year, false))
+			continue
+		}
+		if start, end, ok := parseDateExpression(m[1]); ok {
+			ranges = append(ranges, DateRangeMatch{Text: strings.TrimSpace(m[0]), Start: start, End: end})
+		}
+	}
+	return ranges
+}
+
+// yearRange covers whole years first..last
+// Federal fiscal years run October 1 to September 30, so FY2022 starts in 2021
+func yearRange(text string, first, last int, fiscal bool) DateRangeMatch {
+	if fiscal {
+		return DateRangeMatch{
+			Text:  text,
+			Start: time.Date(first-1, 10, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(last, 9, 30, 23, 59, 59, 0, time.UTC),
+		}
+	}
+	return DateRangeMatch{
+		Text:  text,
+		Start: time.Date(first, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(last, 12, 31, 23, 59, 59, 0, time.UTC),
+	}
+}
+
+// parseDateExpression returns the first and last instant a date expression covers
+// "January 2020" covers the whole month; "January 1, 2020" covers that day
+func parseDateExpression(expr string) (time.Time, time.Time, bool) {
+	expr = strings.Join(strings.Fields(strings.ReplaceAll(expr, ".", "")), " ")
+
+	dayLayouts := []string{"January 2, 2006", "January 2 2006", "Jan 2, 2006", "Jan 2 2006", "1/2/2006"}
+	for _, layout := range dayLayouts {
+		if t, err := time.Parse(layout, normalizeMonth(expr)); err == nil {
+			return t, t.Add(24*time.Hour - time.Second), true
+		}
+	}
+
+	monthLayouts := []string{"January 2006", "Jan 2006"}
+	for _, layout := range monthLayouts {
+		if t, err := time.Parse(layout, normalizeMonth(expr)); err == nil {
+			return t, t.AddDate(0, 1, 0).Add(-time.Second), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// normalizeMonth title-cases the month and maps "Sept" to Go's "Sep"
+func normalizeMonth(expr string) string {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return expr
+	}
+	month := strings.ToUpper(fields[0][:1]) + strings.ToLower(fields[0][1:])
+	if month == "Sept" {
+		month = "Sep"
+	}
+	fields[0] = month
+	return strings.Join(fields, " ")
+}
+
+// candidateNames returns capitalized words that may be people, in order of appearance
+// ASSUMPTION: Requests name people by surname with a title ("supervisor Smith")
+// Sentence-initial words and month names are excluded
+func candidateNames(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, loc := range nameRegex.FindAllStringIndex(text, -1) {
+		word := strings.TrimSuffix(text[loc[0]:loc[1]], "'s")
+		// Acronyms (EEO, MSPB, GS) are never people
+		if nonNames[word] || seen[word] || word == strings.ToUpper(word) {
+			continue
+		}
+		if _, _, isMonth := parseDateExpression(word + " 2000"); isMonth {
+			continue
+		}
+		// Skip the first word of a sentence - it is capitalized regardless
+		prefix := strings.TrimRight(text[:loc[0]], " ")
+		if prefix == "" || strings.HasSuffix(prefix, ".") {
+			continue
+		}
+		seen[word] = true
+		names = append(names, word)
+	}
+	return names
+}
+
+// matchPerson finds the email address whose local part contains the name
+// "Cortelyou-Hamilton" matches "l.cortelyou-hamilton@..." and "cortelyouhamilton@..."
+func matchPerson(name string, people *PeopleList) string {
+	if people == nil {
+		return ""
+	}
+	key := strings.ToLower(name)
+	compactKey := strings.ReplaceAll(key, "-", "")
+
+	for _, email := range people.All {
+		local := strings.ToLower(strings.SplitN(email, "@", 2)[0])
+		parts := strings.FieldsFunc(local, func(r rune) bool {
+			return r == '.' || r == '_' || r == '+'
+		})
+		for _, p := range parts {
+			if p == key || strings.ReplaceAll(p, "-", "") == compactKey {
+				return email
+			}
+		}
+	}
+	return ""
+}
+
+// keyTerms returns the distinctive words of a request, in order of appearance
+// Matched people names and request boilerplate are dropped; at most eight terms are kept
+func keyTerms(text string, names []string) []string {
+	skip := make(map[string]bool)
+	for _, name := range names {
+		for _, t := range textmodel.Tokenize(name) {
+			skip[t] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range textmodel.Tokenize(text) {
+		if requestBoilerplate[t] || skip[t] || seen[t] {
+			continue
+		}
+		seen[t] = true
+		terms = append(terms, t)
+	}
+
+	if len(terms) > 8 {
+		terms = terms[:8]
+	}
+	return terms
+}
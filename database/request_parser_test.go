@@ -0,0 +1,129 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRequestDescriptionDateRanges(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		text       string
+		start, end time.Time
+	}{
+		{
+			"All emails from January 1, 2022 through March 2023.",
+			time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, 3, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"Memoranda sent between Jan. 2020 and 1/10/2024.",
+			time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 10, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"Documents dated Sept. 5, 2023 to the present.",
+			time.Date(2023, 9, 5, 0, 0, 0, 0, time.UTC),
+			now,
+		},
+		{
+			"Budget records for fiscal years 2022 and 2023.",
+			time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, 9, 30, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"Grievances filed in June 2024.",
+			time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 30, 23, 59, 59, 0, time.UTC),
+		},
+	}
+	for _, c := range cases {
+		draft := ParseRequestDescription(c.text, nil, now)
+		if draft.Filters.DateStart == nil || draft.Filters.DateEnd == nil {
+			t.Errorf("%q: no date range", c.text)
+			continue
+		}
+		if !draft.Filters.DateStart.Equal(c.start) || !draft.Filters.DateEnd.Equal(c.end) {
+			t.Errorf("%q: range %v - %v, want %v - %v", c.text,
+				*draft.Filters.DateStart, *draft.Filters.DateEnd, c.start, c.end)
+		}
+	}
+}
+
+func TestParseRequestDescriptionEnvelopesRanges(t *testing.T) {
+	text := "Emails from March 2021 through May 2021 and from January 2023 through February 2023."
+	draft := ParseRequestDescription(text, nil, time.Now())
+	if len(draft.DateRanges) != 2 {
+		t.Fatalf("got %d ranges, want 2: %+v", len(draft.DateRanges), draft.DateRanges)
+	}
+	if !draft.Filters.DateStart.Equal(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)) ||
+		!draft.Filters.DateEnd.Equal(time.Date(2023, 2, 28, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("envelope %v - %v", *draft.Filters.DateStart, *draft.Filters.DateEnd)
+	}
+}
+
+func TestParseRequestDescriptionSingleDatesAreNotRanges(t *testing.T) {
+	draft := ParseRequestDescription("Notes of the meeting on March 3, 2022 and April 7, 2022.", nil, time.Now())
+	if draft.Filters.DateStart != nil || len(draft.DateRanges) != 0 {
+		t.Errorf("listed dates read as a range: %+v", draft.DateRanges)
+	}
+	if len(draft.MentionedDates) != 2 {
+		t.Errorf("got %d mentioned dates, want 2", len(draft.MentionedDates))
+	}
+}
+
+func TestParseRequestDescriptionPeople(t *testing.T) {
+	people := &PeopleList{All: []string{"l.cortelyou-hamilton@agency.gov", "jsmith@agency.gov"}}
+	text := "Emails between supervisor Cortelyou-Hamilton and counsel Touhy regarding telework."
+	draft := ParseRequestDescription(text, people, time.Now())
+
+	if len(draft.People) != 1 || draft.People[0].Email != "l.cortelyou-hamilton@agency.gov" {
+		t.Errorf("people %+v, want Cortelyou-Hamilton matched", draft.People)
+	}
+	if draft.Filters.PeopleFilterType != "specific" || len(draft.Filters.People) != 1 {
+		t.Errorf("people filter %q %v", draft.Filters.PeopleFilterType, draft.Filters.People)
+	}
+	if len(draft.UnmatchedNames) != 1 || draft.UnmatchedNames[0] != "Touhy" {
+		t.Errorf("unmatched names %v, want [Touhy]", draft.UnmatchedNames)
+	}
+
+	terms := make(map[string]bool)
+	for _, term := range draft.KeyTerms {
+		terms[term] = true
+	}
+	if !terms["touhy"] || !terms["telework"] {
+		t.Errorf("key terms %v missing touhy or telework", draft.KeyTerms)
+	}
+	if terms["cortelyou"] || terms["hamilton"] || terms["emails"] || terms["regarding"] {
+		t.Errorf("key terms %v kept a matched name or boilerplate", draft.KeyTerms)
+	}
+}
+
+func TestDraftFiltersUsesRequestDescription(t *testing.T) {
+	d := newTestDB(t)
+	_, err := d.CreateProductionRequest(ProductionRequest{
+		ID:          "SUPP-A",
+		Description: "All emails sent by Smith in calendar years 2023 and 2024 regarding the budget.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	draft, err := d.DraftFilters("SUPP-A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.Filters.ProductionRequestID != "SUPP-A" {
+		t.Errorf("draft scoped to %q", draft.Filters.ProductionRequestID)
+	}
+	if draft.Filters.DateStart == nil || draft.Filters.DateStart.Year() != 2023 || draft.Filters.DateEnd.Year() != 2024 {
+		t.Errorf("date range %v - %v, want calendar 2023-2024", draft.Filters.DateStart, draft.Filters.DateEnd)
+	}
+	if len(draft.People) != 1 || draft.People[0].Email != "jane.smith@company.com" {
+		t.Errorf("people %+v, want Smith matched to a seeded address", draft.People)
+	}
+
+	if _, err := d.DraftFilters("PR-999"); err == nil {
+		t.Error("drafted filters for an unknown request")
+	}
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Review decisions a reviewer can record for a file and production request
+const (
+	DecisionResponsive    = "responsive"
+	DecisionNonResponsive = "non_responsive"
+	DecisionPrivileged    = "privileged"
+)
+
+// Review statuses accepted by FileFilters.ReviewStatus besides the decisions
+const (
+	ReviewStatusUnreviewed = "unreviewed"
+	ReviewStatusReviewed   = "reviewed"
+)
+
+// ReviewDecision is a reviewer's call on one file for one production request
+type ReviewDecision struct {
+	FileID              int64     `json:"file_id"`
+	ProductionRequestID string    `json:"production_request_id"`
+	Decision            string    `json:"decision"`
+	Reviewer            string    `json:"reviewer"`
+	Note                string    `json:"note"`
+	DecidedAt           time.Time `json:"decided_at"`
+}
+
+// ReviewSummary counts decisions within a filtered set of files
+type ReviewSummary struct {
+	ProductionRequestID string         `json:"production_request_id"`
+	Total               int            `json:"total"`
+	Unreviewed          int            `json:"unreviewed"`
+	Decisions           map[string]int `json:"decisions"` // Decision -> count
+	PercentComplete     float64        `json:"percent_complete"`
+}
+
+// ReviewStatusOptions returns the values accepted by FileFilters.ReviewStatus
+func ReviewStatusOptions() []string {
+	return []string{"all", ReviewStatusUnreviewed, ReviewStatusReviewed,
+		DecisionResponsive, DecisionNonResponsive, DecisionPrivileged}
+}
+
+// isValidReviewDecision reports whether decision is a recordable decision
+func isValidReviewDecision(decision string) bool {
+	switch decision {
+	case DecisionResponsive, DecisionNonResponsive, DecisionPrivileged:
+		return true
+	}
+	return false
+}
+
+// RecordReviewDecisions records the same decision for several files
+// A file's earlier decision for the same request is replaced. The relevance model is not
+// retrained here; see RefreshRelevanceModel
+func (d *DB) RecordReviewDecisions(fileIDs []int64, requestID, decision, reviewer, note string) error {
+	op := logging.StartOperation("RecordReviewDecisions", map[string]interface{}{
+		"file_count":            len(fileIDs),
+		"production_request_id": requestID,
+		"decision":              decision,
+		"reviewer":              reviewer,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to record review decisions")
+
+	if !isValidReviewDecision(decision) {
+		return fmt.Errorf("unknown review decision %q", decision)
+	}
+	// ASSUMPTION: Every decision is attributable to a reviewer
+	if strings.TrimSpace(reviewer) == "" {
+		return fmt.Errorf("reviewer is required")
+	}
+	if _, err := d.GetProductionRequest(requestID); err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// ASSUMPTION: Decisions are only recorded for files in the index
+	var unknown []string
+	for _, fileID := range fileIDs {
+		var n int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM files WHERE id = ?", fileID).Scan(&n); err != nil {
+			return fmt.Errorf("failed to look up file %d: %w", fileID, err)
+		}
+		if n == 0 {
+			unknown = append(unknown, strconv.FormatInt(fileID, 10))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown file IDs: %s", strings.Join(unknown, ", "))
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO review_decisions (file_id, production_request_id, decision, reviewer, note, decided_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(file_id, production_request_id) DO UPDATE SET
+			decision = excluded.decision,
+			reviewer = excluded.reviewer,
+			note = excluded.note,
+			decided_at = excluded.decided_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare review decision: %w", err)
+	}
+	defer stmt.Close()
+
+	decidedAt := time.Now().UTC().Format(time.RFC3339)
+	for _, fileID := range fileIDs {
+		if _, err := stmt.Exec(fileID, requestID, decision, strings.TrimSpace(reviewer), note, decidedAt); err != nil {
+			return fmt.Errorf("failed to record review decision for file %d: %w", fileID, err)
+		}
+		err := appendAudit(tx, AuditReview, fileID, 0, strings.TrimSpace(reviewer), map[string]interface{}{
+			"production_request_id": requestID,
+			"decision":              decision,
+			"note":                  note,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit review decisions: %w", err)
+	}
+	return nil
+}
+
+// RecordReviewDecision records a decision for one file
+func (d *DB) RecordReviewDecision(fileID int64, requestID, decision, reviewer, note string) error {
+	return d.RecordReviewDecisions([]int64{fileID}, requestID, decision, reviewer, note)
+}
+
+// ClearReviewDecision returns a file to unreviewed for a production request
+// reviewer is who cleared it, recorded in the audit log like the decision itself
+func (d *DB) ClearReviewDecision(fileID int64, requestID, reviewer string) error {
+	// ASSUMPTION: Every change to a decision is attributable to a reviewer
+	reviewer = strings.TrimSpace(reviewer)
+	if reviewer == "" {
+		return fmt.Errorf("reviewer is required")
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM review_decisions WHERE file_id = ? AND production_request_id = ?", fileID, requestID)
+	if err != nil {
+		return fmt.Errorf("failed to clear review decision: %w", err)
+	}
+	if cleared, _ := result.RowsAffected(); cleared > 0 {
+		err := appendAudit(tx, AuditReview, fileID, 0, reviewer, map[string]interface{}{
+			"production_request_id": requestID,
+			"decision":              "cleared",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetReviewDecision returns a file's decision for a request, or nil if unreviewed
+func (d *DB) GetReviewDecision(fileID int64, requestID string) (*ReviewDecision, error) {
+	var rd ReviewDecision
+	var decidedAt string
+	err := d.db.QueryRow(`
+		SELECT file_id, production_request_id, decision, reviewer, note, decided_at
+		FROM review_decisions
+		WHERE file_id = ? AND production_request_id = ?
+	`, fileID, requestID).Scan(&rd.FileID, &rd.ProductionRequestID, &rd.Decision, &rd.Reviewer, &rd.Note, &decidedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review decision: %w", err)
+	}
+	rd.DecidedAt = parseTimestamp(decidedAt)
+	return &rd, nil
+}
+
+// GetReviewSummary counts review decisions among the files matching filters
+// filters.ProductionRequestID selects whose decisions are counted;
+// filters.ReviewStatus is ignored so the summary always covers every status
+func (d *DB) GetReviewSummary(filters FileFilters) (*ReviewSummary, error) {
+	if filters.ProductionRequestID == "" {
+		return nil, fmt.Errorf("review summary requires a production request")
+	}
+	filters.ReviewStatus = ""
+
+	whereClause, args, err := fileWhereClause(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT COALESCE(rd.decision, ''), COUNT(*)
+		FROM files
+		LEFT JOIN review_decisions rd ON rd.file_id = files.id AND rd.production_request_id = ?
+		WHERE ` + whereClause + `
+		GROUP BY rd.decision
+	`
+	rows, err := d.db.Query(query, append([]interface{}{filters.ProductionRequestID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize review decisions: %w", err)
+	}
+	defer rows.Close()
+
+	summary := &ReviewSummary{
+		ProductionRequestID: filters.ProductionRequestID,
+		Decisions:           make(map[string]int),
+	}
+	for rows.Next() {
+		var decision string
+		var count int
+		if err := rows.Scan(&decision, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan review summary: %w", err)
+		}
+		summary.Total += count
+		if decision == "" {
+			summary.Unreviewed = count
+		} else {
+			summary.Decisions[decision] = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating review summary: %w", err)
+	}
+
+	if summary.Total > 0 {
+		summary.PercentComplete = 100 * float64(summary.Total-summary.Unreviewed) / float64(summary.Total)
+	}
+	return summary, nil
+}
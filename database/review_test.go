@@ -0,0 +1,148 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordReviewDecisionsValidates(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	if err := d.RecordReviewDecisions(ids, "PR-001", "maybe", "reviewer", ""); err == nil {
+		t.Error("recorded an unknown decision")
+	}
+	if err := d.RecordReviewDecisions(ids, "PR-001", DecisionResponsive, "  ", ""); err == nil {
+		t.Error("recorded a decision without a reviewer")
+	}
+	if err := d.RecordReviewDecisions(ids, "PR-999", DecisionResponsive, "reviewer", ""); err == nil {
+		t.Error("recorded a decision for an unknown request")
+	}
+	// One unknown file rejects the whole call
+	err := d.RecordReviewDecisions([]int64{ids[0], 999999}, "PR-001", DecisionResponsive, "reviewer", "")
+	if err == nil || !strings.Contains(err.Error(), "999999") {
+		t.Errorf("got %v, want an unknown file error", err)
+	}
+	if rd, _ := d.GetReviewDecision(ids[0], "PR-001"); rd != nil {
+		t.Errorf("rejected calls left decision %+v", rd)
+	}
+}
+
+func TestRecordReviewDecisionsReplacesEarlierDecision(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	if err := d.RecordReviewDecision(ids[0], "PR-001", DecisionResponsive, "first", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RecordReviewDecision(ids[0], "PR-001", DecisionPrivileged, " second ", "attorney advice"); err != nil {
+		t.Fatal(err)
+	}
+	// Another request's decision is separate
+	if err := d.RecordReviewDecision(ids[0], "PR-002", DecisionNonResponsive, "first", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := d.GetReviewDecision(ids[0], "PR-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rd == nil || rd.Decision != DecisionPrivileged || rd.Reviewer != "second" || rd.Note != "attorney advice" {
+		t.Errorf("decision %+v, want the second reviewer's privileged call", rd)
+	}
+	if rd, _ := d.GetReviewDecision(ids[0], "PR-002"); rd == nil || rd.Decision != DecisionNonResponsive {
+		t.Errorf("PR-002 decision %+v", rd)
+	}
+
+	// Both PR-001 decisions stay in the audit log
+	entries, err := d.queryAuditEntries("action = ? AND file_id = ?", AuditReview, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("got %d review audit entries, want 3", len(entries))
+	}
+}
+
+func TestGetReviewSummary(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 4)
+	mustExec(t, d, "UPDATE files SET category = 'review-test' WHERE id IN (?, ?, ?, ?)", ids[0], ids[1], ids[2], ids[3])
+	if err := d.RecordReviewDecisions(ids[:2], "PR-001", DecisionResponsive, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RecordReviewDecision(ids[2], "PR-001", DecisionNonResponsive, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Decisions for other requests don't count
+	if err := d.RecordReviewDecision(ids[3], "PR-002", DecisionResponsive, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	filters := FileFilters{
+		ProductionRequestID: "PR-001",
+		Categories:          []string{"review-test"},
+		ReviewStatus:        ReviewStatusUnreviewed, // Ignored by the summary
+	}
+	summary, err := d.GetReviewSummary(filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{DecisionResponsive: 2, DecisionNonResponsive: 1}
+	if summary.Total != 4 || summary.Unreviewed != 1 || !reflect.DeepEqual(summary.Decisions, want) {
+		t.Errorf("summary %+v", summary)
+	}
+	if summary.PercentComplete != 75 {
+		t.Errorf("percent complete %v, want 75", summary.PercentComplete)
+	}
+
+	if _, err := d.GetReviewSummary(FileFilters{}); err == nil {
+		t.Error("summarized without a production request")
+	}
+}
+
+func TestReviewStatusFilter(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 3)
+	mustExec(t, d, "UPDATE files SET category = 'review-test' WHERE id IN (?, ?, ?)", ids[0], ids[1], ids[2])
+	if err := d.RecordReviewDecision(ids[0], "PR-001", DecisionResponsive, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RecordReviewDecision(ids[1], "PR-001", DecisionPrivileged, "reviewer", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]int64{
+		"all":                  ids,
+		ReviewStatusUnreviewed: {ids[2]},
+		ReviewStatusReviewed:   {ids[0], ids[1]},
+		DecisionPrivileged:     {ids[1]},
+	}
+	for status, want := range cases {
+		got, err := d.SearchFileIDs(FileFilters{
+			ProductionRequestID: "PR-001",
+			Categories:          []string{"review-test"},
+			ReviewStatus:        status,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", status, err)
+		}
+		seen := make(map[int64]bool)
+		for _, id := range got {
+			seen[id] = true
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", status, got, want)
+			continue
+		}
+		for _, id := range want {
+			if !seen[id] {
+				t.Errorf("%s: got %v, want %v", status, got, want)
+				break
+			}
+		}
+	}
+
+	if _, err := d.SearchFileIDs(FileFilters{ProductionRequestID: "PR-001", ReviewStatus: "maybe"}); err == nil {
+		t.Error("searched with an unknown review status")
+	}
+}
@@ -0,0 +1,410 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// SavedSearch is a named filter set linked to a production request
+// Saving new filters under the same name adds a version rather than overwriting
+type SavedSearch struct {
+	ID                  int64       `json:"id"`
+	Name                string      `json:"name"`
+	ProductionRequestID string      `json:"production_request_id"`
+	Version             int         `json:"version"` // Latest version number
+	Filters             FileFilters `json:"filters"` // Filters of the latest version
+	CreatedAt           time.Time   `json:"created_at"`
+	LastRun             *SearchRun  `json:"last_run"`
+}
+
+// SavedSearchVersion is one recorded filter set of a saved search
+type SavedSearchVersion struct {
+	Version   int         `json:"version"`
+	Filters   FileFilters `json:"filters"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// SearchRun records one execution of a saved search
+type SearchRun struct {
+	ID         int64     `json:"id"`
+	SearchID   int64     `json:"search_id"`
+	Version    int       `json:"version"`
+	RunAt      time.Time `json:"run_at"`
+	TotalCount int       `json:"total_count"`
+}
+
+// SearchDiff compares a run's result set with the previous run of the same search
+// On the first run there is nothing to compare, so Added and Removed are empty
+type SearchDiff struct {
+	Run           SearchRun `json:"run"`
+	PreviousRunID int64     `json:"previous_run_id"` // 0 on the first run
+	Added         []int64   `json:"added"`           // In this run, not the previous one
+	Removed       []int64   `json:"removed"`         // In the previous run, not this one, by file ID
+	Unchanged     int       `json:"unchanged"`
+}
+
+// encodeSearchFilters serializes filters for storage without pagination
+// Page and PageSize describe a view, not the search
+func encodeSearchFilters(filters FileFilters) (string, error) {
+	filters.Page = 0
+	filters.PageSize = 0
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode filters: %w", err)
+	}
+	return string(data), nil
+}
+
+// SaveSearch stores filters under a name for the filters' production request
+// An existing search with the same name gets a new version if the filters changed
+func (d *DB) SaveSearch(name string, filters FileFilters) (*SavedSearch, error) {
+	op := logging.StartOperation("SaveSearch", map[string]interface{}{
+		"name":                  name,
+		"production_request_id": filters.ProductionRequestID,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to save searches")
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("saved search name is required")
+	}
+	// ASSUMPTION: Every saved search answers a production request
+	if filters.ProductionRequestID == "" {
+		return nil, fmt.Errorf("saved search %q needs a production request", name)
+	}
+	if _, err := d.GetProductionRequest(filters.ProductionRequestID); err != nil {
+		return nil, err
+	}
+
+	filtersJSON, err := encodeSearchFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var searchID int64
+	err = tx.QueryRow(`
+		SELECT id FROM saved_searches WHERE name = ? AND production_request_id = ?
+	`, name, filters.ProductionRequestID).Scan(&searchID)
+	switch {
+	case err == sql.ErrNoRows:
+		result, err := tx.Exec(`
+			INSERT INTO saved_searches (name, production_request_id, created_at) VALUES (?, ?, ?)
+		`, name, filters.ProductionRequestID, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create saved search: %w", err)
+		}
+		if searchID, err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to get saved search id: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to look up saved search: %w", err)
+	}
+
+	var latestVersion int
+	var latestFilters sql.NullString
+	err = tx.QueryRow(`
+		SELECT version, filters FROM saved_search_versions
+		WHERE search_id = ? ORDER BY version DESC LIMIT 1
+	`, searchID).Scan(&latestVersion, &latestFilters)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get latest version: %w", err)
+	}
+
+	// Unchanged filters don't make a new version
+	if !latestFilters.Valid || latestFilters.String != filtersJSON {
+		_, err = tx.Exec(`
+			INSERT INTO saved_search_versions (search_id, version, filters, created_at) VALUES (?, ?, ?, ?)
+		`, searchID, latestVersion+1, filtersJSON, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save search version: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit saved search: %w", err)
+	}
+
+	return d.GetSavedSearch(searchID)
+}
+
+// GetSavedSearch returns a saved search with its latest version and last run
+func (d *DB) GetSavedSearch(id int64) (*SavedSearch, error) {
+	searches, err := d.querySavedSearches("s.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(searches) == 0 {
+		return nil, fmt.Errorf("saved search %d not found", id)
+	}
+	return &searches[0], nil
+}
+
+// GetSavedSearches returns the saved searches of a production request
+// An empty requestID returns the saved searches of all requests
+func (d *DB) GetSavedSearches(requestID string) ([]SavedSearch, error) {
+	if requestID == "" {
+		return d.querySavedSearches("1=1")
+	}
+	return d.querySavedSearches("s.production_request_id = ?", requestID)
+}
+
+// querySavedSearches loads saved searches matching a condition on alias s
+func (d *DB) querySavedSearches(condition string, args ...interface{}) ([]SavedSearch, error) {
+	rows, err := d.db.Query(`
+		SELECT s.id, s.name, s.production_request_id, s.created_at, v.version, v.filters,
+		       r.id, r.version, r.run_at, r.total_count
+		FROM saved_searches s
+		JOIN saved_search_versions v ON v.search_id = s.id
+			AND v.version = (SELECT MAX(version) FROM saved_search_versions WHERE search_id = s.id)
+		LEFT JOIN saved_search_runs r ON r.search_id = s.id
+			AND r.id = (SELECT MAX(id) FROM saved_search_runs WHERE search_id = s.id)
+		WHERE `+condition+`
+		ORDER BY s.production_request_id, s.name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []SavedSearch
+	for rows.Next() {
+		var s SavedSearch
+		var createdAt, filtersJSON string
+		var runID sql.NullInt64
+		var runVersion, runCount sql.NullInt64
+		var runAt sql.NullString
+		if err := rows.Scan(&s.ID, &s.Name, &s.ProductionRequestID, &createdAt, &s.Version, &filtersJSON,
+			&runID, &runVersion, &runAt, &runCount); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %w", err)
+		}
+		s.CreatedAt = parseTimestamp(createdAt)
+		if err := json.Unmarshal([]byte(filtersJSON), &s.Filters); err != nil {
+			return nil, fmt.Errorf("failed to decode saved search filters: %w", err)
+		}
+		if runID.Valid {
+			s.LastRun = &SearchRun{
+				ID:         runID.Int64,
+				SearchID:   s.ID,
+				Version:    int(runVersion.Int64),
+				RunAt:      parseTimestamp(runAt.String),
+				TotalCount: int(runCount.Int64),
+			}
+		}
+		searches = append(searches, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating saved searches: %w", err)
+	}
+	return searches, nil
+}
+
+// GetSavedSearchVersions returns every version of a saved search, oldest first
+func (d *DB) GetSavedSearchVersions(searchID int64) ([]SavedSearchVersion, error) {
+	rows, err := d.db.Query(`
+		SELECT version, filters, created_at FROM saved_search_versions
+		WHERE search_id = ? ORDER BY version
+	`, searchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved search versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []SavedSearchVersion
+	for rows.Next() {
+		var v SavedSearchVersion
+		var filtersJSON, createdAt string
+		if err := rows.Scan(&v.Version, &filtersJSON, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search version: %w", err)
+		}
+		if err := json.Unmarshal([]byte(filtersJSON), &v.Filters); err != nil {
+			return nil, fmt.Errorf("failed to decode saved search filters: %w", err)
+		}
+		v.CreatedAt = parseTimestamp(createdAt)
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating saved search versions: %w", err)
+	}
+	return versions, nil
+}
+
+// RunSavedSearch executes the latest version of a saved search, records the
+// full result set and diffs it against the previous run
+func (d *DB) RunSavedSearch(searchID int64) (*SearchDiff, error) {
+	op := logging.StartOperation("RunSavedSearch", map[string]interface{}{
+		"search_id": searchID,
+	})
+	defer op.EndOperation()
+
+	search, err := d.GetSavedSearch(searchID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := d.SearchFileIDs(search.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous map[int64]bool
+	var previousIDs []int64
+	var previousRunID int64
+	if search.LastRun != nil {
+		previousRunID = search.LastRun.ID
+		var err error
+		previousIDs, err = d.GetSearchRunFileIDs(previousRunID)
+		if err != nil {
+			return nil, err
+		}
+		previous = make(map[int64]bool, len(previousIDs))
+		for _, id := range previousIDs {
+			previous[id] = true
+		}
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	runAt := time.Now().UTC()
+	result, err := tx.Exec(`
+		INSERT INTO saved_search_runs (search_id, version, run_at, total_count) VALUES (?, ?, ?, ?)
+	`, searchID, search.Version, runAt.Format(time.RFC3339), len(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record search run: %w", err)
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search run id: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO saved_search_run_files (run_id, file_id) VALUES (?, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare run file insert: %w", err)
+	}
+	defer stmt.Close()
+
+	diff := &SearchDiff{
+		Run: SearchRun{
+			ID:         runID,
+			SearchID:   searchID,
+			Version:    search.Version,
+			RunAt:      runAt,
+			TotalCount: len(ids),
+		},
+		PreviousRunID: previousRunID,
+		Added:         []int64{},
+		Removed:       []int64{},
+	}
+
+	current := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		current[id] = true
+		if _, err := stmt.Exec(runID, id); err != nil {
+			return nil, fmt.Errorf("failed to record run file: %w", err)
+		}
+		if previous != nil && previous[id] {
+			diff.Unchanged++
+		} else if previous != nil {
+			diff.Added = append(diff.Added, id)
+		}
+	}
+	// Walk the previous run's ID list rather than the map so Removed is in file ID order
+	for _, id := range previousIDs {
+		if !current[id] {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit search run: %w", err)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"run_id":    runID,
+		"version":   search.Version,
+		"total":     len(ids),
+		"added":     len(diff.Added),
+		"removed":   len(diff.Removed),
+		"unchanged": diff.Unchanged,
+	})
+	return diff, nil
+}
+
+// GetSearchRunFileIDs returns the file IDs recorded for a search run
+func (d *DB) GetSearchRunFileIDs(runID int64) ([]int64, error) {
+	rows, err := d.db.Query("SELECT file_id FROM saved_search_run_files WHERE run_id = ? ORDER BY file_id", runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run files: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan run file: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating run files: %w", err)
+	}
+	return ids, nil
+}
+
+// DeleteSavedSearch removes a saved search with its versions and run history
+// A search that review batches were cut from is refused rather than orphaning them
+func (d *DB) DeleteSavedSearch(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Delete first so the transaction holds the write lock while batches are counted
+	result, err := tx.Exec("DELETE FROM saved_searches WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("saved search %d not found", id)
+	}
+	var batches int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM review_batches WHERE saved_search_id = ?", id).Scan(&batches); err != nil {
+		return fmt.Errorf("failed to count review batches: %w", err)
+	}
+	if batches > 0 {
+		return fmt.Errorf("saved search %d has %d review batches; delete them first", id, batches)
+	}
+
+	statements := []string{
+		"DELETE FROM saved_search_run_files WHERE run_id IN (SELECT id FROM saved_search_runs WHERE search_id = ?)",
+		"DELETE FROM saved_search_runs WHERE search_id = ?",
+		"DELETE FROM saved_search_versions WHERE search_id = ?",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return fmt.Errorf("failed to delete saved search history: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSaveSearchVersionsOnlyChangedFilters(t *testing.T) {
+	d := newTestDB(t)
+	filters := FileFilters{ProductionRequestID: "PR-001", Categories: []string{"email"}}
+
+	first, err := d.SaveSearch("Emails", filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pagination describes a view, so it doesn't make a new version
+	filters.Page, filters.PageSize = 3, 25
+	same, err := d.SaveSearch(" Emails ", filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.ID != first.ID || same.Version != 1 {
+		t.Errorf("resaving unchanged filters gave search %d version %d", same.ID, same.Version)
+	}
+
+	filters.Categories = []string{"email", "claim"}
+	changed, err := d.SaveSearch("Emails", filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.ID != first.ID || changed.Version != 2 {
+		t.Errorf("changed filters gave search %d version %d, want %d version 2", changed.ID, changed.Version, first.ID)
+	}
+
+	versions, err := d.GetSavedSearchVersions(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions))
+	}
+	if !reflect.DeepEqual(versions[0].Filters.Categories, []string{"email"}) ||
+		!reflect.DeepEqual(versions[1].Filters.Categories, []string{"email", "claim"}) {
+		t.Errorf("versions kept categories %v and %v", versions[0].Filters.Categories, versions[1].Filters.Categories)
+	}
+	if versions[0].Filters.Page != 0 || versions[0].Filters.PageSize != 0 {
+		t.Error("pagination stored with the search")
+	}
+}
+
+func TestSaveSearchRequiresNameAndRequest(t *testing.T) {
+	d := newTestDB(t)
+	if _, err := d.SaveSearch(" ", FileFilters{ProductionRequestID: "PR-001"}); err == nil {
+		t.Error("saved a search without a name")
+	}
+	if _, err := d.SaveSearch("Unscoped", FileFilters{}); err == nil {
+		t.Error("saved a search without a production request")
+	}
+	if _, err := d.SaveSearch("Unknown", FileFilters{ProductionRequestID: "PR-999"}); err == nil {
+		t.Error("saved a search for an unknown production request")
+	}
+}
+
+func TestRunSavedSearchDiffsAgainstPreviousRun(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 3)
+	mustExec(t, d, "UPDATE files SET extracted_text = 'bluebird memo' WHERE id IN (?, ?)", ids[0], ids[1])
+	search, err := d.SaveSearch("Bluebird", FileFilters{ProductionRequestID: "PR-001", Keywords: []string{"bluebird"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := d.RunSavedSearch(search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.PreviousRunID != 0 || first.Run.TotalCount != 2 || len(first.Added) != 0 || len(first.Removed) != 0 {
+		t.Errorf("first run %+v, want 2 files and nothing to compare", first)
+	}
+
+	mustExec(t, d, "UPDATE files SET extracted_text = NULL WHERE id = ?", ids[1])
+	mustExec(t, d, "UPDATE files SET extracted_text = 'bluebird memo' WHERE id = ?", ids[2])
+
+	second, err := d.RunSavedSearch(search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.PreviousRunID != first.Run.ID {
+		t.Errorf("second run compared with run %d, want %d", second.PreviousRunID, first.Run.ID)
+	}
+	if !reflect.DeepEqual(second.Added, []int64{ids[2]}) || !reflect.DeepEqual(second.Removed, []int64{ids[1]}) ||
+		second.Unchanged != 1 {
+		t.Errorf("diff added %v removed %v unchanged %d", second.Added, second.Removed, second.Unchanged)
+	}
+
+	// The first run's result set is kept as it was
+	recorded, err := d.GetSearchRunFileIDs(first.Run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorded, ids[:2]) {
+		t.Errorf("first run recorded %v, want %v", recorded, ids[:2])
+	}
+
+	saved, err := d.GetSavedSearch(search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.LastRun == nil || saved.LastRun.ID != second.Run.ID || saved.LastRun.Version != 1 {
+		t.Errorf("last run %+v, want run %d of version 1", saved.LastRun, second.Run.ID)
+	}
+}
+
+func TestRunSavedSearchListsRemovedFilesInOrder(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 10)
+	for _, id := range ids {
+		mustExec(t, d, "UPDATE files SET extracted_text = 'bluebird memo' WHERE id = ?", id)
+	}
+	search, err := d.SaveSearch("Bluebird", FileFilters{ProductionRequestID: "PR-001", Keywords: []string{"bluebird"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.RunSavedSearch(search.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids[1:9] {
+		mustExec(t, d, "UPDATE files SET extracted_text = NULL WHERE id = ?", id)
+	}
+
+	diff, err := d.RunSavedSearch(search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff.Removed, ids[1:9]) {
+		t.Errorf("removed %v, want %v", diff.Removed, ids[1:9])
+	}
+}
+
+func TestDeleteSavedSearchRemovesHistory(t *testing.T) {
+	d := newTestDB(t)
+	search, err := d.SaveSearch("Emails", FileFilters{ProductionRequestID: "PR-001", Categories: []string{"email"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := d.RunSavedSearch(search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.DeleteSavedSearch(search.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetSavedSearch(search.ID); err == nil {
+		t.Error("deleted search still found")
+	}
+	if ids, _ := d.GetSearchRunFileIDs(run.Run.ID); len(ids) != 0 {
+		t.Errorf("deleted search left %d run files", len(ids))
+	}
+	if err := d.DeleteSavedSearch(search.ID); err == nil {
+		t.Error("deleting a missing search succeeded")
+	}
+}
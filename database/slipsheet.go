@@ -0,0 +1,245 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"signal-from-noise/logging"
+)
+
+// Slip sheet wording, shown in place of a document that is not produced
+const (
+	SlipSheetPrivileged = "Document Withheld – Privileged"
+	SlipSheetTechnical  = "Document Withheld – Technical Issue"
+	SlipSheetRedacted   = "Native Withheld – Produced as Redacted Text"
+)
+
+// SlipSheetPDF renders a one-page US Letter placeholder reading reason,
+// endorsed with its Bates number in the bottom right corner
+// Written by hand so productions need no PDF library; it has no timestamps,
+// so the same Bates number and reason always give the same bytes
+func SlipSheetPDF(bates, reason string) []byte {
+	content := fmt.Sprintf("BT /F1 24 Tf 72 560 Td (%s) Tj ET\nBT /F1 12 Tf 460 36 Td (%s) Tj ET\n",
+		pdfString(reason), pdfString(bates))
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	// Cross-reference entries are exactly 20 bytes, hence the trailing space before \n
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+// pdfString escapes text for a PDF literal string in WinAnsi encoding
+// The en dash has a WinAnsi code; other characters outside ASCII become '?'
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '–':
+			b.WriteString(`\226`)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// withheldDocuments decides which files in a production are replaced by slip sheets
+// Privileged files are withheld, as are files logged as withheld with their family;
+// files logged for redaction are produced redacted once they have redactions. With
+// withholdFamilies, every family member of a withheld file in the production is withheld
+// along with it. Redacted files other than PDFs cannot be redacted in their native form,
+// so they go out as a slip sheet with redacted text. Returns the reason by file ID
+func (d *DB) withheldDocuments(files []File, withholdFamilies bool, redactions map[int64][]Redaction) (map[int64]string, error) {
+	treatments := make(map[int64]string)
+	rows, err := d.db.Query("SELECT file_id, treatment FROM privilege_entries")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query privilege entries: %w", err)
+	}
+	for rows.Next() {
+		var fileID int64
+		var treatment string
+		if err := rows.Scan(&fileID, &treatment); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan privilege entry: %w", err)
+		}
+		treatments[fileID] = treatment
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating privilege entries: %w", err)
+	}
+
+	withheld := make(map[int64]string)
+	for _, file := range files {
+		switch treatments[file.ID] {
+		case TreatmentWithheld, TreatmentFamilyWithheld:
+			withheld[file.ID] = SlipSheetPrivileged
+		case TreatmentRedacted:
+			if len(redactions[file.ID]) == 0 {
+				withheld[file.ID] = SlipSheetPrivileged
+			}
+		default:
+			if file.Privileged {
+				withheld[file.ID] = SlipSheetPrivileged
+			}
+		}
+	}
+	if withholdFamilies && len(withheld) > 0 {
+		families, err := d.fileFamilies()
+		if err != nil {
+			return nil, err
+		}
+		withheldFamilies := make(map[string]bool)
+		for id := range withheld {
+			if family := families[id]; family != "" {
+				withheldFamilies[family] = true
+			}
+		}
+		for _, file := range files {
+			if withheldFamilies[families[file.ID]] {
+				withheld[file.ID] = SlipSheetPrivileged
+			}
+		}
+	}
+
+	for _, file := range files {
+		if withheld[file.ID] == "" && len(redactions[file.ID]) > 0 && !isPDFPath(file.Path) {
+			withheld[file.ID] = SlipSheetRedacted
+		}
+	}
+	return withheld, nil
+}
+
+// TechnicalIssue is a collected file that cannot be processed for production as it stands
+type TechnicalIssue struct {
+	FileID  int64  `json:"file_id"`
+	Path    string `json:"path"`
+	Problem string `json:"problem"` // e.g. "the PDF is encrypted"
+}
+
+// fileSignatures are the leading bytes of formats whose contents can be checked
+// against their extension: Office Open XML is a zip, legacy Office an OLE container
+var fileSignatures = map[string][]byte{
+	".pdf":  []byte("%PDF-"),
+	".docx": []byte("PK\x03\x04"),
+	".xlsx": []byte("PK\x03\x04"),
+	".pptx": []byte("PK\x03\x04"),
+	".doc":  {0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
+	".xls":  {0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
+	".ppt":  {0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
+	".msg":  {0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
+}
+
+// technicalMissing is the problem reported for a file absent from the data lake
+// A production reports these as missing rather than slip-sheeting them
+const technicalMissing = "the file is missing from the data lake"
+
+// pdfTailSize is how much of a PDF's end is read to find its trailer
+const pdfTailSize = 4096
+
+// DetectTechnicalIssues checks files in the data lake under sourceRoot for problems that
+// stop them being converted or imaged: missing or empty files, contents that do not
+// match the extension, and PDFs that are truncated or encrypted
+// Productions with TechnicalSlipSheets set slip-sheet these as SlipSheetTechnical
+func (d *DB) DetectTechnicalIssues(fileIDs []int64, sourceRoot string) ([]TechnicalIssue, error) {
+	op := logging.StartOperation("DetectTechnicalIssues", map[string]interface{}{
+		"file_count": len(fileIDs),
+	})
+	defer op.EndOperation()
+
+	files, err := d.GetFilesByIDs(uniqueIDs(fileIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+	issues := []TechnicalIssue{}
+	for _, file := range files {
+		problem, err := technicalProblem(sourcePath(sourceRoot, file.Path))
+		if err != nil {
+			return nil, err
+		}
+		if problem != "" {
+			issues = append(issues, TechnicalIssue{FileID: file.ID, Path: file.Path, Problem: problem})
+		}
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"issues": len(issues),
+	})
+	return issues, nil
+}
+
+// technicalProblem describes why the file at path cannot be processed, or returns ""
+// Only the head and, for PDFs, the tail are read
+func technicalProblem(path string) (string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return technicalMissing, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if info.Size() == 0 {
+		return "the file is empty", nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if signature := fileSignatures[ext]; signature != nil {
+		head := make([]byte, len(signature))
+		if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, signature) {
+			return fmt.Sprintf("the contents are not a %s file", strings.ToUpper(ext[1:])), nil
+		}
+	}
+	if ext != ".pdf" {
+		return "", nil
+	}
+
+	tail := make([]byte, pdfTailSize)
+	offset := info.Size() - pdfTailSize
+	if offset < 0 {
+		tail, offset = tail[:info.Size()], 0
+	}
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return "the PDF is truncated", nil
+	}
+	if bytes.Contains(tail, []byte("/Encrypt")) {
+		return "the PDF is encrypted", nil
+	}
+	return "", nil
+}
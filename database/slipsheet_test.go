@@ -0,0 +1,81 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDetectTechnicalIssues(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 5)
+	good := onePagePDF("fine")
+	encrypted := bytes.Replace(good, []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt 9 0 R"), 1)
+	root := writeLake(t, d, map[int64][]byte{
+		ids[0]: good,
+		ids[1]: {},
+		ids[2]: good[:len(good)/2],
+		ids[3]: encrypted,
+		ids[4]: []byte("PK\x03\x04 a zip named .pdf"),
+	})
+
+	issues, err := d.DetectTechnicalIssues(append(ids, 999999), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int64]string{}
+	for _, issue := range issues {
+		got[issue.FileID] = issue.Problem
+	}
+	want := map[int64]string{
+		ids[1]: "the file is empty",
+		ids[2]: "the PDF is truncated",
+		ids[3]: "the PDF is encrypted",
+		ids[4]: "the contents are not a PDF file",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for id, problem := range want {
+		if got[id] != problem {
+			t.Errorf("file %d: got %q, want %q", id, got[id], problem)
+		}
+	}
+}
+
+func TestTechnicalSlipSheetsWithholdUnconvertibleFiles(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("fine"), ids[1]: {}})
+
+	out := produce(t, d, ids, root, ProductionSettings{TechnicalSlipSheets: true})
+	if out.Documents[0].SlipSheet != "" || out.Documents[1].SlipSheet != SlipSheetTechnical {
+		t.Errorf("slip sheets are %q and %q", out.Documents[0].SlipSheet, out.Documents[1].SlipSheet)
+	}
+
+	produced, err := d.GetDocumentProductions(ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(produced) != 1 || produced[0].SlipSheet != SlipSheetTechnical {
+		t.Errorf("document productions %+v do not show the slip sheet", produced)
+	}
+	if produced, _ = d.GetDocumentProductions(ids[0]); len(produced) != 1 || produced[0].SlipSheet != "" {
+		t.Errorf("produced document reported as %+v", produced)
+	}
+}
+
+func TestSlipSheetsMustBelongToTheProduction(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("a")})
+
+	for _, sheets := range []map[int64]string{{ids[1]: SlipSheetTechnical}, {ids[0]: " "}} {
+		_, err := d.CreateZipFile(context.Background(), "PR-001", ids[:1], root, t.TempDir(),
+			ProductionSettings{SlipSheets: sheets}, nil)
+		if err == nil || !strings.Contains(err.Error(), "slip sheet") {
+			t.Errorf("%v: got %v", sheets, err)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// tagPathSeparator joins tag names into the path of a nested tag, e.g. "Medical/Leave"
+const tagPathSeparator = "/"
+
+// TagSet groups related tags; a single-select set allows one of its tags per file
+type TagSet struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	MultiSelect bool   `json:"multi_select"`
+	Tags        []Tag  `json:"tags"` // Ordered by path, so parents precede children
+}
+
+// Tag is a node in a tag set's hierarchy
+type Tag struct {
+	ID       int64  `json:"id"`
+	SetID    int64  `json:"set_id"`
+	ParentID *int64 `json:"parent_id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"` // Names from the root down, joined by "/"
+}
+
+// TagUsage counts the files carrying a tag
+type TagUsage struct {
+	Tag
+	SetName    string `json:"set_name"`
+	Count      int    `json:"count"`       // Files tagged with exactly this tag
+	TotalCount int    `json:"total_count"` // Files tagged with this tag or any descendant
+}
+
+// defaultTagSets are created on first use so the spreadsheet's tags have a home
+var defaultTagSets = []struct {
+	name        string
+	multiSelect bool
+	tags        []string
+}{
+	{"Document tags", true, []string{"WithAttorney", "WithBoss", "Medical", "WorkRelated"}},
+}
+
+// seedTagSets creates the default tag sets when no tag set exists yet
+func (d *DB) seedTagSets() error {
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM tag_sets").Scan(&count); err != nil {
+		return fmt.Errorf("failed to count tag sets: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, set := range defaultTagSets {
+		setID, err := d.CreateTagSet(set.name, set.multiSelect)
+		if err != nil {
+			return err
+		}
+		for _, name := range set.tags {
+			if _, err := d.CreateTag(setID, nil, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateTagSet creates an empty tag set and returns its ID
+func (d *DB) CreateTagSet(name string, multiSelect bool) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("tag set name is required")
+	}
+
+	result, err := d.db.Exec("INSERT INTO tag_sets (name, multi_select) VALUES (?, ?)", name, multiSelect)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tag set: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// UpdateTagSet renames a tag set or changes its selection semantics
+// Switching to single-select fails while any file carries two of the set's tags
+func (d *DB) UpdateTagSet(id int64, name string, multiSelect bool) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("tag set name is required")
+	}
+
+	if !multiSelect {
+		var conflicts int
+		err := d.db.QueryRow(`
+			SELECT COUNT(*) FROM (
+				SELECT ft.file_id FROM file_tags ft JOIN tags t ON t.id = ft.tag_id
+				WHERE t.set_id = ?
+				GROUP BY ft.file_id HAVING COUNT(*) > 1
+			)
+		`, id).Scan(&conflicts)
+		if err != nil {
+			return fmt.Errorf("failed to check tag set conflicts: %w", err)
+		}
+		if conflicts > 0 {
+			return fmt.Errorf("%d files carry more than one tag of this set; untag them before making it single-select", conflicts)
+		}
+	}
+
+	result, err := d.db.Exec("UPDATE tag_sets SET name = ?, multi_select = ? WHERE id = ?", name, multiSelect, id)
+	if err != nil {
+		return fmt.Errorf("failed to update tag set: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("tag set %d not found", id)
+	}
+	return nil
+}
+
+// DeleteTagSet removes a tag set, its tags and every file's use of them
+func (d *DB) DeleteTagSet(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM file_tags WHERE tag_id IN (SELECT id FROM tags WHERE set_id = ?)", id); err != nil {
+		return fmt.Errorf("failed to delete file tags: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE set_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete tags: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM tag_sets WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag set: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("tag set %d not found", id)
+	}
+	return tx.Commit()
+}
+
+// CreateTag adds a tag to a set, under parentID when given, and returns its ID
+// ASSUMPTION: Names are unique among siblings and never contain "/"
+func (d *DB) CreateTag(setID int64, parentID *int64, name string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("tag name is required")
+	}
+	if strings.Contains(name, tagPathSeparator) {
+		return 0, fmt.Errorf("tag name %q must not contain %q", name, tagPathSeparator)
+	}
+
+	path := name
+	if parentID != nil {
+		parent, err := d.getTag(*parentID)
+		if err != nil {
+			return 0, err
+		}
+		if parent.SetID != setID {
+			return 0, fmt.Errorf("parent tag %d belongs to another tag set", *parentID)
+		}
+		path = parent.Path + tagPathSeparator + name
+	}
+
+	result, err := d.db.Exec("INSERT INTO tags (set_id, parent_id, name, path) VALUES (?, ?, ?, ?)", setID, parentID, name, path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tag %q: %w", path, err)
+	}
+	return result.LastInsertId()
+}
+
+// DeleteTag removes a tag and its descendants, untagging every file that carried them
+func (d *DB) DeleteTag(id int64) error {
+	tag, err := d.getTag(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SQLite's substr and length count characters, so the prefix length is measured in SQL
+	// too; LIKE would treat % and _ in tag names as wildcards
+	subtree := "SELECT id FROM tags WHERE set_id = ? AND (path = ? OR substr(path, 1, length(?)) = ?)"
+	prefix := tag.Path + tagPathSeparator
+	subtreeArgs := []interface{}{tag.SetID, tag.Path, prefix, prefix}
+	if _, err := tx.Exec("DELETE FROM file_tags WHERE tag_id IN ("+subtree+")", subtreeArgs...); err != nil {
+		return fmt.Errorf("failed to delete file tags: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id IN ("+subtree+")", subtreeArgs...); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return tx.Commit()
+}
+
+// getTag returns a single tag by ID
+func (d *DB) getTag(id int64) (*Tag, error) {
+	var t Tag
+	var parentID sql.NullInt64
+	err := d.db.QueryRow("SELECT id, set_id, parent_id, name, path FROM tags WHERE id = ?", id).
+		Scan(&t.ID, &t.SetID, &parentID, &t.Name, &t.Path)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("tag %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	if parentID.Valid {
+		t.ParentID = &parentID.Int64
+	}
+	return &t, nil
+}
+
+// GetTagSets returns every tag set with its tags
+func (d *DB) GetTagSets() ([]TagSet, error) {
+	rows, err := d.db.Query("SELECT id, name, multi_select FROM tag_sets ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag sets: %w", err)
+	}
+	var sets []TagSet
+	index := make(map[int64]int)
+	for rows.Next() {
+		var s TagSet
+		if err := rows.Scan(&s.ID, &s.Name, &s.MultiSelect); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tag set: %w", err)
+		}
+		index[s.ID] = len(sets)
+		sets = append(sets, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag sets: %w", err)
+	}
+
+	rows, err = d.db.Query("SELECT id, set_id, parent_id, name, path FROM tags ORDER BY set_id, path")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t Tag
+		var parentID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.SetID, &parentID, &t.Name, &t.Path); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if parentID.Valid {
+			t.ParentID = &parentID.Int64
+		}
+		if i, ok := index[t.SetID]; ok {
+			sets[i].Tags = append(sets[i].Tags, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+	return sets, nil
+}
+
+// TagFiles applies a tag to files
+// In a single-select set the file's other tags from that set are removed first
+func (d *DB) TagFiles(fileIDs []int64, tagID int64) error {
+	op := logging.StartOperation("TagFiles", map[string]interface{}{
+		"file_count": len(fileIDs),
+		"tag_id":     tagID,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to tag files")
+
+	tag, err := d.getTag(tagID)
+	if err != nil {
+		return err
+	}
+	var multiSelect bool
+	if err := d.db.QueryRow("SELECT multi_select FROM tag_sets WHERE id = ?", tag.SetID).Scan(&multiSelect); err != nil {
+		return fmt.Errorf("failed to get tag set: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	clearStmt, err := tx.Prepare(`
+		DELETE FROM file_tags
+		WHERE file_id = ? AND tag_id != ? AND tag_id IN (SELECT id FROM tags WHERE set_id = ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare tag clear: %w", err)
+	}
+	defer clearStmt.Close()
+
+	insertStmt, err := tx.Prepare("INSERT OR IGNORE INTO file_tags (file_id, tag_id) VALUES (?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare file tag: %w", err)
+	}
+	defer insertStmt.Close()
+
+	for _, fileID := range fileIDs {
+		if !multiSelect {
+			if _, err := clearStmt.Exec(fileID, tagID, tag.SetID); err != nil {
+				return fmt.Errorf("failed to clear tags of file %d: %w", fileID, err)
+			}
+		}
+		if _, err := insertStmt.Exec(fileID, tagID); err != nil {
+			return fmt.Errorf("failed to tag file %d: %w", fileID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UntagFiles removes a tag from files
+func (d *DB) UntagFiles(fileIDs []int64, tagID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("DELETE FROM file_tags WHERE file_id = ? AND tag_id = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare untag: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, fileID := range fileIDs {
+		if _, err := stmt.Exec(fileID, tagID); err != nil {
+			return fmt.Errorf("failed to untag file %d: %w", fileID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// TagSearchResult tags every file matching filters, not just one page
+// Returns the number of files tagged
+func (d *DB) TagSearchResult(filters FileFilters, tagID int64) (int, error) {
+	ids, err := d.SearchFileIDs(filters)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.TagFiles(ids, tagID); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// UntagSearchResult removes a tag from every file matching filters
+// Returns the number of files the tag was removed from, whether or not they carried it
+func (d *DB) UntagSearchResult(filters FileFilters, tagID int64) (int, error) {
+	ids, err := d.SearchFileIDs(filters)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.UntagFiles(ids, tagID); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// GetTagUsage returns how many files carry each tag, directly and through descendants
+func (d *DB) GetTagUsage() ([]TagUsage, error) {
+	rows, err := d.db.Query(`
+		SELECT t.id, t.set_id, t.parent_id, t.name, t.path, s.name,
+		       (SELECT COUNT(*) FROM file_tags WHERE tag_id = t.id),
+		       (SELECT COUNT(DISTINCT ft.file_id) FROM file_tags ft JOIN tags d ON d.id = ft.tag_id
+		        WHERE d.set_id = t.set_id AND (d.path = t.path OR substr(d.path, 1, length(t.path) + 1) = t.path || '/'))
+		FROM tags t
+		JOIN tag_sets s ON s.id = t.set_id
+		ORDER BY s.name, t.path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []TagUsage
+	for rows.Next() {
+		var u TagUsage
+		var parentID sql.NullInt64
+		if err := rows.Scan(&u.ID, &u.SetID, &parentID, &u.Name, &u.Path, &u.SetName, &u.Count, &u.TotalCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag usage: %w", err)
+		}
+		if parentID.Valid {
+			u.ParentID = &parentID.Int64
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag usage: %w", err)
+	}
+	return usage, nil
+}
+
+// tagFilterClause returns a subquery selecting files that carry any of the tags
+// or any of their descendants, with one placeholder per tag
+func tagFilterClause(tagIDs []int64) (string, []interface{}) {
+	placeholders := make([]string, len(tagIDs))
+	args := make([]interface{}, len(tagIDs))
+	for i, id := range tagIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return fmt.Sprintf(`SELECT ft.file_id FROM file_tags ft
+		JOIN tags t ON t.id = ft.tag_id
+		JOIN tags p ON p.set_id = t.set_id
+			AND (t.path = p.path OR substr(t.path, 1, length(p.path) + 1) = p.path || '/')
+		WHERE p.id IN (%s)`, strings.Join(placeholders, ",")), args
+}
+
+// getFileTagNames returns "Set/Path" names of the tags on each of the given files
+// Reads every tagging once and filters in Go, which beats a long IN list for productions
+func (d *DB) getFileTagNames(fileIDs []int64) (map[int64][]string, error) {
+	wanted := make(map[int64]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		wanted[id] = true
+	}
+
+	rows, err := d.db.Query(`
+		SELECT ft.file_id, s.name, t.path
+		FROM file_tags ft
+		JOIN tags t ON t.id = ft.tag_id
+		JOIN tag_sets s ON s.id = t.set_id
+		ORDER BY s.name, t.path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file tags: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int64][]string)
+	for rows.Next() {
+		var fileID int64
+		var setName, path string
+		if err := rows.Scan(&fileID, &setName, &path); err != nil {
+			return nil, fmt.Errorf("failed to scan file tag: %w", err)
+		}
+		if wanted[fileID] {
+			names[fileID] = append(names[fileID], setName+tagPathSeparator+path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file tags: %w", err)
+	}
+	return names, nil
+}
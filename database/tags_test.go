@@ -0,0 +1,91 @@
+package database
+
+import "testing"
+
+func TestDeleteTagRemovesNonASCIISubtree(t *testing.T) {
+	d := newTestDB(t)
+	setID, err := d.CreateTagSet("Claims", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := d.CreateTag(setID, nil, "Rétaliation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := d.CreateTag(setID, &parent, "Réassignment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sibling, err := d.CreateTag(setID, nil, "Rétaliation_2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := firstFileIDs(t, d, 2)
+	if err := d.TagFiles(ids[:1], child); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.TagFiles(ids[1:], sibling); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.DeleteTag(parent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.getTag(child); err == nil {
+		t.Error("child of the deleted tag was left behind")
+	}
+	if _, err := d.getTag(sibling); err != nil {
+		t.Errorf("sibling sharing the name prefix was deleted: %v", err)
+	}
+	var tagged int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM file_tags WHERE tag_id = ?", child).Scan(&tagged); err != nil {
+		t.Fatal(err)
+	}
+	if tagged != 0 {
+		t.Errorf("%d files still carry the deleted child tag", tagged)
+	}
+}
+
+func TestTagUsageCountsDescendants(t *testing.T) {
+	d := newTestDB(t)
+	setID, err := d.CreateTagSet("Issues", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, _ := d.CreateTag(setID, nil, "Discrimination")
+	child, _ := d.CreateTag(setID, &parent, "Âge")
+	other, _ := d.CreateTag(setID, nil, "Other")
+	ids := firstFileIDs(t, d, 2)
+	if err := d.TagFiles(ids[:1], child); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.TagFiles(ids[:1], other); err != nil {
+		t.Fatal(err)
+	}
+
+	// Single-select: tagging Other replaced Âge on the first file
+	for _, u := range mustTagUsage(t, d) {
+		if u.ID == parent && u.TotalCount != 0 {
+			t.Errorf("Discrimination still counts %d files after the single-select replace", u.TotalCount)
+		}
+	}
+
+	if err := d.TagFiles(ids[1:], child); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range mustTagUsage(t, d) {
+		if u.ID == parent && (u.Count != 0 || u.TotalCount != 1) {
+			t.Errorf("Discrimination counts %d direct and %d total, want 0 and 1", u.Count, u.TotalCount)
+		}
+	}
+}
+
+// mustTagUsage returns GetTagUsage or fails the test
+func mustTagUsage(t *testing.T, d *DB) []TagUsage {
+	t.Helper()
+	usage, err := d.GetTagUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return usage
+}
@@ -0,0 +1,427 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+	"signal-from-noise/textmodel"
+)
+
+// topicModelSeed fixes k-means seeding so retraining the same corpus gives the same topics
+const topicModelSeed = 42
+
+// Assignment thresholds: a document gets at most three topics, and secondary
+// topics must be at least this similar to count
+const (
+	maxTopicsPerFile   = 3
+	minTopicSimilarity = 0.1
+)
+
+// TopicSummary describes one modeled topic
+type TopicSummary struct {
+	ID            int64    `json:"id"`
+	Label         string   `json:"label"`     // Top three terms, human-readable
+	TopTerms      []string `json:"top_terms"` // Highest-weighted centroid terms
+	DocumentCount int      `json:"document_count"`
+}
+
+// FileTopic is a topic assigned to a file with its weight
+type FileTopic struct {
+	TopicID int64   `json:"topic_id"`
+	Label   string  `json:"label"`
+	Weight  float64 `json:"weight"` // Weights of one file's topics sum to 1
+}
+
+// TopicModelInfo summarizes a training run
+type TopicModelInfo struct {
+	ModelID        int64          `json:"model_id"`
+	TopicCount     int            `json:"topic_count"`
+	DocumentCount  int            `json:"document_count"`
+	VocabularySize int            `json:"vocabulary_size"`
+	TrainedAt      time.Time      `json:"trained_at"`
+	Topics         []TopicSummary `json:"topics"`
+}
+
+// corpusDocument is the text of one file as seen by the topic model
+type corpusDocument struct {
+	fileID    int64
+	text      string // documentText of the file
+	nameStart int    // Where the file name begins in text
+	textStart int    // Where the extracted text begins in text
+}
+
+// documentText builds the text analysed for a file
+// ASSUMPTION: Subject, file name and extracted text together describe the document
+// Directory names are excluded - they describe storage, not content
+func documentText(subject, fileName, extractedText string) string {
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	return strings.Join([]string{subject, name, extractedText}, "\n")
+}
+
+// TrainTopicModel fits a TF-IDF + k-means topic model over the whole corpus
+// and replaces all topic assignments. Pass k <= 0 to pick a topic count from corpus size.
+// Every file's topic column is set to its dominant modeled topic.
+func (d *DB) TrainTopicModel(k int) (*TopicModelInfo, error) {
+	op := logging.StartOperation("TrainTopicModel", map[string]interface{}{
+		"requested_topics": k,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to train topic model")
+
+	docs, err := d.loadCorpus(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no files to train topic model on")
+	}
+
+	if k <= 0 {
+		// Rule of thumb: sqrt(n/2) clusters, bounded to stay readable
+		k = int(math.Sqrt(float64(len(docs)) / 2))
+		if k < 2 {
+			k = 2
+		}
+		if k > 12 {
+			k = 12
+		}
+	}
+
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.text
+	}
+
+	model, err := textmodel.TrainTopicModel(texts, k, topicModelSeed)
+	if err != nil {
+		logging.LogError("TrainTopicModel", err, map[string]interface{}{
+			"operation": "train",
+			"documents": len(docs),
+		})
+		return nil, fmt.Errorf("failed to train topic model: %w", err)
+	}
+
+	modelJSON, err := json.Marshal(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode topic model: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	trainedAt := time.Now().UTC()
+	result, err := tx.Exec(`
+		INSERT INTO topic_models (topic_count, document_count, vocabulary_size, model, trained_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, model.TopicCount(), len(docs), model.Vectorizer.Size(), string(modelJSON), trainedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save topic model: %w", err)
+	}
+	modelID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topic model id: %w", err)
+	}
+
+	// Only the latest model's topics are kept; older model rows remain as history
+	if _, err := tx.Exec("DELETE FROM file_topics"); err != nil {
+		return nil, fmt.Errorf("failed to clear file topics: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM topics"); err != nil {
+		return nil, fmt.Errorf("failed to clear topics: %w", err)
+	}
+
+	topics := make([]TopicSummary, model.TopicCount())
+	labels := make(map[string]bool, len(topics))
+	for cluster := range topics {
+		terms := model.TopTerms(cluster, 10)
+		label := topicLabel(terms, labels)
+		termsJSON, err := json.Marshal(terms)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode topic terms: %w", err)
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO topics (model_id, cluster, label, top_terms) VALUES (?, ?, ?, ?)
+		`, modelID, cluster, label, string(termsJSON))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save topic: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get topic id: %w", err)
+		}
+		topics[cluster] = TopicSummary{ID: id, Label: label, TopTerms: terms}
+	}
+
+	for _, doc := range docs {
+		weights := model.Assign(doc.text, maxTopicsPerFile, minTopicSimilarity)
+		if err := writeFileTopics(tx, doc.fileID, weights, topics); err != nil {
+			return nil, err
+		}
+		for _, w := range weights {
+			topics[w.Topic].DocumentCount++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit topic model: %w", err)
+	}
+
+	info := &TopicModelInfo{
+		ModelID:        modelID,
+		TopicCount:     model.TopicCount(),
+		DocumentCount:  len(docs),
+		VocabularySize: model.Vectorizer.Size(),
+		TrainedAt:      trainedAt,
+		Topics:         topics,
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"model_id":        modelID,
+		"topic_count":     info.TopicCount,
+		"document_count":  info.DocumentCount,
+		"vocabulary_size": info.VocabularySize,
+	})
+
+	return info, nil
+}
+
+// AssignTopics scores files against the latest trained model without retraining
+// ExtractText calls it for the files whose text it stores
+func (d *DB) AssignTopics(fileIDs []int64) error {
+	op := logging.StartOperation("AssignTopics", map[string]interface{}{
+		"file_count": len(fileIDs),
+	})
+	defer op.EndOperation()
+
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	var modelJSON string
+	var modelID int64
+	err := d.db.QueryRow("SELECT id, model FROM topic_models ORDER BY id DESC LIMIT 1").Scan(&modelID, &modelJSON)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no topic model trained")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load topic model: %w", err)
+	}
+
+	var model textmodel.TopicModel
+	if err := json.Unmarshal([]byte(modelJSON), &model); err != nil {
+		return fmt.Errorf("failed to decode topic model: %w", err)
+	}
+
+	topics := make([]TopicSummary, model.TopicCount())
+	rows, err := d.db.Query("SELECT id, cluster, label FROM topics WHERE model_id = ?", modelID)
+	if err != nil {
+		return fmt.Errorf("failed to query topics: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t TopicSummary
+		var cluster int
+		if err := rows.Scan(&t.ID, &cluster, &t.Label); err != nil {
+			return fmt.Errorf("failed to scan topic: %w", err)
+		}
+		// ASSUMPTION: Stored clusters match the model's centroid count
+		assert.That(cluster >= 0 && cluster < len(topics), "topic cluster must index a model centroid")
+		topics[cluster] = t
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating topics: %w", err)
+	}
+
+	docs, err := d.loadCorpus(fileIDs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, doc := range docs {
+		if _, err := tx.Exec("DELETE FROM file_topics WHERE file_id = ?", doc.fileID); err != nil {
+			return fmt.Errorf("failed to clear file topics: %w", err)
+		}
+		weights := model.Assign(doc.text, maxTopicsPerFile, minTopicSimilarity)
+		if err := writeFileTopics(tx, doc.fileID, weights, topics); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit topic assignments: %w", err)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"model_id":       modelID,
+		"files_assigned": len(docs),
+	})
+	return nil
+}
+
+// writeFileTopics stores a file's topic weights and sets its dominant topic
+// Files with no known terms get a NULL topic so stale subject prefixes don't linger
+func writeFileTopics(tx *sql.Tx, fileID int64, weights []textmodel.TopicWeight, topics []TopicSummary) error {
+	for _, w := range weights {
+		_, err := tx.Exec(`
+			INSERT INTO file_topics (file_id, topic_id, weight) VALUES (?, ?, ?)
+		`, fileID, topics[w.Topic].ID, w.Weight)
+		if err != nil {
+			return fmt.Errorf("failed to save file topic: %w", err)
+		}
+	}
+
+	var dominant interface{}
+	if len(weights) > 0 {
+		dominant = topics[weights[0].Topic].Label
+	}
+	if _, err := tx.Exec("UPDATE files SET topic = ? WHERE id = ?", dominant, fileID); err != nil {
+		return fmt.Errorf("failed to set dominant topic: %w", err)
+	}
+	return nil
+}
+
+// loadCorpus reads document text for the given files, or for all files when ids is nil
+func (d *DB) loadCorpus(ids []int64) ([]corpusDocument, error) {
+	query := "SELECT id, subject, file_name, extracted_text FROM files"
+	args := []interface{}{}
+	if ids != nil {
+		placeholders := ""
+		for i, id := range ids {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+			args = append(args, id)
+		}
+		query += fmt.Sprintf(" WHERE id IN (%s)", placeholders)
+	}
+	query += " ORDER BY id"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query corpus: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []corpusDocument
+	for rows.Next() {
+		var id int64
+		var fileName string
+		var subject, extractedText sql.NullString
+		if err := rows.Scan(&id, &subject, &fileName, &extractedText); err != nil {
+			return nil, fmt.Errorf("failed to scan corpus document: %w", err)
+		}
+		// documentText puts one newline after the subject and one after the file name
+		name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		docs = append(docs, corpusDocument{
+			fileID:    id,
+			text:      documentText(subject.String, fileName, extractedText.String),
+			nameStart: len(subject.String) + 1,
+			textStart: len(subject.String) + len(name) + 2,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating corpus: %w", err)
+	}
+	return docs, nil
+}
+
+// topicLabel joins the leading terms into a display label not already in used, and adds it
+// Topics that share their leading terms get more terms, then a number, so every
+// label (which is also each file's topic column) names one topic
+func topicLabel(terms []string, used map[string]bool) string {
+	n := len(terms)
+	if n > 3 {
+		n = 3
+	}
+	label := strings.Join(terms[:n], ", ")
+	for used[label] && n < len(terms) {
+		n++
+		label = strings.Join(terms[:n], ", ")
+	}
+	base := label
+	for i := 2; used[label]; i++ {
+		label = fmt.Sprintf("%s (%d)", base, i)
+	}
+	used[label] = true
+	return label
+}
+
+// GetTopicSummaries returns the latest model's topics with their top terms and document counts
+func (d *DB) GetTopicSummaries() ([]TopicSummary, error) {
+	rows, err := d.db.Query(`
+		SELECT t.id, t.label, t.top_terms, COUNT(ft.file_id)
+		FROM topics t
+		LEFT JOIN file_topics ft ON ft.topic_id = t.id
+		GROUP BY t.id
+		ORDER BY t.cluster
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query topic summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []TopicSummary
+	for rows.Next() {
+		var s TopicSummary
+		var termsJSON string
+		if err := rows.Scan(&s.ID, &s.Label, &termsJSON, &s.DocumentCount); err != nil {
+			return nil, fmt.Errorf("failed to scan topic summary: %w", err)
+		}
+		if err := json.Unmarshal([]byte(termsJSON), &s.TopTerms); err != nil {
+			return nil, fmt.Errorf("failed to decode topic terms: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating topic summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// GetFileTopics returns a file's modeled topics, strongest first
+func (d *DB) GetFileTopics(fileID int64) ([]FileTopic, error) {
+	rows, err := d.db.Query(`
+		SELECT t.id, t.label, ft.weight
+		FROM file_topics ft
+		JOIN topics t ON t.id = ft.topic_id
+		WHERE ft.file_id = ?
+		ORDER BY ft.weight DESC
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file topics: %w", err)
+	}
+	defer rows.Close()
+
+	var topics []FileTopic
+	for rows.Next() {
+		var t FileTopic
+		if err := rows.Scan(&t.TopicID, &t.Label, &t.Weight); err != nil {
+			return nil, fmt.Errorf("failed to scan file topic: %w", err)
+		}
+		topics = append(topics, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file topics: %w", err)
+	}
+	return topics, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import "testing"
+
+func TestTopicLabelsAreUnique(t *testing.T) {
+	used := make(map[string]bool)
+	got := []string{
+		topicLabel([]string{"budget", "contract", "review", "invoice"}, used),
+		topicLabel([]string{"budget", "contract", "review", "approval"}, used),
+		topicLabel([]string{"budget", "contract", "review"}, used),
+		topicLabel([]string{"budget", "contract", "review"}, used),
+	}
+	want := []string{
+		"budget, contract, review",
+		"budget, contract, review, approval",
+		"budget, contract, review (2)",
+		"budget, contract, review (3)",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("label %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTrainTopicModelLabelsEachTopicOnce(t *testing.T) {
+	d := newTestDB(t)
+	if _, err := d.TrainTopicModel(12); err != nil {
+		t.Fatal(err)
+	}
+	topics, err := d.GetTopicSummaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, topic := range topics {
+		if seen[topic.Label] {
+			t.Errorf("label %q names two topics", topic.Label)
+		}
+		seen[topic.Label] = true
+	}
+}
@@ -7,9 +7,9 @@ import (
 	"signal-from-noise/logging"
 )
 
-// GetTopics returns unique topics from email subjects
-// ASSUMPTION: Topics can be extracted from email subjects
-// ASSUMPTION: Topics exist in database (topic field is populated)
+// GetTopics returns unique topic labels
+// Labels come from the trained topic model when one exists (see TrainTopicModel),
+// otherwise from the subject-prefix topic field of emails
 // If no topics found, assumption about topic extraction is falsified
 func (d *DB) GetTopics() ([]string, error) {
 	op := logging.StartOperation("GetTopics", map[string]interface{}{})
@@ -18,7 +18,12 @@ func (d *DB) GetTopics() ([]string, error) {
 	// ASSUMPTION: Database connection exists
 	assert.ThatNotNil(d.db, "database connection must exist to get topics")
 
-	// ASSUMPTION: Topics are stored in topic field
+	var modeled int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM topics").Scan(&modeled); err != nil {
+		return nil, fmt.Errorf("failed to count modeled topics: %w", err)
+	}
+
+	// ASSUMPTION: Topics are stored in topic field until a model is trained
 	// If topic field is NULL for all emails, assumption about topic extraction is false
 	query := `
 		SELECT DISTINCT topic
@@ -26,9 +31,18 @@ func (d *DB) GetTopics() ([]string, error) {
 		WHERE category = 'email' AND topic IS NOT NULL AND topic != ''
 		ORDER BY topic
 	`
+	note := "extracting_unique_topics_from_email_subjects"
+	if modeled > 0 {
+		query = `
+			SELECT label
+			FROM topics
+			ORDER BY label
+		`
+		note = "listing_modeled_topic_labels"
+	}
 
 	logging.LogQuery(query, map[string]interface{}{
-		"note": "extracting_unique_topics_from_email_subjects",
+		"note": note,
 	})
 
 	rows, err := d.db.Query(query)
@@ -0,0 +1,452 @@
+package database
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"signal-from-noise/assert"
+	"signal-from-noise/logging"
+)
+
+// Checks run by VerifyProduction
+const (
+	VerifyCheckHashes    = "entry_hashes"     // Volumes and entries match the production record
+	VerifyCheckBates     = "bates_continuity" // Bates numbers run without gaps or overlaps
+	VerifyCheckPrivilege = "no_privileged"    // No privileged document went out as a native
+	VerifyCheckLoadFiles = "load_files"       // Every load file reference resolves to an entry
+)
+
+// VerificationCheck is the outcome of one check; Problems is empty when it passed
+type VerificationCheck struct {
+	Name     string   `json:"name"`
+	Passed   bool     `json:"passed"`
+	Problems []string `json:"problems"`
+}
+
+// VerificationReport is the pass/fail report for a produced archive
+type VerificationReport struct {
+	ProductionID        int64               `json:"production_id"`
+	ProductionRequestID string              `json:"production_request_id"`
+	Directory           string              `json:"directory"` // Where the volumes were read from
+	Passed              bool                `json:"passed"`
+	CheckedAt           time.Time           `json:"checked_at"`
+	Checks              []VerificationCheck `json:"checks"`
+}
+
+// VerifyProduction re-opens a produced archive and checks it against its record
+// Every entry is hashed and compared, Bates numbers must be continuous, no document
+// that is now flagged privileged may be in it as a native, and every DAT, OPT and
+// EDRM XML reference must resolve. An empty directory reads the volumes from where
+// they were produced; otherwise from a delivered copy of that directory.
+// An encrypted production needs its password, which is checked before anything is read
+func (d *DB) VerifyProduction(id int64, directory, password string) (*VerificationReport, error) {
+	op := logging.StartOperation("VerifyProduction", map[string]interface{}{
+		"production_id": id,
+		"directory":     directory,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to verify a production")
+
+	output, err := d.GetProduction(id)
+	if err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return nil, fmt.Errorf("production %d not found", id)
+	}
+	if directory == "" {
+		directory = output.Directory
+	}
+	if output.Settings.Encrypt {
+		if err := checkProductionPassword(id, output.Settings, password); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &VerificationReport{
+		ProductionID:        id,
+		ProductionRequestID: output.ProductionRequestID,
+		Directory:           directory,
+		CheckedAt:           time.Now().UTC().Truncate(time.Second),
+	}
+
+	// Read every volume once; the checks below work from its entries
+	volumes := make(map[string]*zip.ReadCloser, len(output.Volumes))
+	defer func() {
+		for _, r := range volumes {
+			r.Close()
+		}
+	}()
+	hashes := VerificationCheck{Name: VerifyCheckHashes}
+	for _, v := range output.Volumes {
+		path := filepath.Join(directory, v.Name+".zip")
+		sum, err := hashFile(path)
+		if err != nil {
+			hashes.Problems = append(hashes.Problems, fmt.Sprintf("%s.zip cannot be read: %v", v.Name, err))
+			continue
+		}
+		if sum != v.SHA256 {
+			hashes.Problems = append(hashes.Problems, fmt.Sprintf("%s.zip SHA-256 is %s, recorded %s", v.Name, sum, v.SHA256))
+		}
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			hashes.Problems = append(hashes.Problems, fmt.Sprintf("%s.zip is not a readable zip: %v", v.Name, err))
+			continue
+		}
+		volumes[v.Name] = r
+	}
+	entries := make(map[string]*zip.File)
+	for _, r := range volumes {
+		for _, f := range r.File {
+			entries[f.Name] = f
+		}
+	}
+
+	hashes.Problems = append(hashes.Problems, verifyEntryHashes(output.Documents, entries, password)...)
+	privilege, err := d.verifyNoPrivileged(output.Documents)
+	if err != nil {
+		return nil, err
+	}
+	bates, err := d.verifyBatesContinuity(output)
+	if err != nil {
+		return nil, err
+	}
+	loadFiles := VerificationCheck{Name: VerifyCheckLoadFiles}
+	for _, v := range output.Volumes {
+		if volumes[v.Name] != nil {
+			loadFiles.Problems = append(loadFiles.Problems, verifyLoadFiles(v.Name, output.Documents, entries, password)...)
+		}
+	}
+
+	report.Passed = true
+	for _, check := range []VerificationCheck{hashes, bates, privilege, loadFiles} {
+		check.Passed = len(check.Problems) == 0
+		if check.Problems == nil {
+			check.Problems = []string{}
+		}
+		report.Passed = report.Passed && check.Passed
+		report.Checks = append(report.Checks, check)
+	}
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"passed":  report.Passed,
+		"volumes": len(output.Volumes),
+	})
+	return report, nil
+}
+
+// verifyEntryHashes compares each recorded native with its entry, and reports natives
+// in the archive that the record does not list
+func verifyEntryHashes(docs []ProducedDocument, entries map[string]*zip.File, password string) []string {
+	var problems []string
+	recorded := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		recorded[doc.NativePath] = true
+		entry := entries[doc.NativePath]
+		if entry == nil {
+			problems = append(problems, fmt.Sprintf("%s: %s is missing", doc.BegBates, doc.NativePath))
+			continue
+		}
+		sum, err := hashZipEntry(entry, password)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s cannot be read: %v", doc.BegBates, doc.NativePath, err))
+			continue
+		}
+		if sum != doc.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s: %s SHA-256 is %s, recorded %s", doc.BegBates, doc.NativePath, sum, doc.SHA256))
+		}
+		if doc.TextPath != "" && entries[doc.TextPath] == nil {
+			problems = append(problems, fmt.Sprintf("%s: %s is missing", doc.BegBates, doc.TextPath))
+		}
+	}
+
+	var unexpected []string
+	for name := range entries {
+		if strings.Contains(name, "/NATIVES/") && !recorded[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		problems = append(problems, fmt.Sprintf("%s is in the archive but not in the production record", name))
+	}
+	return problems
+}
+
+// verifyBatesContinuity checks that documents are numbered consecutively with one
+// number per page, that volumes cover their documents' ranges, and that no other
+// production used any of the same numbers
+func (d *DB) verifyBatesContinuity(output *ProductionOutput) (VerificationCheck, error) {
+	check := VerificationCheck{Name: VerifyCheckBates}
+	prefix := output.BatesPrefix
+
+	var prev int64
+	for i, doc := range output.Documents {
+		beg, begErr := parseBates(prefix, doc.BegBates)
+		end, endErr := parseBates(prefix, doc.EndBates)
+		if begErr != nil || endErr != nil {
+			check.Problems = append(check.Problems, fmt.Sprintf("%s-%s is not numbered under %s", doc.BegBates, doc.EndBates, prefix))
+			continue
+		}
+		if end-beg+1 != doc.Pages {
+			check.Problems = append(check.Problems, fmt.Sprintf("%s-%s spans %d numbers for %d pages", doc.BegBates, doc.EndBates, end-beg+1, doc.Pages))
+		}
+		if i > 0 && beg != prev+1 {
+			check.Problems = append(check.Problems, fmt.Sprintf("%s does not follow %s", doc.BegBates, output.Documents[i-1].EndBates))
+		}
+		prev = end
+	}
+
+	for _, v := range output.Volumes {
+		var first, last string
+		for _, doc := range output.Documents {
+			if doc.Volume != v.Name {
+				continue
+			}
+			if first == "" {
+				first = doc.BegBates
+			}
+			last = doc.EndBates
+		}
+		if v.BegBates != first || v.EndBates != last {
+			check.Problems = append(check.Problems, fmt.Sprintf("%s is recorded as %s-%s but holds %s-%s", v.Name, v.BegBates, v.EndBates, first, last))
+		}
+	}
+
+	// Ranges are compared numerically, since padding may have grown between productions
+	if len(output.Documents) == 0 {
+		return check, nil
+	}
+	lo, errLo := parseBates(prefix, output.Documents[0].BegBates)
+	hi, errHi := parseBates(prefix, output.Documents[len(output.Documents)-1].EndBates)
+	if errLo != nil || errHi != nil {
+		return check, nil
+	}
+	rows, err := d.db.Query(`
+		SELECT id, beg_bates, end_bates FROM productions WHERE bates_prefix = ? AND id != ? ORDER BY id
+	`, prefix, output.ID)
+	if err != nil {
+		return check, fmt.Errorf("failed to query productions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var otherID int64
+		var otherBeg, otherEnd string
+		if err := rows.Scan(&otherID, &otherBeg, &otherEnd); err != nil {
+			return check, fmt.Errorf("failed to scan production: %w", err)
+		}
+		b, errB := parseBates(prefix, otherBeg)
+		e, errE := parseBates(prefix, otherEnd)
+		if errB == nil && errE == nil && b <= hi && e >= lo {
+			check.Problems = append(check.Problems, fmt.Sprintf("numbers overlap production %d (%s-%s)", otherID, otherBeg, otherEnd))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return check, fmt.Errorf("error iterating productions: %w", err)
+	}
+	return check, nil
+}
+
+// parseBates returns the number of a Bates label under prefix
+func parseBates(prefix, bates string) (int64, error) {
+	if !strings.HasPrefix(bates, prefix) {
+		return 0, fmt.Errorf("%s does not start with %s", bates, prefix)
+	}
+	return strconv.ParseInt(bates[len(prefix):], 10, 64)
+}
+
+// verifyNoPrivileged reports documents produced as natives although the file is now
+// flagged privileged, logged as withheld, logged for redaction but produced without
+// any, or still waiting in the privilege review queue
+func (d *DB) verifyNoPrivileged(docs []ProducedDocument) (VerificationCheck, error) {
+	check := VerificationCheck{Name: VerifyCheckPrivilege}
+	fileIDs := make([]int64, 0, len(docs))
+	for _, doc := range docs {
+		fileIDs = append(fileIDs, doc.FileID)
+	}
+	files, err := d.GetFilesByIDs(fileIDs)
+	if err != nil {
+		return check, fmt.Errorf("failed to get files: %w", err)
+	}
+	privileged := make(map[int64]bool, len(files))
+	for _, f := range files {
+		privileged[f.ID] = f.Privileged
+	}
+
+	treatments := make(map[int64]string)
+	rows, err := d.db.Query("SELECT file_id, treatment FROM privilege_entries")
+	if err != nil {
+		return check, fmt.Errorf("failed to query privilege entries: %w", err)
+	}
+	for rows.Next() {
+		var fileID int64
+		var treatment string
+		if err := rows.Scan(&fileID, &treatment); err != nil {
+			rows.Close()
+			return check, fmt.Errorf("failed to scan privilege entry: %w", err)
+		}
+		treatments[fileID] = treatment
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return check, fmt.Errorf("error iterating privilege entries: %w", err)
+	}
+
+	pending := make(map[int64]bool)
+	rows, err = d.db.Query("SELECT file_id FROM privilege_queue WHERE status = ?", QueueStatusPending)
+	if err != nil {
+		return check, fmt.Errorf("failed to query privilege queue: %w", err)
+	}
+	for rows.Next() {
+		var fileID int64
+		if err := rows.Scan(&fileID); err != nil {
+			rows.Close()
+			return check, fmt.Errorf("failed to scan privilege queue: %w", err)
+		}
+		pending[fileID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return check, fmt.Errorf("error iterating privilege queue: %w", err)
+	}
+
+	for _, doc := range docs {
+		if doc.SlipSheet != "" {
+			continue // Only the placeholder was produced
+		}
+		switch treatment := treatments[doc.FileID]; {
+		case treatment == TreatmentWithheld || treatment == TreatmentFamilyWithheld:
+			check.Problems = append(check.Problems, fmt.Sprintf("%s (%s) is logged as withheld but was produced", doc.BegBates, doc.Path))
+		case treatment == TreatmentRedacted:
+			if doc.Redactions == 0 {
+				check.Problems = append(check.Problems, fmt.Sprintf("%s (%s) is logged for redaction but was produced unredacted", doc.BegBates, doc.Path))
+			}
+		case privileged[doc.FileID]:
+			check.Problems = append(check.Problems, fmt.Sprintf("%s (%s) is flagged privileged but was produced", doc.BegBates, doc.Path))
+		case pending[doc.FileID]:
+			check.Problems = append(check.Problems, fmt.Sprintf("%s (%s) is awaiting privilege review", doc.BegBates, doc.Path))
+		}
+	}
+	return check, nil
+}
+
+// verifyLoadFiles checks one volume's DAT, OPT and EDRM XML against its entries
+// Every native and text path must resolve, and the DAT must list exactly the volume's
+// documents in Bates order
+func verifyLoadFiles(volume string, docs []ProducedDocument, entries map[string]*zip.File, password string) []string {
+	var problems []string
+	names := make(map[string]bool)
+	for name := range entries {
+		if strings.HasPrefix(name, volume+"/") {
+			names[name] = true
+		}
+	}
+
+	var want []string
+	for _, doc := range docs {
+		if doc.Volume == volume {
+			want = append(want, doc.BegBates)
+		}
+	}
+
+	datName := volume + "/DATA/" + volume + ".dat"
+	dat, err := readZipEntry(entries[datName], password)
+	if err != nil {
+		return append(problems, fmt.Sprintf("%s: %v", datName, err))
+	}
+	records := strings.Split(strings.TrimSuffix(strings.TrimPrefix(string(dat), datBOM), "\r\n"), "\r\n")
+	header := splitDATLine(records[0])
+	column := make(map[string]int, len(header))
+	for i, field := range header {
+		column[field] = i
+	}
+	var got []string
+	for n, record := range records[1:] {
+		values := splitDATLine(record)
+		if len(values) != len(header) {
+			problems = append(problems, fmt.Sprintf("%s line %d has %d fields, header has %d", datName, n+2, len(values), len(header)))
+			continue
+		}
+		if i, ok := column[DATFieldBegBates]; ok {
+			got = append(got, values[i])
+		}
+		for _, field := range []string{DATFieldNativePath, DATFieldTextPath} {
+			i, ok := column[field]
+			if !ok || values[i] == "" {
+				continue
+			}
+			if ref := filepathFromLoadFile(values[i]); !names[ref] {
+				problems = append(problems, fmt.Sprintf("%s line %d: %s %s does not resolve", datName, n+2, field, values[i]))
+			}
+		}
+	}
+	if _, ok := column[DATFieldBegBates]; ok && strings.Join(got, ",") != strings.Join(want, ",") {
+		problems = append(problems, fmt.Sprintf("%s lists %d documents, the volume holds %d (or their order differs)", datName, len(got), len(want)))
+	}
+
+	optName := volume + "/DATA/" + volume + ".opt"
+	if entry := entries[optName]; entry != nil {
+		opt, err := readZipEntry(entry, password)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", optName, err))
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(opt))
+		for n := 1; scanner.Scan(); n++ {
+			fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+			if len(fields) < 3 {
+				problems = append(problems, fmt.Sprintf("%s line %d is malformed", optName, n))
+				continue
+			}
+			if ref := filepathFromLoadFile(fields[2]); !names[ref] {
+				problems = append(problems, fmt.Sprintf("%s line %d: %s does not resolve", optName, n, fields[2]))
+			} else if !isImagePath(ref) {
+				problems = append(problems, fmt.Sprintf("%s line %d: %s is not an image", optName, n, fields[2]))
+			}
+		}
+	}
+
+	xmlName := volume + "/DATA/" + volume + ".xml"
+	if entry := entries[xmlName]; entry != nil {
+		data, err := readZipEntry(entry, password)
+		if err == nil {
+			err = validateEDRM(data, names)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", xmlName, err))
+		}
+	}
+	return problems
+}
+
+// splitDATLine splits a DAT record into its unquoted values
+func splitDATLine(line string) []string {
+	values := strings.Split(line, datSeparator)
+	for i, v := range values {
+		values[i] = strings.TrimSuffix(strings.TrimPrefix(v, datQuote), datQuote)
+	}
+	return values
+}
+
+// readZipEntry returns an entry's contents, decrypting it with password when encrypted;
+// a nil entry is reported as missing
+func readZipEntry(entry *zip.File, password string) ([]byte, error) {
+	if entry == nil {
+		return nil, fmt.Errorf("missing from the archive")
+	}
+	rc, err := openZipEntry(entry, password)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
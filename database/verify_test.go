@@ -0,0 +1,166 @@
+package database
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failedChecks returns the names of the checks that did not pass
+func failedChecks(report *VerificationReport) []string {
+	var failed []string
+	for _, c := range report.Checks {
+		if !c.Passed {
+			failed = append(failed, c.Name)
+		}
+	}
+	return failed
+}
+
+// dropZipEntry rewrites a zip without the named entry
+func dropZipEntry(t *testing.T, path, name string) {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	rewritten := path + ".tmp"
+	f, err := os.Create(rewritten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, entry := range r.File {
+		if entry.Name == name {
+			continue
+		}
+		if err := w.Copy(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := os.Rename(rewritten, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyProductionPasses(t *testing.T) {
+	d := newTestDB(t)
+	_, _, out := producedFixture(t, d, ProductionSettings{VolumeSizeLimit: 1 << 20, EDRMXML: true})
+
+	report, err := d.VerifyProduction(out.ID, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Passed || len(report.Checks) != 4 {
+		t.Errorf("report failed %v with %d checks", failedChecks(report), len(report.Checks))
+	}
+	if report.Directory != out.Directory {
+		t.Errorf("read volumes from %s, want %s", report.Directory, out.Directory)
+	}
+}
+
+func TestVerifyProductionReadsDeliveredCopy(t *testing.T) {
+	d := newTestDB(t)
+	_, _, out := producedFixture(t, d, ProductionSettings{})
+
+	delivered := filepath.Join(t.TempDir(), "delivered")
+	if err := os.Rename(out.Directory, delivered); err != nil {
+		t.Fatal(err)
+	}
+	report, err := d.VerifyProduction(out.ID, delivered, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Passed {
+		t.Errorf("delivered copy failed %v", failedChecks(report))
+	}
+
+	// The original location is now empty
+	if report, err = d.VerifyProduction(out.ID, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed {
+		t.Error("verification passed without any volumes")
+	}
+}
+
+func TestVerifyProductionDetectsMissingEntry(t *testing.T) {
+	d := newTestDB(t)
+	_, _, out := producedFixture(t, d, ProductionSettings{})
+	dropZipEntry(t, out.Volumes[0].Path, out.Documents[1].NativePath)
+
+	report, err := d.VerifyProduction(out.ID, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := strings.Join(failedChecks(report), ",")
+	if failed != VerifyCheckHashes+","+VerifyCheckLoadFiles {
+		t.Errorf("failed checks %s, want hashes and load files", failed)
+	}
+	// The DAT writes paths with backslashes, so match the file name
+	missing := filepath.Base(out.Documents[1].NativePath)
+	for _, c := range report.Checks {
+		if c.Name == VerifyCheckLoadFiles && !strings.Contains(strings.Join(c.Problems, "\n"), missing) {
+			t.Errorf("load file problems %v don't name the missing native", c.Problems)
+		}
+	}
+}
+
+func TestVerifyProductionDetectsNewlyPrivileged(t *testing.T) {
+	d := newTestDB(t)
+	ids, _, out := producedFixture(t, d, ProductionSettings{})
+	err := d.SetPrivilegeEntry(PrivilegeEntry{
+		FileID:      ids[0],
+		Bases:       []string{PrivilegeAttorneyClient},
+		Description: "Advice of counsel",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := d.VerifyProduction(out.ID, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := failedChecks(report); len(failed) != 1 || failed[0] != VerifyCheckPrivilege {
+		t.Errorf("failed checks %v, want only %s", failed, VerifyCheckPrivilege)
+	}
+}
+
+func TestVerifyProductionDetectsBatesOverlap(t *testing.T) {
+	d := newTestDB(t)
+	_, root, first := producedFixture(t, d, ProductionSettings{})
+	second := produce(t, d, firstFileIDs(t, d, 1), root, ProductionSettings{})
+	mustExec(t, d, "UPDATE productions SET beg_bates = ? WHERE id = ?", first.Documents[0].BegBates, second.ID)
+
+	report, err := d.VerifyProduction(first.ID, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := failedChecks(report); len(failed) != 1 || failed[0] != VerifyCheckBates {
+		t.Errorf("failed checks %v, want only %s", failed, VerifyCheckBates)
+	}
+}
+
+func TestVerifyEncryptedProduction(t *testing.T) {
+	d := newTestDB(t)
+	_, _, out := producedFixture(t, d, ProductionSettings{Encrypt: true, Password: "correct horse", EDRMXML: true})
+
+	if _, err := d.VerifyProduction(out.ID, "", "wrong"); err == nil {
+		t.Error("verified an encrypted production with the wrong password")
+	}
+	report, err := d.VerifyProduction(out.ID, "", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Passed {
+		t.Errorf("encrypted production failed %v", failedChecks(report))
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Minimal SpreadsheetML parts for a single-sheet workbook
+// Cells are written as inline strings, so no shared string table is needed
+// Style 1 is a bold font used for the header row
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`
+)
+
+// WriteXLSX writes rows to a single-sheet .xlsx workbook; the first row is bold
+// Every cell is text, which keeps identifiers like Bates numbers from being reformatted
+func WriteXLSX(path, sheetName string, rows [][]string) error {
+	workbook, err := xlsxWorkbook(sheetName)
+	if err != nil {
+		return err
+	}
+	sheet, err := xlsxSheet(rows)
+	if err != nil {
+		return err
+	}
+
+	parts := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(xlsxContentTypes)},
+		{"_rels/.rels", []byte(xlsxRootRels)},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", []byte(xlsxWorkbookRels)},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+		{"xl/worksheets/sheet1.xml", sheet},
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create workbook: %w", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to create workbook part %s: %w", part.name, err)
+		}
+		if _, err := w.Write(part.data); err != nil {
+			return fmt.Errorf("failed to write workbook part %s: %w", part.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish workbook: %w", err)
+	}
+	return out.Close()
+}
+
+// xlsxWorkbook renders the workbook part naming the single sheet
+func xlsxWorkbook(sheetName string) ([]byte, error) {
+	// Excel limits sheet names to 31 characters
+	if len(sheetName) > 31 {
+		sheetName = sheetName[:31]
+	}
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="`)
+	if err := xml.EscapeText(&b, []byte(sheetName)); err != nil {
+		return nil, fmt.Errorf("failed to escape sheet name: %w", err)
+	}
+	b.WriteString(`" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`)
+	return b.Bytes(), nil
+}
+
+// xlsxSheet renders the worksheet part with one inline-string cell per value
+func xlsxSheet(rows [][]string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>`)
+	for r, row := range rows {
+		rowNumber := strconv.Itoa(r + 1)
+		b.WriteString(`<row r="` + rowNumber + `">`)
+		for c, value := range row {
+			b.WriteString(`<c r="` + xlsxColumn(c) + rowNumber + `" t="inlineStr"`)
+			if r == 0 {
+				b.WriteString(` s="1"`)
+			}
+			b.WriteString(`><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(&b, []byte(value)); err != nil {
+				return nil, fmt.Errorf("failed to escape cell %s%s: %w", xlsxColumn(c), rowNumber, err)
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData>
+</worksheet>`)
+	return b.Bytes(), nil
+}
+
+// xlsxColumn converts a zero-based column index to its letter name: 0 is A, 26 is AA
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
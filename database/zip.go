@@ -2,59 +2,218 @@ package database
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"signal-from-noise/assert"
 	"signal-from-noise/logging"
 )
 
-// CreateZipFile creates a zip file containing the specified files
-// Returns the path to the created zip file
-// Assumption: At least one file ID is provided
-// Assumption: All file IDs exist in database
+// DefaultBatesPrefix numbers productions that do not name a prefix
+const DefaultBatesPrefix = "DOI"
+
+// ProductionSettings controls how a production is numbered and packaged
+// Padding and start number belong to the prefix's BatesSequence, so they persist across productions
+type ProductionSettings struct {
+	BatesPrefix     string   `json:"bates_prefix"`      // Defaults to DefaultBatesPrefix
+	VolumeSizeLimit int64    `json:"volume_size_limit"` // Bytes per volume zip, load files included; 0 for one volume
+	LoadFileFields  []string `json:"load_file_fields"`  // DAT columns in order; defaults to DefaultDATFields
+	Custodian       string   `json:"custodian"`         // Custodian field for every document in the DAT
+	EDRMXML         bool     `json:"edrm_xml"`          // Also write an EDRM XML 2.0 load file per volume
+	// Privileged documents are always slip-sheeted; WithholdFamilies extends that to their
+	// family members, TechnicalSlipSheets to files DetectTechnicalIssues finds cannot be
+	// converted, and SlipSheets adds others with the reason printed on the sheet
+	WithholdFamilies    bool             `json:"withhold_families"`
+	TechnicalSlipSheets bool             `json:"technical_slip_sheets"`
+	SlipSheets          map[int64]string `json:"slip_sheets"`
+	// Encrypt writes every volume entry with AES-256 under Password, generated when empty
+	// The password itself is never recorded, only the seed and check value derived with it
+	Encrypt        bool   `json:"encrypt"`
+	Password       string `json:"-"`
+	EncryptionSeed string `json:"encryption_seed,omitempty"`
+	PasswordCheck  string `json:"password_check,omitempty"`
+}
+
+// datFields returns the DAT columns, falling back to DefaultDATFields
+func (s ProductionSettings) datFields() []string {
+	if len(s.LoadFileFields) == 0 {
+		return DefaultDATFields
+	}
+	return s.LoadFileFields
+}
+
+// ProducedDocument is one document's place in a production
+type ProducedDocument struct {
+	FileID         int64     `json:"file_id"`
+	Path           string    `json:"path"` // Original data lake path
+	Date           time.Time `json:"date"`
+	BegBates       string    `json:"beg_bates"`
+	EndBates       string    `json:"end_bates"`
+	Pages          int64     `json:"pages"`
+	PagesEstimated bool      `json:"pages_estimated,omitempty"` // Pages counted without reading the PDF page tree
+	Volume         string    `json:"volume"`                    // VOL001
+	NativePath     string    `json:"native_path"`               // Entry name, e.g. VOL001/NATIVES/DOI0000001-DOI0000003.pdf
+	Size           int64     `json:"size"`
+	SHA256         string    `json:"sha256"`
+	MD5            string    `json:"md5"`
+	TextPath       string    `json:"text_path"`            // Extracted text entry; empty when the document has none
+	SlipSheet      string    `json:"slip_sheet,omitempty"` // Reason shown on the placeholder produced instead of the native
+	Redactions     int       `json:"redactions,omitempty"` // Redactions applied to the native or text
+}
+
+// ProductionVolume is one zip of a production, sized for the delivery media
+type ProductionVolume struct {
+	Name      string `json:"name"` // VOL001
+	Path      string `json:"path"`
+	FileCount int    `json:"file_count"`
+	Size      int64  `json:"size"` // Document bytes before compression
+	BegBates  string `json:"beg_bates"`
+	EndBates  string `json:"end_bates"`
+	SHA256    string `json:"sha256"` // Of the volume zip
+}
+
+// ProductionOutput describes a written production
+type ProductionOutput struct {
+	ID                  int64              `json:"id"` // productions row
+	ProductionRequestID string             `json:"production_request_id"`
+	Directory           string             `json:"directory"` // Holds VOL001.zip, VOL002.zip, ...
+	BatesPrefix         string             `json:"bates_prefix"`
+	Settings            ProductionSettings `json:"settings"`
+	OutputHash          string             `json:"output_hash"` // See hashVolumes
+	CreatedAt           time.Time          `json:"created_at"`
+	Volumes             []ProductionVolume `json:"volumes"`
+	Documents           []ProducedDocument `json:"documents"`
+	PasswordNote        string             `json:"password_note,omitempty"` // Delivery note for an encrypted production
+}
+
+// CreateZipFile creates a Bates-numbered production of the specified files
+// Each document's bytes are streamed from sourceRoot (the data lake) and checked against
+// the indexed content hash; a *ZipVerificationError lists any missing or changed files
+// Documents are renamed to their Bates ranges and split into volume zips by settings.VolumeSizeLimit
+// With settings.Encrypt the volumes are AES-256 encrypted and the password, generated when
+// settings.Password is empty, is written to a delivery note beside the production directory
+// progress, when not nil, receives files and bytes done as documents are copied. Cancelling
+// ctx stops the production, removes its partial output and releases its Bates numbers
+// File IDs come from the frontend, so duplicates are dropped and IDs that are not in
+// the index are reported in ZipVerificationError.Unknown rather than asserted on
 // Assumption: Output directory is writable
-func (d *DB) CreateZipFile(productionRequestID string, fileIDs []int64, outputDir string) (string, error) {
+func (d *DB) CreateZipFile(ctx context.Context, productionRequestID string, fileIDs []int64, sourceRoot, outputDir string, settings ProductionSettings, progress ProgressFunc) (*ProductionOutput, error) {
 	op := logging.StartOperation("CreateZipFile", map[string]interface{}{
 		"production_request_id": productionRequestID,
 		"file_count":           len(fileIDs),
+		"source_root":          sourceRoot,
 		"output_dir":           outputDir,
+		"bates_prefix":         settings.BatesPrefix,
+		"volume_size_limit":    settings.VolumeSizeLimit,
 	})
 	defer op.EndOperation()
 
-	// ASSUMPTION: At least one file must be selected for zip creation
-	// Empty file list would create an invalid zip file
-	assert.That(len(fileIDs) > 0, "at least one file ID must be provided for zip creation")
+	// A production needs files and a request to name it after; both come from the user
+	fileIDs = uniqueIDs(fileIDs)
+	if len(fileIDs) == 0 {
+		return nil, fmt.Errorf("select at least one file to produce")
+	}
+	if productionRequestID == "" {
+		return nil, fmt.Errorf("a production request is required to name the production")
+	}
 
-	// ASSUMPTION: Production request ID must be non-empty for file naming
-	// Empty ID would create incorrectly named zip files
-	assert.That(productionRequestID != "", "production request ID must be non-empty for zip file naming")
+	if err := validateDATFields(settings.datFields()); err != nil {
+		return nil, err
+	}
+	if settings.Encrypt {
+		if err := prepareEncryption(&settings); err != nil {
+			return nil, err
+		}
+	} else {
+		settings.Password = ""
+	}
 
 	// Get files from database
-	// ASSUMPTION: All file IDs exist in database and can be retrieved
-	// If files don't exist, zip creation cannot proceed
+	// IDs may be stale (deleted since the frontend loaded them); those are reported
 	files, err := d.GetFilesByIDs(fileIDs)
 	if err != nil {
 		logging.LogError("CreateZipFile", err, map[string]interface{}{
 			"operation": "get_files_by_ids",
 			"file_count": len(fileIDs),
 		})
-		return "", fmt.Errorf("failed to get files: %w", err)
+		return nil, fmt.Errorf("failed to get files: %w", err)
 	}
 
-	// ASSUMPTION: All requested files must exist in database
-	// If some files are missing, the user selected invalid file IDs
-	assert.That(len(files) > 0, "at least one file must be found for selected IDs (database integrity check)")
-	assert.That(len(files) == len(fileIDs), "all requested file IDs must exist in database (data consistency check)")
+	if len(files) != len(fileIDs) {
+		found := make(map[int64]bool, len(files))
+		for _, f := range files {
+			found[f.ID] = true
+		}
+		unknown := &ZipVerificationError{}
+		for _, id := range fileIDs {
+			if !found[id] {
+				unknown.Unknown = append(unknown.Unknown, id)
+			}
+		}
+		return nil, unknown
+	}
 
 	logging.LogResult("GetFilesByIDs", len(files), map[string]interface{}{
 		"requested_count": len(fileIDs),
 		"found_count":     len(files),
 	})
 
+	// ASSUMPTION: Every file was hashed by IndexContentHashes when it was collected
+	// An unhashed file cannot be verified, so it is reported rather than produced
+	hashes, err := d.getContentHashes(fileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Withheld documents keep their place in the Bates sequence as slip sheets
+	redactions, err := d.getRedactionsByFile(fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	withheld, err := d.withheldDocuments(files, settings.WithholdFamilies, redactions)
+	if err != nil {
+		return nil, err
+	}
+	if settings.TechnicalSlipSheets {
+		for _, file := range files {
+			if withheld[file.ID] != "" {
+				continue
+			}
+			problem, err := technicalProblem(sourcePath(sourceRoot, file.Path))
+			if err != nil {
+				return nil, err
+			}
+			if problem != "" && problem != technicalMissing {
+				withheld[file.ID] = SlipSheetTechnical
+			}
+		}
+	}
+	inProduction := make(map[int64]bool, len(files))
+	for _, file := range files {
+		inProduction[file.ID] = true
+	}
+	for id, reason := range settings.SlipSheets {
+		if !inProduction[id] {
+			return nil, fmt.Errorf("slip sheet requested for file %d, which is not in the production", id)
+		}
+		if strings.TrimSpace(reason) == "" {
+			return nil, fmt.Errorf("slip sheet for file %d needs a reason", id)
+		}
+		withheld[id] = reason
+	}
+
 	// Ensure output directory exists
 	// ASSUMPTION: Output directory path is valid and can be created
 	// If this fails, the file system is not accessible or permissions are wrong
@@ -63,129 +222,947 @@ func (d *DB) CreateZipFile(productionRequestID string, fileIDs []int64, outputDi
 			"operation":  "create_output_directory",
 			"output_dir": outputDir,
 		})
-		return "", fmt.Errorf("failed to create output directory: %w", err)
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Create zip file name: {productionRequestID}_{timestamp}.zip
-	// ASSUMPTION: Timestamp format is valid and creates unique filenames
-	// Format ensures no collisions and sortable by creation time
-	timestamp := time.Now().Format("20060102_150405")
-	zipFileName := fmt.Sprintf("%s_%s.zip", productionRequestID, timestamp)
-	zipPath := filepath.Join(outputDir, zipFileName)
+	// Number in path order so families (msg_1.eml, then msg_1/attachment) stay contiguous
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
 
-	logging.LogCheckpoint("CreateZipFile", map[string]interface{}{
-		"zip_file_name": zipFileName,
-		"zip_path":      zipPath,
-		"files_to_add":  len(files),
-	})
-
-	// Create zip file
-	// ASSUMPTION: Output directory is writable and file can be created
-	// If this fails, disk is full or permissions are incorrect
-	zipFile, err := os.Create(zipPath)
+	// Plan pages and volumes before any Bates number is spent
+	// ASSUMPTION: Every source exists and is indexed; otherwise nothing is produced
+	textSizes, err := d.getTextSizes(fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := planProduction(ctx, files, sourceRoot, hashes, withheld, textSizes, settings.VolumeSizeLimit)
 	if err != nil {
 		logging.LogError("CreateZipFile", err, map[string]interface{}{
-			"operation": "create_zip_file",
-			"zip_path":   zipPath,
+			"operation":   "plan_production",
+			"source_root": sourceRoot,
 		})
-		return "", fmt.Errorf("failed to create zip file: %w", err)
+		return nil, err
+	}
+
+	var totalPages int64
+	for _, doc := range docs {
+		totalPages += doc.Pages
+	}
+	prefix := settings.BatesPrefix
+	if prefix == "" {
+		prefix = DefaultBatesPrefix
+	}
+	seq, err := d.reserveBates(prefix, totalPages)
+	if err != nil {
+		return nil, err
 	}
-	defer zipFile.Close()
+	assignBates(docs, seq)
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	// A panic (a failed assertion) must not spend numbers on a production that was never
+	// recorded; writeProduction removes the partial volumes on its way out
+	recorded := false
+	defer func() {
+		if r := recover(); r != nil {
+			if !recorded {
+				d.releaseBates(prefix, seq.NextNumber, totalPages)
+			}
+			panic(r)
+		}
+	}()
 
-	// Add files to zip
-	// Note: In a real implementation, we would read actual file contents
-	// For now, we'll create placeholder files with metadata
-	// ASSUMPTION: All files can be added to zip without errors
-	// If any file fails, the zip is corrupted
+	// Stream each document from the data lake into its volumes, then record the production
+	// A failed production is removed and its Bates numbers released so it is never
+	// mistaken for a delivered one
+	output := &ProductionOutput{
+		ProductionRequestID: productionRequestID,
+		BatesPrefix:         prefix,
+		Settings:            settings,
+		Documents:           docs,
+		CreatedAt:           time.Now().UTC().Truncate(time.Second),
+	}
+	logging.LogCheckpoint("CreateZipFile", map[string]interface{}{
+		"files_to_add": len(docs),
+		"pages":        totalPages,
+		"beg_bates":    docs[0].BegBates,
+		"end_bates":    docs[len(docs)-1].EndBates,
+	})
+	if err := d.writeProduction(ctx, output, files, hashes, sourceRoot, outputDir, progress); err != nil {
+		d.releaseBates(prefix, seq.NextNumber, totalPages)
+		logging.LogError("CreateZipFile", err, map[string]interface{}{
+			"operation":  "write_production",
+			"output_dir": outputDir,
+		})
+		return nil, err
+	}
+	if err := checkVolumeSizes(output.Volumes, settings.VolumeSizeLimit); err != nil {
+		os.RemoveAll(output.Directory)
+		d.releaseBates(prefix, seq.NextNumber, totalPages)
+		return nil, err
+	}
+	if settings.Encrypt {
+		if output.PasswordNote, err = writePasswordNote(output); err != nil {
+			os.RemoveAll(output.Directory)
+			d.releaseBates(prefix, seq.NextNumber, totalPages)
+			return nil, err
+		}
+	}
+	if output.ID, err = d.recordProduction(output, sourceRoot); err != nil {
+		os.RemoveAll(output.Directory)
+		os.Remove(output.PasswordNote)
+		d.releaseBates(prefix, seq.NextNumber, totalPages)
+		return nil, err
+	}
+	recorded = true
+	productionDir := output.Directory
+
+	// ASSUMPTION: All files were successfully added to a volume
+	// If count doesn't match, some files failed silently
 	filesAdded := 0
+	for _, volume := range output.Volumes {
+		filesAdded += volume.FileCount
+	}
+	assert.That(filesAdded == len(files), "all files must be added to zip (zip integrity check)")
+
+	op.EndOperationWithResult(map[string]interface{}{
+		"production_dir": productionDir,
+		"volumes":        len(output.Volumes),
+		"files_added":    filesAdded,
+		"total_size":     d.calculateTotalSize(files),
+		"encrypted":      settings.Encrypt,
+		"success":        true,
+	})
+
+	return output, nil
+}
+
+// writeProduction writes output's planned documents as volume zips under outputDir
+// Everything in the zips derives from output and the index, including timestamps taken
+// from output.CreatedAt, so the same record written twice gives byte-identical volumes
+// Fills in Directory, Volumes and OutputHash; removes the directory on failure or cancellation
+func (d *DB) writeProduction(ctx context.Context, output *ProductionOutput, files []File, hashes map[int64]string, sourceRoot, outputDir string, progress ProgressFunc) error {
+	// Create production directory: {productionRequestID}_{timestamp}
+	// ASSUMPTION: Timestamp format is valid and creates unique names
+	// Mkdir (not MkdirAll) fails on a same-second retry instead of overwriting an earlier production
+	timestamp := output.CreatedAt.Format("20060102_150405")
+	output.Directory = filepath.Join(outputDir, fmt.Sprintf("%s_%s", output.ProductionRequestID, timestamp))
+	if err := os.Mkdir(output.Directory, 0755); err != nil {
+		return fmt.Errorf("failed to create production directory: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			os.RemoveAll(output.Directory)
+			panic(r)
+		}
+	}()
+
+	writer := &productionWriter{
+		productionRequestID: output.ProductionRequestID,
+		sourceRoot:          sourceRoot,
+		hashes:              hashes,
+		files:               make(map[int64]File, len(files)),
+		fields:              output.Settings.datFields(),
+		custodian:           output.Settings.Custodian,
+		text:                d.getExtractedText,
+		createdAt:           output.CreatedAt,
+		ctx:                 ctx,
+		progress:            newProgressTracker(output.Documents, progress),
+		problems:            &ZipVerificationError{},
+	}
+	fileIDs := make([]int64, len(files))
+	for i, file := range files {
+		writer.files[file.ID] = file
+		fileIDs[i] = file.ID
+	}
+
+	var err error
+	if output.Settings.Encrypt {
+		writer.password = output.Settings.Password
+		writer.seed, err = hex.DecodeString(output.Settings.EncryptionSeed)
+	}
+	if err == nil {
+		writer.redactions, err = d.getRedactionsByFile(fileIDs)
+	}
+	if err == nil && output.Settings.EDRMXML {
+		writer.edrm = true
+		writer.tags, err = d.getFileTagNames(fileIDs)
+	}
+	if err == nil {
+		output.Volumes, err = writer.writeVolumes(output.Directory, output.Documents)
+	}
+	if err == nil {
+		output.OutputHash, err = hashVolumes(output.Volumes)
+	}
+	if err != nil {
+		os.RemoveAll(output.Directory)
+		return err
+	}
+	return nil
+}
+
+// hashVolumes records each volume zip's SHA-256 and returns the production's output hash,
+// the SHA-256 of one "name sha256" line per volume
+func hashVolumes(volumes []ProductionVolume) (string, error) {
+	hasher := sha256.New()
+	for i := range volumes {
+		sum, err := hashFile(volumes[i].Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", volumes[i].Name, err)
+		}
+		volumes[i].SHA256 = sum
+		fmt.Fprintf(hasher, "%s %s\n", volumes[i].Name, sum)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// ZipVerificationError reports documents whose source bytes do not match the index
+// Paths are data lake relative so they can be handed straight to whoever manages the drive
+type ZipVerificationError struct {
+	Missing   []string `json:"missing"`   // Not found under the data lake root
+	Changed   []string `json:"changed"`   // SHA-256 differs from the indexed content hash
+	Unindexed []string `json:"unindexed"` // No content hash indexed to verify against
+	Unknown   []int64  `json:"unknown"`   // Requested file IDs that are not in the index
+}
+
+func (e *ZipVerificationError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing: %s", len(e.Missing), strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Changed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d changed: %s", len(e.Changed), strings.Join(e.Changed, ", ")))
+	}
+	if len(e.Unindexed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d without an indexed hash: %s", len(e.Unindexed), strings.Join(e.Unindexed, ", ")))
+	}
+	if len(e.Unknown) > 0 {
+		ids := make([]string, len(e.Unknown))
+		for i, id := range e.Unknown {
+			ids[i] = fmt.Sprint(id)
+		}
+		parts = append(parts, fmt.Sprintf("%d file IDs not in the index: %s", len(e.Unknown), strings.Join(ids, ", ")))
+	}
+	return "source files do not match the index (" + strings.Join(parts, "; ") + ")"
+}
+
+// empty reports whether no problems were recorded
+func (e *ZipVerificationError) empty() bool {
+	return len(e.Missing) == 0 && len(e.Changed) == 0 && len(e.Unindexed) == 0 && len(e.Unknown) == 0
+}
+
+// uniqueIDs returns ids without duplicates, keeping the first occurrence of each
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// volumeReserve is kept free in every volume for the load files, manifest and central directory
+// volumeEntryOverhead is added per document for its zip headers, text entry and load file rows
+const (
+	volumeReserve       = 64 << 10
+	volumeEntryOverhead = 4 << 10
+)
+
+// planProduction lays out documents in order, counting pages and splitting volumes
+// Each document is sized as its native plus its extracted text and volumeEntryOverhead,
+// within a limit less volumeReserve. A volume closes before the document that would take
+// it past the limit; a document larger than the limit gets a volume to itself. A limit of
+// 0 keeps everything in VOL001
+// Withheld documents become one-page slip sheets and are not read from the data lake
+func planProduction(ctx context.Context, files []File, sourceRoot string, hashes map[int64]string, withheld map[int64]string, textSizes map[int64]int64, limit int64) ([]ProducedDocument, error) {
+	problems := &ZipVerificationError{}
+	docs := make([]ProducedDocument, 0, len(files))
+	volume, volumeSize := 1, int64(0)
+
 	for _, file := range files {
 		// ASSUMPTION: File path is valid for zip entry creation
 		// Invalid paths would cause zip creation to fail
 		assert.That(file.Path != "", "file path must be non-empty for zip entry creation")
 
-		// Create a file entry in the zip
-		fileWriter, err := zipWriter.Create(file.Path)
+		// Counting pages reads each document, which is slow enough on a USB drive to cancel
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		doc := ProducedDocument{
+			FileID:    file.ID,
+			Path:      file.Path,
+			Date:      file.Date,
+			Pages:     1,
+			SlipSheet: withheld[file.ID],
+		}
+		if doc.SlipSheet != "" {
+			// Sized with a full-width Bates number; the real one is assigned later
+			doc.Size = int64(len(SlipSheetPDF(strings.Repeat("0", maxBatesPadding+8), doc.SlipSheet)))
+		} else {
+			src := sourcePath(sourceRoot, file.Path)
+			info, err := os.Stat(src)
+			if err != nil || info.IsDir() {
+				problems.Missing = append(problems.Missing, file.Path)
+				continue
+			}
+			if hashes[file.ID] == "" {
+				problems.Unindexed = append(problems.Unindexed, file.Path)
+				continue
+			}
+			if doc.Pages, doc.PagesEstimated, err = pageCount(src); err != nil {
+				return nil, fmt.Errorf("failed to count pages of %s: %w", file.Path, err)
+			}
+			doc.Size = info.Size()
+		}
+
+		footprint := doc.Size + textSizes[file.ID] + volumeEntryOverhead
+		if limit > 0 && volumeSize > 0 && volumeSize+footprint > limit-volumeReserve {
+			volume++
+			volumeSize = 0
+		}
+		volumeSize += footprint
+		doc.Volume = volumeName(volume)
+		docs = append(docs, doc)
+	}
+	if !problems.empty() {
+		return nil, problems
+	}
+	return docs, nil
+}
+
+// checkVolumeSizes measures the written volume zips against limit
+// Planning only estimates what the text, load files and zip structure add, so a volume
+// that still overflows fails the production; a single oversized document is allowed
+func checkVolumeSizes(volumes []ProductionVolume, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	for _, v := range volumes {
+		info, err := os.Stat(v.Path)
 		if err != nil {
-			logging.LogError("CreateZipFile", err, map[string]interface{}{
-				"operation": "create_zip_entry",
-				"file_path": file.Path,
-			})
-			return "", fmt.Errorf("failed to create file in zip: %w", err)
-		}
-
-		// Write file metadata as content (in real app, this would be actual file content)
-		// ASSUMPTION: File date can be formatted as RFC3339
-		// If formatting fails, the date structure is invalid
-		metadata := fmt.Sprintf(`File: %s
-Directory: %s
-Category: %s
-Date: %s
-Size: %d bytes
-Privileged: %v
-Duplicate Hash: %s
-`, file.FileName, file.Directory, file.Category, file.Date.Format(time.RFC3339), file.Size, file.Privileged, file.DuplicateHash)
-
-		if _, err := fileWriter.Write([]byte(metadata)); err != nil {
-			logging.LogError("CreateZipFile", err, map[string]interface{}{
-				"operation": "write_file_to_zip",
-				"file_path": file.Path,
+			return fmt.Errorf("failed to measure %s: %w", v.Name, err)
+		}
+		if v.FileCount > 1 && info.Size() > limit {
+			return fmt.Errorf("%s is %d bytes, over the %d byte volume size limit; lower the limit and produce again",
+				v.Name, info.Size(), limit)
+		}
+	}
+	return nil
+}
+
+// volumeName formats a volume number as VOL001
+func volumeName(number int) string {
+	return fmt.Sprintf("VOL%03d", number)
+}
+
+// assignBates numbers documents consecutively from the reserved sequence and names
+// each native after its Bates range; slip sheets are named as PDFs
+func assignBates(docs []ProducedDocument, seq BatesSequence) {
+	next := seq.NextNumber
+	for i := range docs {
+		doc := &docs[i]
+		doc.BegBates = FormatBates(seq.Prefix, seq.Padding, next)
+		doc.EndBates = FormatBates(seq.Prefix, seq.Padding, next+doc.Pages-1)
+		next += doc.Pages
+
+		name := doc.BegBates
+		if doc.EndBates != doc.BegBates {
+			name += "-" + doc.EndBates
+		}
+		ext := strings.ToLower(filepath.Ext(doc.Path))
+		if doc.SlipSheet != "" {
+			ext = ".pdf"
+			doc.Size = int64(len(SlipSheetPDF(doc.BegBates, doc.SlipSheet)))
+		}
+		doc.NativePath = doc.Volume + "/NATIVES/" + name + ext
+	}
+}
+
+// productionWriter carries what every volume of one production needs
+type productionWriter struct {
+	productionRequestID string
+	sourceRoot          string
+	hashes              map[int64]string // Indexed content hash by file ID
+	files               map[int64]File   // Metadata for the load files
+	fields              []string         // DAT columns
+	custodian           string
+	text                func(fileID int64) (string, error) // Extracted text for TEXT/
+	createdAt           time.Time                          // Timestamp for generated entries
+	edrm                bool                               // Write EDRM XML alongside the DAT
+	tags                map[int64][]string                 // Review tag names for EDRM XML
+	redactions          map[int64][]Redaction              // Applied to PDF natives and to text
+	password            string                             // Encrypts every entry when set
+	seed                []byte                             // Derives encrypted entries' salts
+	ctx                 context.Context                    // Cancels the production between and within documents
+	progress            *progressTracker
+	problems            *ZipVerificationError
+}
+
+// writeVolumes writes one zip per volume into productionDir and verifies each one
+// Every document is still read after a mismatch so the caller gets the complete list
+func (pw *productionWriter) writeVolumes(productionDir string, docs []ProducedDocument) ([]ProductionVolume, error) {
+	problems := pw.problems
+	var volumes []ProductionVolume
+
+	for start := 0; start < len(docs); {
+		end := start
+		for end < len(docs) && docs[end].Volume == docs[start].Volume {
+			end++
+		}
+		if err := pw.ctx.Err(); err != nil {
+			return nil, err
+		}
+		pw.progress.startVolume(docs[start].Volume)
+		volume := ProductionVolume{
+			Name:      docs[start].Volume,
+			Path:      filepath.Join(productionDir, docs[start].Volume+".zip"),
+			FileCount: end - start,
+			BegBates:  docs[start].BegBates,
+			EndBates:  docs[end-1].EndBates,
+		}
+
+		// ASSUMPTION: Production directory is writable and the volume can be created
+		// If this fails, disk is full or permissions are incorrect
+		zipFile, err := os.Create(volume.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zip file: %w", err)
+		}
+		copied, writeErr := pw.writeZipEntries(zipFile, volume.Name, docs[start:end])
+		if closeErr := zipFile.Close(); writeErr == nil && closeErr != nil {
+			writeErr = fmt.Errorf("failed to close zip file: %w", closeErr)
+		}
+		if writeErr == nil {
+			writeErr = pw.ctx.Err()
+		}
+		if writeErr == nil && problems.empty() {
+			// Re-read the finished archive so what was written, not what was read, is verified
+			writeErr = verifyZipEntries(volume.Path, copied, pw.password)
+		}
+		if writeErr != nil {
+			return nil, writeErr
+		}
+
+		// Sized after writing, since redaction changes the size of a native
+		for _, doc := range docs[start:end] {
+			volume.Size += doc.Size
+		}
+		volumes = append(volumes, volume)
+		start = end
+	}
+	if !problems.empty() {
+		return nil, problems
+	}
+	return volumes, nil
+}
+
+// zipManifestEntry records one produced document in manifest.json
+type zipManifestEntry struct {
+	BegBates       string `json:"beg_bates"`
+	EndBates       string `json:"end_bates"`
+	NativePath     string `json:"native_path"`
+	SourcePath     string `json:"source_path,omitempty"` // Omitted for slip sheets
+	Size           int64  `json:"size"`
+	SHA256         string `json:"sha256"`
+	SlipSheet      string `json:"slip_sheet,omitempty"`
+	Redactions     int    `json:"redactions,omitempty"`
+	PagesEstimated bool   `json:"pages_estimated,omitempty"` // The Bates range may not match the page count
+}
+
+// writeZipEntries copies one volume's documents into the archive, then writes their
+// extracted text, the DAT (and OPT when images are present) and manifest.json
+// Mismatches against the index are added to pw.problems; returns the SHA-256 of each
+// native keyed by entry name
+func (pw *productionWriter) writeZipEntries(w io.Writer, volume string, docs []ProducedDocument) (map[string]string, error) {
+	problems := pw.problems
+	zipWriter := newVolumeZip(w, pw.password, pw.seed)
+	copied := make(map[string]string, len(docs))
+	entries := make(map[string]bool) // Every document entry, for EDRM reference checks
+	var manifestFiles []zipManifestEntry
+	var totalSize int64
+
+	for i := range docs {
+		if err := pw.ctx.Err(); err != nil {
+			return nil, err
+		}
+		doc := &docs[i]
+		if doc.SlipSheet != "" {
+			// The placeholder stands in for the native; nothing of the withheld document is read
+			slip := SlipSheetPDF(doc.BegBates, doc.SlipSheet)
+			size, sum, md5sum, err := copyReaderIntoZip(zipWriter, doc.NativePath, doc.Date, pw.track(bytes.NewReader(slip)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to write slip sheet %s: %w", doc.BegBates, err)
+			}
+			doc.Size, doc.SHA256, doc.MD5 = size, sum, md5sum
+			copied[doc.NativePath] = sum
+			entries[doc.NativePath] = true
+			manifestFiles = append(manifestFiles, zipManifestEntry{
+				BegBates:   doc.BegBates,
+				EndBates:   doc.EndBates,
+				NativePath: doc.NativePath,
+				Size:       size,
+				SHA256:     sum,
+				SlipSheet:  doc.SlipSheet,
 			})
-			return "", fmt.Errorf("failed to write file to zip: %w", err)
+			totalSize += size
+			if doc.SlipSheet != SlipSheetRedacted {
+				pw.progress.fileDone()
+				continue
+			}
+
+			// The native is withheld but its text goes out with the redactions applied
+			text, err := pw.text(doc.FileID)
+			if err != nil {
+				return nil, err
+			}
+			redactions := pw.redactions[doc.FileID]
+			if text, err = redactText(text, redactions); err != nil {
+				return nil, fmt.Errorf("failed to redact text of %s: %w", doc.Path, err)
+			}
+			doc.Redactions = len(redactions)
+			manifestFiles[len(manifestFiles)-1].Redactions = doc.Redactions
+			if err := writeDocumentText(zipWriter, volume, doc, text, pw.createdAt, entries); err != nil {
+				return nil, err
+			}
+			pw.progress.fileDone()
+			continue
+		}
+
+		var size int64
+		var sum, md5sum, redactedText string
+		var err error
+		src := sourcePath(pw.sourceRoot, doc.Path)
+		redactions := pw.redactions[doc.FileID]
+		if len(redactions) == 0 {
+			size, sum, md5sum, err = pw.copyIntoZip(zipWriter, doc.NativePath, doc.Date, src)
+			if !os.IsNotExist(err) && err != nil {
+				return nil, fmt.Errorf("failed to write %s to zip: %w", doc.Path, err)
+			}
+		} else {
+			// Verify the source as indexed before redacting, since the redacted native
+			// can no longer be compared with the index
+			if !isPDFPath(doc.Path) {
+				return nil, fmt.Errorf("%s has redactions but only PDFs are redacted in their native form", doc.Path)
+			}
+			var data []byte
+			data, err = os.ReadFile(src)
+			if !os.IsNotExist(err) && err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", doc.Path, err)
+			}
+			if err == nil {
+				pw.progress.addBytes(int64(len(data)))
+				indexed := sha256.Sum256(data)
+				if !strings.EqualFold(hex.EncodeToString(indexed[:]), pw.hashes[doc.FileID]) {
+					problems.Changed = append(problems.Changed, doc.Path)
+					pw.progress.fileDone()
+					continue
+				}
+				if data, err = redactPDF(data, redactions); err != nil {
+					return nil, fmt.Errorf("failed to redact %s: %w", doc.Path, err)
+				}
+				pages, err := pdfPageTexts(data)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read redacted %s: %w", doc.Path, err)
+				}
+				texts := make([]string, len(pages))
+				for i, page := range pages {
+					texts[i] = latin1String(page)
+				}
+				redactedText = strings.Join(texts, "\f")
+				if size, sum, md5sum, err = copyReaderIntoZip(zipWriter, doc.NativePath, doc.Date, bytes.NewReader(data)); err != nil {
+					return nil, fmt.Errorf("failed to write %s to zip: %w", doc.Path, err)
+				}
+				doc.Redactions = len(redactions)
+			}
+		}
+		if os.IsNotExist(err) {
+			// Removed after the production was planned
+			problems.Missing = append(problems.Missing, doc.Path)
+			pw.progress.fileDone()
+			continue
+		}
+		if doc.Redactions == 0 && !strings.EqualFold(sum, pw.hashes[doc.FileID]) {
+			problems.Changed = append(problems.Changed, doc.Path)
+		}
+		doc.Size = size
+		doc.SHA256 = sum
+		doc.MD5 = md5sum
+		entries[doc.NativePath] = true
+		copied[doc.NativePath] = sum
+		manifestFiles = append(manifestFiles, zipManifestEntry{
+			BegBates:       doc.BegBates,
+			EndBates:       doc.EndBates,
+			NativePath:     doc.NativePath,
+			SourcePath:     doc.Path,
+			Size:           size,
+			SHA256:         sum,
+			Redactions:     doc.Redactions,
+			PagesEstimated: doc.PagesEstimated,
+		})
+		totalSize += size
+
+		// Extracted text travels with the native so the receiving platform can search it
+		// A redacted PDF's text is read back from the redacted native, so nothing removed
+		// from the page survives in TEXT/
+		text := redactedText
+		if doc.Redactions == 0 {
+			if text, err = pw.text(doc.FileID); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeDocumentText(zipWriter, volume, doc, text, pw.createdAt, entries); err != nil {
+			return nil, err
 		}
-		filesAdded++
+		pw.progress.fileDone()
 	}
 
-	// ASSUMPTION: All files were successfully added to zip
-	// If count doesn't match, some files failed silently
-	assert.That(filesAdded == len(files), "all files must be added to zip (zip integrity check)")
+	// Write load files
+	// ASSUMPTION: Load files describe only the documents in this volume
+	// Each volume can then be loaded on its own as it arrives
+	var dat bytes.Buffer
+	if err := writeDAT(&dat, pw.fields, docs, pw.files, pw.custodian); err != nil {
+		return nil, err
+	}
+	if err := writeZipText(zipWriter, volume+"/DATA/"+volume+".dat", pw.createdAt, dat.Bytes()); err != nil {
+		return nil, err
+	}
+	if hasImages(docs) {
+		var opt bytes.Buffer
+		if err := writeOPT(&opt, docs); err != nil {
+			return nil, err
+		}
+		if err := writeZipText(zipWriter, volume+"/DATA/"+volume+".opt", pw.createdAt, opt.Bytes()); err != nil {
+			return nil, err
+		}
+	}
 
-	logging.LogCheckpoint("CreateZipFile", map[string]interface{}{
-		"files_added": filesAdded,
-		"total_files": len(files),
-	})
+	// EDRM XML is checked against the 2.0 structure and the volume's entries before it is packaged
+	// (skipped once a mismatch has doomed the production, so the mismatch is what gets reported)
+	if pw.edrm && problems.empty() {
+		edrm, err := buildEDRM(volume, pw.productionRequestID, docs, pw.files, pw.tags, pw.custodian)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateEDRM(edrm, entries); err != nil {
+			return nil, fmt.Errorf("EDRM XML for %s failed validation: %w", volume, err)
+		}
+		if err := writeZipText(zipWriter, volume+"/DATA/"+volume+".xml", pw.createdAt, edrm); err != nil {
+			return nil, err
+		}
+	}
 
 	// Create manifest file
-	// ASSUMPTION: Manifest entry can be created in zip
-	// If this fails, zip structure is corrupted
-	manifestWriter, err := zipWriter.Create("manifest.json")
+	// Lists each entry's hash so recipients can check the production independently
+	manifest, err := json.MarshalIndent(map[string]interface{}{
+		"production_request_id": pw.productionRequestID,
+		"volume":                volume,
+		"created_at":            pw.createdAt.Format(time.RFC3339),
+		"file_count":            len(manifestFiles),
+		"total_size":            totalSize,
+		"files":                 manifestFiles,
+	}, "", "  ")
 	if err != nil {
-		logging.LogError("CreateZipFile", err, map[string]interface{}{
-			"operation": "create_manifest_entry",
-		})
-		return "", fmt.Errorf("failed to create manifest: %w", err)
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeZipText(zipWriter, volume+"/manifest.json", pw.createdAt, manifest); err != nil {
+		return nil, err
 	}
 
-	totalSize := d.calculateTotalSize(files)
-	manifest := fmt.Sprintf(`{
-  "production_request_id": "%s",
-  "created_at": "%s",
-  "file_count": %d,
-  "total_size": %d
-}`, productionRequestID, time.Now().Format(time.RFC3339), len(files), totalSize)
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish zip file: %w", err)
+	}
+	return copied, nil
+}
 
-	if _, err := manifestWriter.Write([]byte(manifest)); err != nil {
-		logging.LogError("CreateZipFile", err, map[string]interface{}{
-			"operation": "write_manifest",
-		})
-		return "", fmt.Errorf("failed to write manifest: %w", err)
+// writeDocumentText adds a document's text under TEXT/ and records the entry
+// Documents without text get no entry and keep an empty TextPath
+func writeDocumentText(zipWriter *volumeZip, volume string, doc *ProducedDocument, text string, modified time.Time, entries map[string]bool) error {
+	if text == "" {
+		return nil
+	}
+	doc.TextPath = volume + "/TEXT/" + doc.BegBates + ".txt"
+	if err := writeZipText(zipWriter, doc.TextPath, modified, []byte(text)); err != nil {
+		return err
 	}
+	entries[doc.TextPath] = true
+	return nil
+}
 
-	// ASSUMPTION: Zip file was successfully created and is accessible
-	// If file doesn't exist after creation, the operation silently failed
-	// Note: We can't check file existence here because zipWriter.Close() hasn't been called yet
-	// The defer will close it, so we verify existence would need to happen after this function
+// writeZipText adds a generated entry (text, load file, manifest) to the archive
+func writeZipText(zipWriter *volumeZip, name string, modified time.Time, data []byte) error {
+	if zipWriter.password != "" {
+		if _, _, _, err := zipWriter.copyEncrypted(name, modified, bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+	entry, err := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := entry.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// copyIntoZip streams one source file into a new zip entry, hashing the bytes as they pass
+// Returns the size, SHA-256 (for verification) and MD5 (for the DAT)
+func (pw *productionWriter) copyIntoZip(zipWriter *volumeZip, name string, modified time.Time, src string) (int64, string, string, error) {
+	source, err := os.Open(src)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer source.Close()
+	return copyReaderIntoZip(zipWriter, name, modified, pw.track(source))
+}
+
+// track counts a document's bytes toward progress and stops reading once the job is cancelled
+func (pw *productionWriter) track(r io.Reader) io.Reader {
+	return &progressReader{ctx: pw.ctx, r: r, tracker: pw.progress}
+}
+
+// copyReaderIntoZip writes r to a new zip entry, returning its size, SHA-256 and MD5
+func copyReaderIntoZip(zipWriter *volumeZip, name string, modified time.Time, source io.Reader) (int64, string, string, error) {
+	if zipWriter.password != "" {
+		return zipWriter.copyEncrypted(name, modified, source)
+	}
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	}
+	entry, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to create file in zip: %w", err)
+	}
+
+	hasher, md5Hasher := sha256.New(), md5.New()
+	size, err := io.Copy(io.MultiWriter(entry, hasher, md5Hasher), source)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to copy file contents: %w", err)
+	}
+	return size, hex.EncodeToString(hasher.Sum(nil)), hex.EncodeToString(md5Hasher.Sum(nil)), nil
+}
+
+// verifyZipEntries re-reads a finished archive and checks every entry against its expected hash
+// Encrypted entries are decrypted with password
+func verifyZipEntries(zipPath string, expected map[string]string, password string) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to reopen zip for verification: %w", err)
+	}
+	defer reader.Close()
+
+	problems := &ZipVerificationError{}
+	seen := make(map[string]bool, len(expected))
+	for _, entry := range reader.File {
+		want, ok := expected[entry.Name]
+		if !ok {
+			continue // manifest.json and other generated entries
+		}
+		seen[entry.Name] = true
+		sum, err := hashZipEntry(entry, password)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", entry.Name, err)
+		}
+		if sum != want {
+			problems.Changed = append(problems.Changed, entry.Name)
+		}
+	}
+	for path := range expected {
+		if !seen[path] {
+			problems.Missing = append(problems.Missing, path)
+		}
+	}
+	if problems.empty() {
+		return nil
+	}
+	sort.Strings(problems.Missing)
+	return problems
+}
+
+// hashZipEntry returns the hex SHA-256 of a zip entry's uncompressed contents
+func hashZipEntry(entry *zip.File, password string) (string, error) {
+	rc, err := openZipEntry(entry, password)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// getTextSizes returns the size in bytes of each file's extracted text, for volume planning
+func (d *DB) getTextSizes(fileIDs []int64) (map[int64]int64, error) {
+	sizes := make(map[int64]int64, len(fileIDs))
+	for start := 0; start < len(fileIDs); start += 500 {
+		end := start + 500
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+		chunk := fileIDs[start:end]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+
+		rows, err := d.db.Query("SELECT id, length(CAST(extracted_text AS BLOB)) FROM files WHERE extracted_text IS NOT NULL AND id IN ("+placeholders+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query text sizes: %w", err)
+		}
+		for rows.Next() {
+			var id, size int64
+			if err := rows.Scan(&id, &size); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan text size: %w", err)
+			}
+			sizes[id] = size
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating text sizes: %w", err)
+		}
+	}
+	return sizes, nil
+}
+
+// getContentHashes returns the indexed content hash for each file ID that has one
+func (d *DB) getContentHashes(fileIDs []int64) (map[int64]string, error) {
+	hashes := make(map[int64]string, len(fileIDs))
+	for start := 0; start < len(fileIDs); start += 500 {
+		end := start + 500
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+		chunk := fileIDs[start:end]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+
+		rows, err := d.db.Query("SELECT id, content_hash FROM files WHERE content_hash IS NOT NULL AND id IN ("+placeholders+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query content hashes: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			var hash string
+			if err := rows.Scan(&id, &hash); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan content hash: %w", err)
+			}
+			hashes[id] = hash
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating content hashes: %w", err)
+		}
+	}
+	return hashes, nil
+}
+
+// IndexContentHashes records the SHA-256 of each file's bytes in the data lake
+// Only files without a hash are read, so the first index is the baseline that later
+// productions are verified against. Returns the number of files hashed
+func (d *DB) IndexContentHashes(sourceRoot string) (int, error) {
+	op := logging.StartOperation("IndexContentHashes", map[string]interface{}{
+		"source_root": sourceRoot,
+	})
+	defer op.EndOperation()
+
+	// ASSUMPTION: Database connection exists
+	assert.ThatNotNil(d.db, "database connection must exist to index content hashes")
+
+	rows, err := d.db.Query("SELECT id, path FROM files WHERE content_hash IS NULL ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query unhashed files: %w", err)
+	}
+	type unhashed struct {
+		id   int64
+		path string
+	}
+	var pending []unhashed
+	for rows.Next() {
+		var f unhashed
+		if err := rows.Scan(&f.id, &f.path); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file: %w", err)
+		}
+		pending = append(pending, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating files: %w", err)
+	}
+
+	hashed, missing := 0, 0
+	for _, f := range pending {
+		sum, err := hashFile(sourcePath(sourceRoot, f.path))
+		if os.IsNotExist(err) {
+			missing++
+			continue
+		}
+		if err != nil {
+			return hashed, fmt.Errorf("failed to hash %s: %w", f.path, err)
+		}
+		if err := d.saveContentHash(f.id, f.path, sum); err != nil {
+			return hashed, err
+		}
+		hashed++
+	}
 
 	op.EndOperationWithResult(map[string]interface{}{
-		"zip_path":    zipPath,
-		"files_added": filesAdded,
-		"total_size":  totalSize,
-		"success":     true,
+		"hashed":  hashed,
+		"missing": missing,
 	})
+	return hashed, nil
+}
 
-	return zipPath, nil
+// saveContentHash records a file's indexed hash together with its audit entry
+func (d *DB) saveContentHash(fileID int64, path, sum string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE files SET content_hash = ? WHERE id = ?", sum, fileID); err != nil {
+		return fmt.Errorf("failed to save content hash: %w", err)
+	}
+	err = appendAudit(tx, AuditHash, fileID, 0, AuditActorSystem, map[string]interface{}{
+		"path":   path,
+		"sha256": sum,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hashFile returns the hex SHA-256 of a file on disk
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 // calculateTotalSize calculates the total size of files
@@ -214,3 +1191,12 @@ func CopyFile(src, dst string) error {
 	_, err = io.Copy(destFile, sourceFile)
 	return err
 }
+
+// getExtractedText returns a file's extracted text, or "" when none has been stored
+func (d *DB) getExtractedText(fileID int64) (string, error) {
+	var text sql.NullString
+	if err := d.db.QueryRow("SELECT extracted_text FROM files WHERE id = ?", fileID).Scan(&text); err != nil {
+		return "", fmt.Errorf("failed to get extracted text: %w", err)
+	}
+	return text.String, nil
+}
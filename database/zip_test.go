@@ -0,0 +1,140 @@
+package database
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// produce writes a production of ids from root into a fresh output directory
+func produce(t *testing.T, d *DB, ids []int64, root string, settings ProductionSettings) *ProductionOutput {
+	t.Helper()
+	out, err := d.CreateZipFile(context.Background(), "PR-001", ids, root, t.TempDir(), settings, nil)
+	if err != nil {
+		t.Fatalf("CreateZipFile: %v", err)
+	}
+	return out
+}
+
+func TestCreateZipFileStreamsSourceBytes(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 3)
+	content := map[int64][]byte{}
+	for i, id := range ids {
+		content[id] = onePagePDF(string(rune('A' + i)))
+	}
+	root := writeLake(t, d, content)
+
+	out := produce(t, d, ids, root, ProductionSettings{})
+	r, err := zip.OpenReader(out.Volumes[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	entries := map[string]*zip.File{}
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+	for _, doc := range out.Documents {
+		rc, err := entries[doc.NativePath].Open()
+		if err != nil {
+			t.Fatalf("%s: %v", doc.NativePath, err)
+		}
+		got, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(got) != string(content[doc.FileID]) {
+			t.Errorf("%s does not hold the source bytes", doc.NativePath)
+		}
+	}
+}
+
+func TestCreateZipFileDeduplicatesIDs(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("a"), ids[1]: onePagePDF("b")})
+
+	out := produce(t, d, []int64{ids[0], ids[1], ids[0]}, root, ProductionSettings{})
+	if len(out.Documents) != 2 {
+		t.Fatalf("got %d documents, want 2", len(out.Documents))
+	}
+}
+
+func TestCreateZipFileReportsUnknownIDs(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 1)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("a")})
+
+	_, err := d.CreateZipFile(context.Background(), "PR-001", []int64{ids[0], 999999}, root, t.TempDir(), ProductionSettings{}, nil)
+	var mismatch *ZipVerificationError
+	if !errors.As(err, &mismatch) || len(mismatch.Unknown) != 1 || mismatch.Unknown[0] != 999999 {
+		t.Fatalf("got %v, want unknown ID 999999", err)
+	}
+	if _, err := d.CreateZipFile(context.Background(), "PR-001", nil, root, t.TempDir(), ProductionSettings{}, nil); err == nil {
+		t.Error("an empty selection was accepted")
+	}
+}
+
+func TestCreateZipFileReportsChangedSources(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 2)
+	root := writeLake(t, d, map[int64][]byte{ids[0]: onePagePDF("a"), ids[1]: onePagePDF("b")})
+	f, _ := d.GetFileByID(ids[1])
+	if err := os.WriteFile(sourcePath(root, f.Path), onePagePDF("altered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := t.TempDir()
+	_, err := d.CreateZipFile(context.Background(), "PR-001", ids, root, outputDir, ProductionSettings{}, nil)
+	var mismatch *ZipVerificationError
+	if !errors.As(err, &mismatch) || len(mismatch.Changed) != 1 || mismatch.Changed[0] != f.Path {
+		t.Fatalf("got %v, want %s reported changed", err, f.Path)
+	}
+	if left, _ := filepath.Glob(filepath.Join(outputDir, "*")); len(left) != 0 {
+		t.Errorf("failed production left %v behind", left)
+	}
+}
+
+func TestVolumeSizeLimitCountsTextAndOverhead(t *testing.T) {
+	d := newTestDB(t)
+	ids := firstFileIDs(t, d, 4)
+	content := map[int64][]byte{}
+	for _, id := range ids {
+		content[id] = onePagePDF("page")
+		mustExec(t, d, "UPDATE files SET extracted_text = ? WHERE id = ?", strings.Repeat("text ", 8<<10), id)
+	}
+	root := writeLake(t, d, content)
+
+	// The natives alone would fit in one volume; their text takes two documents per volume
+	limit := int64(volumeReserve + 100<<10)
+	out := produce(t, d, ids, root, ProductionSettings{VolumeSizeLimit: limit})
+	if len(out.Volumes) != 2 {
+		t.Fatalf("got %d volumes, want 2", len(out.Volumes))
+	}
+	for _, v := range out.Volumes {
+		info, err := os.Stat(v.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > limit {
+			t.Errorf("%s is %d bytes, over the %d byte limit", v.Name, info.Size(), limit)
+		}
+	}
+}
+
+func TestCheckVolumeSizesMeasuresZips(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "VOL001.zip")
+	if err := os.WriteFile(path, make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkVolumeSizes([]ProductionVolume{{Name: "VOL001", Path: path, FileCount: 2}}, 1024); err == nil {
+		t.Error("an oversized volume of two documents passed")
+	}
+	if err := checkVolumeSizes([]ProductionVolume{{Name: "VOL001", Path: path, FileCount: 1}}, 1024); err != nil {
+		t.Errorf("a single oversized document was refused: %v", err)
+	}
+}
@@ -0,0 +1,485 @@
+package database
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encrypted volumes use WinZip AES (AE-2) with 256-bit keys, which 7-Zip, WinZip and
+// libarchive open with the password; file names stay visible, contents do not
+const (
+	zipMethodAES        = 99     // Compression method marking an AES entry
+	zipAESExtraID       = 0x9901 // Extra field carrying the real method and key strength
+	zipAESVersion       = 2      // AE-2: CRC is zero, the authentication code covers integrity
+	zipAESStrength256   = 3
+	zipAESSaltSize      = 16
+	zipAESKeySize       = 32
+	zipAESVerifierSize  = 2
+	zipAESAuthSize      = 10
+	zipAESIterations    = 1000
+	zipEncryptedFlag    = 0x1
+	zipAESReaderVersion = 51 // Version needed to extract AES entries
+)
+
+// MinProductionPasswordLength is the shortest password accepted for an encrypted production
+const MinProductionPasswordLength = 12
+
+// passwordAlphabet leaves out characters that are easy to misread (0/O, 1/l/I) when the
+// password is read aloud or copied from the delivery note
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+
+// errZipPassword reports an entry that the given password does not open
+var errZipPassword = errors.New("incorrect password")
+
+// GenerateProductionPassword returns a random password of five hyphenated groups of four
+func GenerateProductionPassword() (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		b.WriteByte(passwordAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// prepareEncryption fills in the settings an encrypted production records: a password
+// (generated when none was given), the seed entry salts derive from, and a check value
+// so re-creation and verification can reject a wrong password up front
+func prepareEncryption(settings *ProductionSettings) error {
+	if settings.Password == "" {
+		password, err := GenerateProductionPassword()
+		if err != nil {
+			return err
+		}
+		settings.Password = password
+	}
+	if len(settings.Password) < MinProductionPasswordLength {
+		return fmt.Errorf("production password must be at least %d characters", MinProductionPasswordLength)
+	}
+	seed := make([]byte, zipAESSaltSize)
+	if _, err := rand.Read(seed); err != nil {
+		return fmt.Errorf("failed to generate encryption seed: %w", err)
+	}
+	settings.EncryptionSeed = hex.EncodeToString(seed)
+	settings.PasswordCheck = passwordCheck(settings.Password, seed)
+	return nil
+}
+
+// The recorded check value is "pbkdf2-sha256$<iterations>$<hex key>"
+// passwordCheckIterations follows current guidance for stored password hashes
+const (
+	passwordCheckScheme     = "pbkdf2-sha256"
+	passwordCheckIterations = 600000
+)
+
+// passwordCheck derives the recorded check value for a production password
+// The value confirms a guessed password outright, so anyone with a copy of the database
+// can test guesses offline. A slow KDF makes each guess cost far more than against the
+// volumes, whose WinZip AES keys are fixed at zipAESIterations
+func passwordCheck(password string, seed []byte) string {
+	key := pbkdf2Key(sha256.New, []byte(password), seed, passwordCheckIterations, sha256.Size)
+	return fmt.Sprintf("%s$%d$%s", passwordCheckScheme, passwordCheckIterations, hex.EncodeToString(key))
+}
+
+// matchesPasswordCheck reports whether password derives the recorded check value
+func matchesPasswordCheck(password string, seed []byte, check string) (bool, error) {
+	scheme, rest, found := strings.Cut(check, "$")
+	if !found {
+		return false, fmt.Errorf("unrecognized password check")
+	}
+	count, want, _ := strings.Cut(rest, "$")
+	iterations, err := strconv.Atoi(count)
+	if scheme != passwordCheckScheme || err != nil || iterations < 1 {
+		return false, fmt.Errorf("unrecognized password check %q", scheme)
+	}
+	key := pbkdf2Key(sha256.New, []byte(password), seed, iterations, sha256.Size)
+	return hmac.Equal([]byte(hex.EncodeToString(key)), []byte(want)), nil
+}
+
+// checkProductionPassword confirms password opens a recorded encrypted production
+func checkProductionPassword(id int64, settings ProductionSettings, password string) error {
+	if password == "" {
+		return fmt.Errorf("production %d is encrypted; its password is required", id)
+	}
+	seed, err := hex.DecodeString(settings.EncryptionSeed)
+	if err != nil {
+		return fmt.Errorf("production %d has an invalid encryption seed: %w", id, err)
+	}
+	ok, err := matchesPasswordCheck(password, seed, settings.PasswordCheck)
+	if err != nil {
+		return fmt.Errorf("production %d: %w", id, err)
+	}
+	if !ok {
+		return fmt.Errorf("production %d: %w", id, errZipPassword)
+	}
+	return nil
+}
+
+// writePasswordNote writes the password delivery note beside (not inside) the production
+// directory, so the password can be sent by a different channel than the volumes
+func writePasswordNote(output *ProductionOutput) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "PASSWORD DELIVERY NOTE\n\n")
+	fmt.Fprintf(&b, "Production request: %s\n", output.ProductionRequestID)
+	fmt.Fprintf(&b, "Bates range:        %s - %s\n", output.Documents[0].BegBates, output.Documents[len(output.Documents)-1].EndBates)
+	fmt.Fprintf(&b, "Produced:           %s\n", output.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Encryption:         AES-256 zip (WinZip AE-2); open with 7-Zip, WinZip or another AES-capable tool\n\n")
+	fmt.Fprintf(&b, "Password:           %s\n\n", output.Settings.Password)
+	fmt.Fprintf(&b, "Volumes:\n")
+	for _, v := range output.Volumes {
+		fmt.Fprintf(&b, "  %s.zip  %s - %s  SHA-256 %s\n", v.Name, v.BegBates, v.EndBates, v.SHA256)
+	}
+	fmt.Fprintf(&b, "\nSend this note separately from the production media, by a different channel.\n")
+
+	path := output.Directory + "_PASSWORD.txt"
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return "", fmt.Errorf("failed to write password note %s: %w", filepath.Base(path), err)
+	}
+	return path, nil
+}
+
+// volumeZip is a volume archive being written; every entry is AES-256 encrypted when
+// password is set
+type volumeZip struct {
+	*zip.Writer
+	password string
+	seed     []byte // Entry salts derive from it, so re-created volumes are byte-identical
+}
+
+// newVolumeZip starts a volume archive on w, encrypted when password is not empty
+func newVolumeZip(w io.Writer, password string, seed []byte) *volumeZip {
+	return &volumeZip{Writer: zip.NewWriter(w), password: password, seed: seed}
+}
+
+// entrySalt derives an entry's salt from the production's seed and the entry name
+func (vz *volumeZip) entrySalt(name string) []byte {
+	mac := hmac.New(sha256.New, vz.seed)
+	mac.Write([]byte(name))
+	return mac.Sum(nil)[:zipAESSaltSize]
+}
+
+// copyEncrypted deflates and encrypts source into a new AE-2 entry, returning the
+// plaintext's size, SHA-256 and MD5
+// The entry is staged in a temporary file because its sizes precede its data
+func (vz *volumeZip) copyEncrypted(name string, modified time.Time, source io.Reader) (int64, string, string, error) {
+	staged, err := os.CreateTemp("", "production-entry-*")
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to stage encrypted entry: %w", err)
+	}
+	defer os.Remove(staged.Name())
+	defer staged.Close()
+
+	salt := vz.entrySalt(name)
+	encKey, authKey, verifier := zipAESKeys(vz.password, salt)
+	if _, err := staged.Write(append(append([]byte{}, salt...), verifier...)); err != nil {
+		return 0, "", "", fmt.Errorf("failed to stage encrypted entry: %w", err)
+	}
+	encrypter, err := newZipAESWriter(staged, encKey, authKey)
+	if err != nil {
+		return 0, "", "", err
+	}
+	compressor, err := flate.NewWriter(encrypter, flate.DefaultCompression)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to compress %s: %w", name, err)
+	}
+	hasher, md5Hasher := sha256.New(), md5.New()
+	size, err := io.Copy(io.MultiWriter(compressor, hasher, md5Hasher), source)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to copy file contents: %w", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return 0, "", "", fmt.Errorf("failed to compress %s: %w", name, err)
+	}
+	if _, err := staged.Write(encrypter.mac.Sum(nil)[:zipAESAuthSize]); err != nil {
+		return 0, "", "", fmt.Errorf("failed to stage encrypted entry: %w", err)
+	}
+	compressed, err := staged.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = staged.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to rewind encrypted entry: %w", err)
+	}
+
+	header := &zip.FileHeader{
+		Name:               name,
+		Method:             zipMethodAES,
+		Flags:              zipEncryptedFlag,
+		CreatorVersion:     zipAESReaderVersion,
+		ReaderVersion:      zipAESReaderVersion,
+		Modified:           modified,
+		CompressedSize64:   uint64(compressed),
+		UncompressedSize64: uint64(size),
+		Extra:              zipAESExtra(zip.Deflate, modified),
+	}
+	header.ModifiedDate, header.ModifiedTime = msDosTime(modified)
+	entry, err := vz.CreateRaw(header)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to create file in zip: %w", err)
+	}
+	if _, err := io.Copy(entry, staged); err != nil {
+		return 0, "", "", fmt.Errorf("failed to copy encrypted entry: %w", err)
+	}
+	return size, hex.EncodeToString(hasher.Sum(nil)), hex.EncodeToString(md5Hasher.Sum(nil)), nil
+}
+
+// zipAESExtra builds the AES extra field naming the entry's real method, followed by
+// the extended timestamp archive/zip adds to its own entries
+func zipAESExtra(method uint16, modified time.Time) []byte {
+	extra := make([]byte, 0, 11+9)
+	extra = binary.LittleEndian.AppendUint16(extra, zipAESExtraID)
+	extra = binary.LittleEndian.AppendUint16(extra, 7)
+	extra = binary.LittleEndian.AppendUint16(extra, zipAESVersion)
+	extra = append(extra, 'A', 'E', zipAESStrength256)
+	extra = binary.LittleEndian.AppendUint16(extra, method)
+
+	extra = binary.LittleEndian.AppendUint16(extra, 0x5455)
+	extra = binary.LittleEndian.AppendUint16(extra, 5)
+	extra = append(extra, 1) // Modification time only
+	return binary.LittleEndian.AppendUint32(extra, uint32(modified.Unix()))
+}
+
+// zipAESMethod returns the real compression method from an entry's AES extra field
+func zipAESMethod(extra []byte) (uint16, error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+size {
+			break
+		}
+		field := extra[4 : 4+size]
+		if id == zipAESExtraID {
+			if size != 7 || field[2] != 'A' || field[3] != 'E' {
+				return 0, fmt.Errorf("malformed AES extra field")
+			}
+			if field[4] != zipAESStrength256 {
+				return 0, fmt.Errorf("unsupported AES key strength %d", field[4])
+			}
+			return binary.LittleEndian.Uint16(field[5:]), nil
+		}
+		extra = extra[4+size:]
+	}
+	return 0, fmt.Errorf("AES entry has no AES extra field")
+}
+
+// msDosTime converts t to the MS-DOS date and time fields of a zip header
+func msDosTime(t time.Time) (uint16, uint16) {
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+// openZipEntry opens an entry for reading, decrypting AES entries with password
+// The authentication code is checked once the contents have been read to the end
+func openZipEntry(entry *zip.File, password string) (io.ReadCloser, error) {
+	if entry.Method != zipMethodAES {
+		return entry.Open()
+	}
+	if password == "" {
+		return nil, fmt.Errorf("entry is encrypted and no password was given")
+	}
+	method, err := zipAESMethod(entry.Extra)
+	if err != nil {
+		return nil, err
+	}
+	if method != zip.Deflate && method != zip.Store {
+		return nil, fmt.Errorf("unsupported compression method %d", method)
+	}
+	overhead := int64(zipAESSaltSize + zipAESVerifierSize + zipAESAuthSize)
+	if int64(entry.CompressedSize64) < overhead {
+		return nil, fmt.Errorf("encrypted entry is truncated")
+	}
+
+	raw, err := entry.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, zipAESSaltSize+zipAESVerifierSize)
+	if _, err := io.ReadFull(raw, head); err != nil {
+		return nil, err
+	}
+	encKey, authKey, verifier := zipAESKeys(password, head[:zipAESSaltSize])
+	if !bytes.Equal(verifier, head[zipAESSaltSize:]) {
+		return nil, errZipPassword
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	decrypter := &zipAESReader{
+		data:    io.LimitReader(raw, int64(entry.CompressedSize64)-overhead),
+		trailer: raw,
+		ctr:     newZipAESCTR(block),
+		mac:     hmac.New(sha1.New, authKey),
+	}
+	if method == zip.Store {
+		return io.NopCloser(decrypter), nil
+	}
+	return &zipAESInflater{ReadCloser: flate.NewReader(decrypter), decrypter: decrypter}, nil
+}
+
+// zipAESKeys derives the encryption key, authentication key and password verifier
+func zipAESKeys(password string, salt []byte) ([]byte, []byte, []byte) {
+	key := pbkdf2SHA1([]byte(password), salt, zipAESIterations, 2*zipAESKeySize+zipAESVerifierSize)
+	return key[:zipAESKeySize], key[zipAESKeySize : 2*zipAESKeySize], key[2*zipAESKeySize:]
+}
+
+// pbkdf2SHA1 is PBKDF2 with HMAC-SHA1, as the WinZip AES format specifies
+func pbkdf2SHA1(password, salt []byte, iterations, keyLen int) []byte {
+	return pbkdf2Key(sha1.New, password, salt, iterations, keyLen)
+}
+
+// pbkdf2Key is PBKDF2 (RFC 8018) with HMAC over h
+func pbkdf2Key(h func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// zipAESCTR is AES in counter mode with the little-endian counter, starting at 1,
+// that WinZip AES uses in place of the standard big-endian one
+type zipAESCTR struct {
+	block     cipher.Block
+	counter   [aes.BlockSize]byte
+	keystream [aes.BlockSize]byte
+	used      int
+}
+
+func newZipAESCTR(block cipher.Block) *zipAESCTR {
+	return &zipAESCTR{block: block, used: aes.BlockSize}
+}
+
+// xor encrypts or decrypts src into dst
+func (c *zipAESCTR) xor(dst, src []byte) {
+	for i := range src {
+		if c.used == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.keystream[:], c.counter[:])
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.keystream[c.used]
+		c.used++
+	}
+}
+
+// zipAESWriter encrypts what is written to it and authenticates the ciphertext
+type zipAESWriter struct {
+	w   io.Writer
+	ctr *zipAESCTR
+	mac hash.Hash
+	buf []byte
+}
+
+func newZipAESWriter(w io.Writer, encKey, authKey []byte) (*zipAESWriter, error) {
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	return &zipAESWriter{w: w, ctr: newZipAESCTR(block), mac: hmac.New(sha1.New, authKey)}, nil
+}
+
+func (z *zipAESWriter) Write(p []byte) (int, error) {
+	if cap(z.buf) < len(p) {
+		z.buf = make([]byte, len(p))
+	}
+	out := z.buf[:len(p)]
+	z.ctr.xor(out, p)
+	z.mac.Write(out)
+	if _, err := z.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// zipAESReader decrypts an entry's data and checks the authentication code that follows it
+type zipAESReader struct {
+	data    io.Reader // Ciphertext only
+	trailer io.Reader // Positioned at the authentication code once data is exhausted
+	ctr     *zipAESCTR
+	mac     hash.Hash
+	checked bool
+}
+
+func (z *zipAESReader) Read(p []byte) (int, error) {
+	if z.checked {
+		return 0, io.EOF
+	}
+	n, err := z.data.Read(p)
+	z.mac.Write(p[:n])
+	z.ctr.xor(p[:n], p[:n])
+	if err == io.EOF {
+		code := make([]byte, zipAESAuthSize)
+		if _, readErr := io.ReadFull(z.trailer, code); readErr != nil {
+			return n, fmt.Errorf("failed to read authentication code: %w", readErr)
+		}
+		if !hmac.Equal(code, z.mac.Sum(nil)[:zipAESAuthSize]) {
+			return n, fmt.Errorf("authentication code does not match; the entry is damaged or altered")
+		}
+		z.checked = true
+	}
+	return n, err
+}
+
+// zipAESInflater inflates a decrypted entry and, at the end of the compressed stream,
+// reads the rest of the ciphertext so the authentication code is always checked
+type zipAESInflater struct {
+	io.ReadCloser
+	decrypter *zipAESReader
+}
+
+func (z *zipAESInflater) Read(p []byte) (int, error) {
+	n, err := z.ReadCloser.Read(p)
+	if err == io.EOF {
+		if _, drainErr := io.Copy(io.Discard, z.decrypter); drainErr != nil {
+			return n, drainErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPBKDF2KnownAnswers(t *testing.T) {
+	cases := []struct {
+		name       string
+		h          func() hash.Hash
+		password   string
+		salt       string
+		iterations int
+		want       string
+	}{
+		// RFC 6070
+		{"sha1 c=1", sha1.New, "password", "salt", 1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"sha1 c=2", sha1.New, "password", "salt", 2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"sha1 c=4096", sha1.New, "password", "salt", 4096, "4b007901b765489abead49d926f721d065a429c1"},
+		{"sha1 two blocks", sha1.New, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+			"3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		// The same inputs with HMAC-SHA256, as used for the password check
+		{"sha256 c=1", sha256.New, "password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"sha256 c=2", sha256.New, "password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"sha256 two blocks", sha256.New, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+	}
+	for _, c := range cases {
+		want, _ := hex.DecodeString(c.want)
+		got := pbkdf2Key(c.h, []byte(c.password), []byte(c.salt), c.iterations, len(want))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %x, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+// libarchiveAE is memo.txt encrypted by libarchive (bsdtar --options zip:encryption=aes256)
+// with the password "correct horse battery", so it was written independently of this package
+const libarchiveAE = "504b03041400090063007b91525d00000000000000000000000008002b006d656d6f2e74787475780b000104000000000400000000019907000100414503080055540d0007eb0bd56aeb0bd56aeb0bd56a6269f6953a82edf7695d6bc918d01a36830f6304a7a10a559d331c2f469d8e15941d55116068ef6d4091e29382f2719c7d7d17d4df2c41de5b47b43038090fbf4e504b07089fd13c4d4100000027000000504b010214031400090063007b91525d9fd13c4d4100000027000000080023000000000000000000a481000000006d656d6f2e74787475780b00010400000000040000000001990700010041450308005554050001eb0bd56a504b0506000000000100010059000000a20000000000"
+
+func TestOpenZipEntryDecryptsWinZipAES(t *testing.T) {
+	data, _ := hex.DecodeString(libarchiveAE)
+	read := func(data []byte, password string) (string, error) {
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return "", err
+		}
+		rc, err := openZipEntry(r.File[0], password)
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		got, err := io.ReadAll(rc)
+		return string(got), err
+	}
+
+	got, err := read(data, "correct horse battery")
+	if err != nil || got != "Bates DOI0000001: privileged memo text\n" {
+		t.Errorf("decrypted %q, %v", got, err)
+	}
+	if _, err := read(data, "incorrect horse battery"); err == nil {
+		t.Error("a wrong password opened the entry")
+	}
+
+	// A flipped ciphertext byte must fail the authentication code
+	tampered := append([]byte{}, data...)
+	tampered[0x60] ^= 1
+	if _, err := read(tampered, "correct horse battery"); err == nil {
+		t.Error("tampered ciphertext was accepted")
+	}
+}
+
+func TestEncryptedVolumeRoundTrip(t *testing.T) {
+	const name = "VOL001/NATIVES/DOI0000001.txt"
+	var buf bytes.Buffer
+	vz := newVolumeZip(&buf, "correct horse battery", bytes.Repeat([]byte{7}, zipAESSaltSize))
+	plain := bytes.Repeat([]byte("responsive document text "), 200)
+	size, sum, _, err := vz.copyEncrypted(name, time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(plain)) {
+		t.Errorf("size = %d, want %d", size, len(plain))
+	}
+	if bytes.Contains(buf.Bytes(), []byte("responsive document")) {
+		t.Error("plaintext appears in the encrypted volume")
+	}
+
+	path := filepath.Join(t.TempDir(), "VOL001.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyZipEntries(path, map[string]string{name: sum}, "correct horse battery"); err != nil {
+		t.Errorf("verify with the password: %v", err)
+	}
+	if err := verifyZipEntries(path, map[string]string{name: sum}, "wrong password!"); err == nil {
+		t.Error("verify passed with the wrong password")
+	}
+}
+
+func TestCheckProductionPassword(t *testing.T) {
+	seed := bytes.Repeat([]byte{1}, zipAESSaltSize)
+	settings := ProductionSettings{
+		EncryptionSeed: hex.EncodeToString(seed),
+		PasswordCheck:  passwordCheck("correct horse battery", seed),
+	}
+	if err := checkProductionPassword(1, settings, "correct horse battery"); err != nil {
+		t.Errorf("right password refused: %v", err)
+	}
+	if err := checkProductionPassword(1, settings, "incorrect horse"); !errors.Is(err, errZipPassword) {
+		t.Errorf("wrong password: got %v", err)
+	}
+
+	// A check value without a scheme is refused, whatever password is given
+	settings.PasswordCheck = hex.EncodeToString(pbkdf2SHA1([]byte("correct horse battery"), seed, zipAESIterations, zipAESKeySize))
+	if err := checkProductionPassword(1, settings, "correct horse battery"); err == nil || errors.Is(err, errZipPassword) {
+		t.Errorf("bare hex check: got %v, want an unrecognized check error", err)
+	}
+}
@@ -0,0 +1,259 @@
+package textmodel
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// TopicModel clusters TF-IDF vectors with spherical k-means
+// Each centroid is a topic; its heaviest terms describe it
+type TopicModel struct {
+	Vectorizer *Vectorizer `json:"vectorizer"`
+	Centroids  [][]float64 `json:"centroids"`
+}
+
+// TopicWeight is the share of a document attributed to one topic
+type TopicWeight struct {
+	Topic  int     `json:"topic"`
+	Weight float64 `json:"weight"`
+}
+
+// TrainTopicModel fits a vectorizer and k-means clustering over the documents
+// The seed makes training reproducible; k is capped at the number of usable documents
+func TrainTopicModel(docs []string, k int, seed int64) (*TopicModel, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("topic count must be positive, got %d", k)
+	}
+
+	tokenized := make([][]string, len(docs))
+	for i, doc := range docs {
+		tokenized[i] = Tokenize(doc)
+	}
+
+	// Terms must appear at least twice to form a cluster; terms in over half
+	// the corpus (e.g. "email") separate nothing
+	vectorizer := FitVectorizer(tokenized, 2, 0.5)
+	if vectorizer.Size() == 0 {
+		return nil, fmt.Errorf("no usable terms in %d documents", len(docs))
+	}
+
+	var vectors []Vector
+	for _, tokens := range tokenized {
+		vec := vectorizer.Transform(tokens)
+		if len(vec) > 0 {
+			vectors = append(vectors, vec)
+		}
+	}
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("no documents contain vocabulary terms")
+	}
+	if k > len(vectors) {
+		k = len(vectors)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	centroids := initCentroids(vectors, vectorizer.Size(), k, rng)
+
+	assignments := make([]int, len(vectors))
+	for iter := 0; iter < 50; iter++ {
+		changed := false
+		for i, vec := range vectors {
+			if best := nearestCentroid(vec, centroids); best != assignments[i] {
+				assignments[i] = best
+				changed = true
+			}
+		}
+
+		centroids = recomputeCentroids(vectors, assignments, centroids, vectorizer.Size())
+		if !changed && iter > 0 {
+			break
+		}
+	}
+
+	return &TopicModel{
+		Vectorizer: vectorizer,
+		Centroids:  centroids,
+	}, nil
+}
+
+// initCentroids picks starting centroids with k-means++ seeding
+func initCentroids(vectors []Vector, dim, k int, rng *rand.Rand) [][]float64 {
+	centroids := make([][]float64, 0, k)
+	centroids = append(centroids, densify(vectors[rng.Intn(len(vectors))], dim))
+
+	distances := make([]float64, len(vectors))
+	for len(centroids) < k {
+		var total float64
+		for i, vec := range vectors {
+			// Cosine distance to the closest centroid chosen so far
+			best := math.Inf(1)
+			for _, c := range centroids {
+				d := 1 - vec.Dot(c)
+				if d < best {
+					best = d
+				}
+			}
+			distances[i] = best * best
+			total += distances[i]
+		}
+
+		if total == 0 {
+			// Every remaining vector duplicates a centroid; pick any
+			centroids = append(centroids, densify(vectors[rng.Intn(len(vectors))], dim))
+			continue
+		}
+
+		target := rng.Float64() * total
+		for i, d := range distances {
+			target -= d
+			if target <= 0 {
+				centroids = append(centroids, densify(vectors[i], dim))
+				break
+			}
+		}
+		if target > 0 {
+			centroids = append(centroids, densify(vectors[len(vectors)-1], dim))
+		}
+	}
+	return centroids
+}
+
+// recomputeCentroids averages each cluster and renormalizes it
+// Empty clusters keep their previous centroid rather than collapsing to zero
+func recomputeCentroids(vectors []Vector, assignments []int, previous [][]float64, dim int) [][]float64 {
+	sums := make([][]float64, len(previous))
+	counts := make([]int, len(previous))
+	for i := range sums {
+		sums[i] = make([]float64, dim)
+	}
+
+	for i, vec := range vectors {
+		c := assignments[i]
+		counts[c]++
+		for j, w := range vec {
+			sums[c][j] += w
+		}
+	}
+
+	for c := range sums {
+		if counts[c] == 0 {
+			sums[c] = previous[c]
+			continue
+		}
+		normalizeDense(sums[c])
+	}
+	return sums
+}
+
+// nearestCentroid returns the index of the most similar centroid
+func nearestCentroid(vec Vector, centroids [][]float64) int {
+	best, bestSim := 0, math.Inf(-1)
+	for c, centroid := range centroids {
+		if sim := vec.Dot(centroid); sim > bestSim {
+			best, bestSim = c, sim
+		}
+	}
+	return best
+}
+
+// densify expands a sparse vector into a dense slice
+func densify(vec Vector, dim int) []float64 {
+	dense := make([]float64, dim)
+	for i, w := range vec {
+		dense[i] = w
+	}
+	return dense
+}
+
+// normalizeDense scales a dense vector to unit length in place
+func normalizeDense(dense []float64) {
+	var sum float64
+	for _, w := range dense {
+		sum += w * w
+	}
+	if sum == 0 {
+		return
+	}
+	norm := math.Sqrt(sum)
+	for i := range dense {
+		dense[i] /= norm
+	}
+}
+
+// TopicCount returns the number of topics in the model
+func (m *TopicModel) TopicCount() int {
+	return len(m.Centroids)
+}
+
+// TopTerms returns the n highest-weighted terms of a topic's centroid
+func (m *TopicModel) TopTerms(topic, n int) []string {
+	centroid := m.Centroids[topic]
+	indices := make([]int, 0, len(centroid))
+	for i, w := range centroid {
+		if w > 0 {
+			indices = append(indices, i)
+		}
+	}
+	sort.Slice(indices, func(a, b int) bool {
+		if centroid[indices[a]] != centroid[indices[b]] {
+			return centroid[indices[a]] > centroid[indices[b]]
+		}
+		return indices[a] < indices[b]
+	})
+
+	if len(indices) > n {
+		indices = indices[:n]
+	}
+	terms := make([]string, len(indices))
+	for i, idx := range indices {
+		terms[i] = m.Vectorizer.Terms[idx]
+	}
+	return terms
+}
+
+// Assign scores a document against every topic and keeps the strongest
+// Topics with cosine similarity below minSimilarity are dropped, at most
+// maxTopics are returned, and weights are rescaled to sum to one
+func (m *TopicModel) Assign(text string, maxTopics int, minSimilarity float64) []TopicWeight {
+	vec := m.Vectorizer.Transform(Tokenize(text))
+	if len(vec) == 0 {
+		return nil
+	}
+
+	scores := make([]TopicWeight, len(m.Centroids))
+	for c, centroid := range m.Centroids {
+		scores[c] = TopicWeight{Topic: c, Weight: vec.Dot(centroid)}
+	}
+	sort.Slice(scores, func(a, b int) bool {
+		if scores[a].Weight != scores[b].Weight {
+			return scores[a].Weight > scores[b].Weight
+		}
+		return scores[a].Topic < scores[b].Topic
+	})
+
+	var kept []TopicWeight
+	for _, s := range scores {
+		if len(kept) >= maxTopics {
+			break
+		}
+		// The best topic is always kept so every document with known terms is labelled
+		if s.Weight < minSimilarity && len(kept) > 0 {
+			break
+		}
+		if s.Weight <= 0 {
+			break
+		}
+		kept = append(kept, s)
+	}
+
+	var total float64
+	for _, s := range kept {
+		total += s.Weight
+	}
+	for i := range kept {
+		kept[i].Weight /= total
+	}
+	return kept
+}
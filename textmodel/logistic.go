@@ -0,0 +1,183 @@
+package textmodel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Training settings for the logistic classifier
+// Inputs are unit-length TF-IDF vectors, so a large step size converges quickly
+const (
+	logisticIterations   = 300
+	logisticLearningRate = 2.0
+	logisticL2           = 1e-4
+)
+
+// LogisticModel is a binary logistic regression over TF-IDF features
+// Score returns the probability that a document belongs to the positive class
+type LogisticModel struct {
+	Vectorizer *Vectorizer `json:"vectorizer"`
+	Weights    []float64   `json:"weights"`
+	Bias       float64     `json:"bias"`
+}
+
+// TrainLogistic fits a vectorizer on corpus and a classifier on the labelled docs
+// The corpus should include unlabelled documents so the vocabulary covers what will be scored
+// Classes are weighted by inverse frequency, so a few positives among many negatives still count
+func TrainLogistic(corpus []string, docs []string, labels []bool) (*LogisticModel, error) {
+	if len(docs) != len(labels) {
+		return nil, fmt.Errorf("got %d documents but %d labels", len(docs), len(labels))
+	}
+
+	tokenized := make([][]string, len(corpus))
+	for i, doc := range corpus {
+		tokenized[i] = Tokenize(doc)
+	}
+	// Rare terms are kept down to two documents: a term seen only in responsive
+	// documents is exactly the signal the classifier is looking for
+	vectorizer := FitVectorizer(tokenized, 2, 0.95)
+	if vectorizer.Size() == 0 {
+		return nil, fmt.Errorf("no usable terms in %d documents", len(corpus))
+	}
+
+	vectors := make([]Vector, len(docs))
+	for i, doc := range docs {
+		vectors[i] = vectorizer.Transform(Tokenize(doc))
+	}
+	weights, bias, err := trainLogisticWeights(vectors, labels, vectorizer.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &LogisticModel{Vectorizer: vectorizer, Weights: weights, Bias: bias}, nil
+}
+
+// trainLogisticWeights runs full-batch gradient descent with L2 regularization
+// Full batches keep training deterministic for the same inputs
+func trainLogisticWeights(vectors []Vector, labels []bool, dim int) ([]float64, float64, error) {
+	var positives int
+	for _, l := range labels {
+		if l {
+			positives++
+		}
+	}
+	negatives := len(labels) - positives
+	if positives == 0 || negatives == 0 {
+		return nil, 0, fmt.Errorf("training needs both classes, got %d positive and %d negative", positives, negatives)
+	}
+
+	// Balanced class weights: each class contributes half of the total loss
+	n := float64(len(labels))
+	positiveWeight := n / (2 * float64(positives))
+	negativeWeight := n / (2 * float64(negatives))
+
+	weights := make([]float64, dim)
+	var bias float64
+	gradient := make([]float64, dim)
+	for iter := 0; iter < logisticIterations; iter++ {
+		for i := range gradient {
+			gradient[i] = 0
+		}
+		var biasGradient float64
+		for i, vec := range vectors {
+			target, sampleWeight := 0.0, negativeWeight
+			if labels[i] {
+				target, sampleWeight = 1.0, positiveWeight
+			}
+			err := (sigmoid(vec.Dot(weights)+bias) - target) * sampleWeight
+			for j, x := range vec {
+				gradient[j] += err * x
+			}
+			biasGradient += err
+		}
+		for j := range weights {
+			weights[j] -= logisticLearningRate * (gradient[j]/n + logisticL2*weights[j])
+		}
+		bias -= logisticLearningRate * biasGradient / n
+	}
+	return weights, bias, nil
+}
+
+// sigmoid maps a log-odds value to a probability
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+// Score returns the probability that text belongs to the positive class
+// Text with no vocabulary terms scores on the bias alone
+func (m *LogisticModel) Score(text string) float64 {
+	vec := m.Vectorizer.Transform(Tokenize(text))
+	return sigmoid(vec.Dot(m.Weights) + m.Bias)
+}
+
+// TopTerms returns the n terms pushing hardest toward the positive class,
+// or toward the negative class when positive is false
+func (m *LogisticModel) TopTerms(n int, positive bool) []string {
+	indices := make([]int, 0, len(m.Weights))
+	for i, w := range m.Weights {
+		if (positive && w > 0) || (!positive && w < 0) {
+			indices = append(indices, i)
+		}
+	}
+	sort.Slice(indices, func(a, b int) bool {
+		wa, wb := math.Abs(m.Weights[indices[a]]), math.Abs(m.Weights[indices[b]])
+		if wa != wb {
+			return wa > wb
+		}
+		return indices[a] < indices[b]
+	})
+
+	if len(indices) > n {
+		indices = indices[:n]
+	}
+	terms := make([]string, len(indices))
+	for i, idx := range indices {
+		terms[i] = m.Vectorizer.Terms[idx]
+	}
+	return terms
+}
+
+// CrossValidate estimates accuracy on unseen documents with k-fold cross-validation
+// The model's vectorizer is reused; only the weights are retrained per fold
+// Folds are assigned round-robin, so the estimate is reproducible
+func (m *LogisticModel) CrossValidate(docs []string, labels []bool, folds int) (float64, error) {
+	if folds < 2 {
+		return 0, fmt.Errorf("cross-validation needs at least 2 folds, got %d", folds)
+	}
+	if len(docs) < folds {
+		return 0, fmt.Errorf("cross-validation needs at least %d documents, got %d", folds, len(docs))
+	}
+
+	vectors := make([]Vector, len(docs))
+	for i, doc := range docs {
+		vectors[i] = m.Vectorizer.Transform(Tokenize(doc))
+	}
+
+	var correct, tested int
+	for fold := 0; fold < folds; fold++ {
+		var trainVectors []Vector
+		var trainLabels []bool
+		for i := range vectors {
+			if i%folds != fold {
+				trainVectors = append(trainVectors, vectors[i])
+				trainLabels = append(trainLabels, labels[i])
+			}
+		}
+		weights, bias, err := trainLogisticWeights(trainVectors, trainLabels, m.Vectorizer.Size())
+		if err != nil {
+			// A fold without both classes cannot be trained; skip it rather than fail
+			continue
+		}
+		for i := fold; i < len(vectors); i += folds {
+			predicted := sigmoid(vectors[i].Dot(weights)+bias) >= 0.5
+			if predicted == labels[i] {
+				correct++
+			}
+			tested++
+		}
+	}
+	if tested == 0 {
+		return 0, fmt.Errorf("no fold had both classes to train on")
+	}
+	return float64(correct) / float64(tested), nil
+}
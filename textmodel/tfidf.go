@@ -0,0 +1,140 @@
+package textmodel
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// Vector is a sparse term-weight vector keyed by vocabulary index
+type Vector map[int]float64
+
+// Vectorizer turns token lists into L2-normalized TF-IDF vectors
+// Terms and IDF are exported so a fitted vectorizer can be persisted as JSON
+// The term index is built when fitting or decoding and only read afterwards, so one
+// vectorizer can transform from several goroutines
+type Vectorizer struct {
+	Terms []string  `json:"terms"`
+	IDF   []float64 `json:"idf"`
+	index map[string]int
+}
+
+// UnmarshalJSON decodes a persisted vectorizer and builds its term index
+func (v *Vectorizer) UnmarshalJSON(data []byte) error {
+	type fields Vectorizer // Without the method, so decoding does not recurse
+	if err := json.Unmarshal(data, (*fields)(v)); err != nil {
+		return err
+	}
+	v.buildIndex()
+	return nil
+}
+
+// FitVectorizer learns the vocabulary and IDF weights from a tokenized corpus
+// Terms appearing in fewer than minDF documents or in more than maxDFRatio of
+// all documents are excluded - they are either noise or carry no signal
+func FitVectorizer(docs [][]string, minDF int, maxDFRatio float64) *Vectorizer {
+	df := make(map[string]int)
+	for _, tokens := range docs {
+		seen := make(map[string]bool)
+		for _, t := range tokens {
+			if !seen[t] {
+				seen[t] = true
+				df[t]++
+			}
+		}
+	}
+
+	n := float64(len(docs))
+	var terms []string
+	for term, count := range df {
+		if count < minDF {
+			continue
+		}
+		if maxDFRatio > 0 && float64(count)/n > maxDFRatio {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	// Sorted vocabulary keeps training reproducible across runs
+	sort.Strings(terms)
+
+	v := &Vectorizer{
+		Terms: terms,
+		IDF:   make([]float64, len(terms)),
+	}
+	for i, term := range terms {
+		// Smoothed IDF, as in scikit-learn: ln((1+n)/(1+df)) + 1
+		v.IDF[i] = math.Log((1+n)/(1+float64(df[term]))) + 1
+	}
+	v.buildIndex()
+	return v
+}
+
+// buildIndex builds the term lookup after fitting or unmarshalling
+func (v *Vectorizer) buildIndex() {
+	v.index = termIndex(v.Terms)
+}
+
+// termIndex maps each term to its vocabulary position
+func termIndex(terms []string) map[string]int {
+	index := make(map[string]int, len(terms))
+	for i, term := range terms {
+		index[term] = i
+	}
+	return index
+}
+
+// Size returns the number of terms in the vocabulary
+func (v *Vectorizer) Size() int {
+	return len(v.Terms)
+}
+
+// Transform converts tokens to a normalized TF-IDF vector
+// Tokens outside the vocabulary are ignored; an empty vector means no known terms
+func (v *Vectorizer) Transform(tokens []string) Vector {
+	index := v.index
+	if index == nil {
+		// Assembled by hand rather than fitted or decoded; build a lookup without storing it
+		index = termIndex(v.Terms)
+	}
+
+	counts := make(map[int]float64)
+	for _, t := range tokens {
+		if i, ok := index[t]; ok {
+			counts[i]++
+		}
+	}
+
+	vec := make(Vector, len(counts))
+	for i, tf := range counts {
+		vec[i] = tf * v.IDF[i]
+	}
+	vec.normalize()
+	return vec
+}
+
+// normalize scales the vector to unit length in place
+func (vec Vector) normalize() {
+	var sum float64
+	for _, w := range vec {
+		sum += w * w
+	}
+	if sum == 0 {
+		return
+	}
+	norm := math.Sqrt(sum)
+	for i := range vec {
+		vec[i] /= norm
+	}
+}
+
+// Dot returns the dot product of a sparse vector with a dense one
+func (vec Vector) Dot(dense []float64) float64 {
+	var total float64
+	for i, w := range vec {
+		if i < len(dense) {
+			total += w * dense[i]
+		}
+	}
+	return total
+}
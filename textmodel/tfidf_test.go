@@ -0,0 +1,51 @@
+package textmodel
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestVectorizerJSONRoundTrip(t *testing.T) {
+	docs := [][]string{
+		{"budget", "approval", "contract"},
+		{"budget", "review", "contract"},
+		{"telework", "agreement", "review"},
+	}
+	fitted := FitVectorizer(docs, 1, 0)
+	data, err := json.Marshal(fitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Vectorizer
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.index == nil {
+		t.Fatal("decoding did not build the term index")
+	}
+	tokens := []string{"budget", "contract", "contract", "unknown"}
+	if got, want := decoded.Transform(tokens), fitted.Transform(tokens); !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded transform = %v, want %v", got, want)
+	}
+}
+
+func TestVectorizerTransformIsReadOnly(t *testing.T) {
+	// Assembled by hand, so there is no index; Transform must not build one in place
+	v := &Vectorizer{Terms: []string{"alpha", "beta"}, IDF: []float64{1, 2}}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if vec := v.Transform([]string{"alpha", "beta"}); len(vec) != 2 {
+				t.Errorf("transform = %v", vec)
+			}
+		}()
+	}
+	wg.Wait()
+	if v.index != nil {
+		t.Error("Transform stored an index on a shared vectorizer")
+	}
+}
@@ -0,0 +1,62 @@
+package textmodel
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are dropped before vectorizing because they carry no topical signal
+// Includes email boilerplate ("re", "fwd") that would otherwise dominate subjects
+var stopWords = map[string]bool{
+	"a": true, "about": true, "above": true, "after": true, "again": true, "all": true,
+	"also": true, "am": true, "an": true, "and": true, "any": true, "are": true,
+	"as": true, "at": true, "be": true, "because": true, "been": true, "before": true,
+	"being": true, "below": true, "between": true, "both": true, "but": true, "by": true,
+	"can": true, "could": true, "did": true, "do": true, "does": true, "doing": true,
+	"down": true, "during": true, "each": true, "few": true, "for": true, "from": true,
+	"further": true, "had": true, "has": true, "have": true, "having": true, "he": true,
+	"her": true, "here": true, "hers": true, "him": true, "his": true, "how": true,
+	"i": true, "if": true, "in": true, "into": true, "is": true, "it": true, "its": true,
+	"just": true, "me": true, "more": true, "most": true, "my": true, "no": true,
+	"nor": true, "not": true, "now": true, "of": true, "off": true, "on": true,
+	"once": true, "only": true, "or": true, "other": true, "our": true, "ours": true,
+	"out": true, "over": true, "own": true, "same": true, "she": true, "should": true,
+	"so": true, "some": true, "such": true, "than": true, "that": true, "the": true,
+	"their": true, "them": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "those": true, "through": true, "to": true, "too": true, "under": true,
+	"until": true, "up": true, "very": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "while": true, "who": true,
+	"whom": true, "why": true, "will": true, "with": true, "would": true, "you": true,
+	"your": true, "yours": true,
+	// Email and file boilerplate
+	"re": true, "fw": true, "fwd": true, "cc": true, "bcc": true, "sent": true,
+	"subject": true, "pdf": true, "docx": true, "doc": true, "msg": true, "eml": true,
+	"file": true, "files": true, "please": true, "thanks": true, "thank": true,
+}
+
+// Tokenize splits text into lowercase terms suitable for TF-IDF
+// Terms shorter than three characters, pure numbers and stop words are dropped
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if len(field) < 3 || stopWords[field] || isNumeric(field) {
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+// isNumeric reports whether the term contains only digits
+func isNumeric(term string) bool {
+	for _, r := range term {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}